@@ -34,6 +34,7 @@ func main() {
 		op.EventRecorder,
 		op.InstanceProvider,
 		op.InstanceTypeProvider,
+		op.NetworkClient,
 	)
 	lo.Must0(op.AddHealthzCheck("cloud-provider", osCloudProvider.LivenessProbe))
 
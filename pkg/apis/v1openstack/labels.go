@@ -0,0 +1,96 @@
+package v1openstack
+
+// Metadata keys stamped on every Nova server launched by Karpenter. They are used to
+// recognise servers owned by a cluster and to map them back to their NodeClaims.
+const (
+	ClusterMetadataKey   = GroupName + "/cluster"
+	NodePoolMetadataKey  = GroupName + "/nodepool"
+	NodeClaimMetadataKey = GroupName + "/nodeclaim"
+	NodeClassMetadataKey = GroupName + "/openstacknodeclass"
+	// ReservationMetadataKey holds the ID of the Blazar reservation a reserved server was launched
+	// into.
+	ReservationMetadataKey = GroupName + "/reservation-id"
+	// ReservationTypeMetadataKey holds the type of the Blazar reservation of a reserved server, one
+	// of the values of LabelReservationType.
+	ReservationTypeMetadataKey = GroupName + "/reservation-type"
+	// CapacityTypeMetadataKey holds the capacity type of the spot servers. Other servers are
+	// on-demand, or reserved when they carry ReservationMetadataKey.
+	CapacityTypeMetadataKey = GroupName + "/capacity-type"
+)
+
+const (
+	// OpenStackNodeClassHashVersion is bumped whenever the hashed fields change in a way that
+	// would otherwise mark every existing NodeClaim as drifted.
+	OpenStackNodeClassHashVersion = "v1"
+
+	AnnotationOpenStackNodeClassHash        = GroupName + "/openstacknodeclass-hash"
+	AnnotationOpenStackNodeClassHashVersion = GroupName + "/openstacknodeclass-hash-version"
+)
+
+// AnnotationFloatingIP records on the NodeClaim the floating IP address allocated for its server.
+const AnnotationFloatingIP = GroupName + "/floating-ip"
+
+// Labels derived from the Nova flavor extra specs. They are registered as well-known labels so that
+// pods can select flavors with ordinary nodeAffinity.
+const (
+	// LabelCPUPolicy is the hw:cpu_policy of the flavor, "shared" unless the flavor pins CPUs.
+	LabelCPUPolicy = GroupName + "/cpu-policy"
+	// LabelMemPageSize is the hw:mem_page_size of the flavor, e.g. "large" or "1GB".
+	LabelMemPageSize = GroupName + "/mem-page-size"
+	// LabelCPUSockets is the hw:cpu_sockets of the flavor.
+	LabelCPUSockets = GroupName + "/cpu-sockets"
+)
+
+// Prefixes of the labels derived from the open-ended flavor extra specs. As their keys are only
+// known once the flavors are listed, they can't be registered as well-known labels: a NodePool has
+// to mention the key in its requirements before pods can select it.
+const (
+	// LabelTraitPrefix is followed by the trait of a trait:<TRAIT> extra spec. The value is
+	// "required" or "forbidden".
+	LabelTraitPrefix = GroupName + "/trait-"
+	// LabelAggregatePrefix is followed by the key of an aggregate_instance_extra_specs:<key> extra spec.
+	LabelAggregatePrefix = GroupName + "/aggregate-"
+	// LabelResourcePrefix is followed by the resource class of a resources:CUSTOM_<name> extra spec.
+	// The value is the requested amount.
+	LabelResourcePrefix = GroupName + "/resource-"
+)
+
+// Labels describing the flavor of a node. They are registered as well-known labels so that NodePool
+// requirements and minValues can target them.
+const (
+	// LabelFlavorID is the ID of the flavor, node.kubernetes.io/instance-type being its name.
+	LabelFlavorID = GroupName + "/flavor-id"
+	// LabelInstanceCPU is the number of vCPUs of the flavor.
+	LabelInstanceCPU = GroupName + "/instance-cpu"
+	// LabelInstanceMemory is the RAM of the flavor in MiB.
+	LabelInstanceMemory = GroupName + "/instance-memory"
+	// LabelInstanceFamily and LabelInstanceSize are parsed from the flavor name, e.g. "m1" and
+	// "large" for "m1.large". Flavors whose name doesn't follow the naming convention have neither.
+	LabelInstanceFamily = GroupName + "/instance-family"
+	LabelInstanceSize   = GroupName + "/instance-size"
+)
+
+// Labels describing the GPUs of a flavor, from its pci_passthrough:alias and resources:VGPU extra
+// specs. They are registered as well-known labels so that pods can select a GPU model.
+const (
+	// LabelGPUName is the model configured for the PCI alias or the vGPUs of the flavor.
+	LabelGPUName = GroupName + "/gpu-name"
+	// LabelGPUCount is the number of GPUs of the flavor exposed as extended resources.
+	LabelGPUCount = GroupName + "/gpu-count"
+)
+
+// Labels of the nodes launched into Blazar reservations, with the karpenter.sh/capacity-type label
+// set to "reserved". Other nodes have neither.
+const (
+	// LabelReservationID is the ID of the Blazar reservation of the node.
+	LabelReservationID = GroupName + "/reservation-id"
+	// LabelReservationType is "host" for physical host reservations and "instance" for instance
+	// reservations.
+	LabelReservationType = GroupName + "/reservation-type"
+)
+
+// Values of LabelReservationType.
+const (
+	ReservationTypeHost     = "host"
+	ReservationTypeInstance = "instance"
+)
@@ -1,7 +1,11 @@
 package v1openstack
 
 import (
+	"fmt"
+
 	"github.com/awslabs/operatorpkg/status"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -16,6 +20,8 @@ type OpenStackNodeClass struct {
 	Status OpenStackNodeClassStatus `json:"status,omitempty"`
 }
 
+// +kubebuilder:validation:XValidation:message="floatingIPNetwork is required when floatingIP is enabled",rule="!has(self.floatingIP) || !self.floatingIP || has(self.floatingIPNetwork)"
+// +kubebuilder:validation:XValidation:message="floatingIP is not supported with a preemptible project",rule="!has(self.floatingIP) || !self.floatingIP || !has(self.preemptible) || !has(self.preemptible.project) || !self.preemptible.project"
 // +k8s:deepcopy-gen=true
 type OpenStackNodeClassSpec struct {
 	// Flavor defines the OpenStack flavor to use for the node.
@@ -25,7 +31,9 @@ type OpenStackNodeClassSpec struct {
 	// +optional
 	KeyPair string `json:"keyPair,omitempty"`
 
-	// Disks defines the disks to attach to the provisioned instance.
+	// Disks defines the disks to attach to the provisioned instance. The boot disk, if any, is a
+	// Cinder volume created from the image; the other disks are attached as blank volumes.
+	// +kubebuilder:validation:XValidation:message="at most one disk can be the boot disk",rule="self.filter(x, has(x.boot) && x.boot).size() <= 1"
 	// +kubebuilder:validation:MaxItems=10
 	// +optional
 	Disks []Disk `json:"disks,omitempty"`
@@ -39,22 +47,38 @@ type OpenStackNodeClassSpec struct {
 	ImageRef string `json:"imageRef,omitempty"`
 
 	// ImageSelectorTerms is a list of image selector terms. The terms are ORed.
+	// +kubebuilder:validation:XValidation:message="expected at least one, got none, ['id', 'alias', 'tags', 'properties']",rule="self.all(x, has(x.id) || has(x.alias) || has(x.tags) || has(x.properties))"
+	// +kubebuilder:validation:XValidation:message="'id' is mutually exclusive, cannot be set with a combination of other fields in imageSelectorTerms",rule="!self.exists(x, has(x.id) && (has(x.alias) || has(x.tags) || has(x.properties)))"
 	// +kubebuilder:validation:MinItems=1
 	// +kubebuilder:validation:MaxItems=30
-	ImageSelectorTerms []OpenStackImageSelectorTerm `json:"imageSelectorTerms"`
+	ImageSelectorTerms []OpenStackImageSelectorTerm `json:"imageSelectorTerms" hash:"ignore"`
+
+	// FlavorSelectorTerms restrict the flavors offered as instance types for this NodeClass. The
+	// terms are ORed. Every flavor is offered when no term is set.
+	// +kubebuilder:validation:XValidation:message="expected at least one, got none, ['id', 'name', 'nameRegex', 'minVCPUs', 'maxVCPUs', 'minMemoryMiB', 'maxMemoryMiB', 'extraSpecs', 'exclude']",rule="self.all(x, has(x.id) || has(x.name) || has(x.nameRegex) || has(x.minVCPUs) || has(x.maxVCPUs) || has(x.minMemoryMiB) || has(x.maxMemoryMiB) || has(x.extraSpecs) || has(x.exclude))"
+	// +kubebuilder:validation:XValidation:message="'id' is mutually exclusive, cannot be set with a combination of other fields in flavorSelectorTerms",rule="!self.exists(x, has(x.id) && (has(x.name) || has(x.nameRegex) || has(x.minVCPUs) || has(x.maxVCPUs) || has(x.minMemoryMiB) || has(x.maxMemoryMiB) || has(x.extraSpecs) || has(x.exclude)))"
+	// +kubebuilder:validation:XValidation:message="'name' and 'nameRegex' are mutually exclusive",rule="!self.exists(x, has(x.name) && has(x.nameRegex))"
+	// +kubebuilder:validation:MaxItems=30
+	// +optional
+	FlavorSelectorTerms []OpenStackFlavorSelectorTerm `json:"flavorSelectorTerms,omitempty" hash:"ignore"`
 
 	// Networks specifies the OpenStack networks to attach to the instance.
 	// +kubebuilder:validation:MinItems=1
-	Networks []string `json:"networks"`
+	Networks []string `json:"networks" hash:"ignore"`
 
 	// SecurityGroups specifies the OpenStack security groups to assign to the instance.
 	// +optional
-	SecurityGroups []string `json:"securityGroups,omitempty"`
+	SecurityGroups []string `json:"securityGroups,omitempty" hash:"ignore"`
 
-	// FloatingIP indicates whether to assign a floating IP to the instance.
+	// FloatingIP indicates whether to assign a floating IP to the instance. The floating IP is
+	// allocated from FloatingIPNetwork and released when the node is deleted.
 	// +optional
 	FloatingIP bool `json:"floatingIP,omitempty"`
 
+	// FloatingIPNetwork is the ID of the external network floating IPs are allocated from.
+	// +optional
+	FloatingIPNetwork string `json:"floatingIPNetwork,omitempty"`
+
 	// KubeletConfiguration defines args to be used when configuring kubelet on provisioned nodes.
 	// +optional
 	KubeletConfiguration *KubeletConfiguration `json:"kubeletConfiguration,omitempty"`
@@ -66,8 +90,44 @@ type OpenStackNodeClassSpec struct {
 	// Metadata contains key/value pairs to set as instance metadata.
 	// +optional
 	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Preemptible offers the flavors of the preemptible tier of the cloud with the spot capacity
+	// type. Their servers can be reclaimed at any time, the nodes are then drained.
+	// +optional
+	Preemptible *Preemptible `json:"preemptible,omitempty" hash:"ignore"`
+}
+
+// Preemptible configures the spot capacity type of a NodeClass. The preemptible tier is either a set
+// of flavors of the project, or a separate project the controller is given credentials for.
+// +kubebuilder:validation:XValidation:message="expected flavorSelectorTerms or project",rule="has(self.flavorSelectorTerms) || (has(self.project) && self.project)"
+// +kubebuilder:validation:XValidation:message="flavorSelectorTerms and project are mutually exclusive",rule="!has(self.flavorSelectorTerms) || !has(self.project) || !self.project"
+// +k8s:deepcopy-gen=true
+type Preemptible struct {
+	// FlavorSelectorTerms select the preemptible flavors among the flavors of the NodeClass. The
+	// terms are ORed. The flavors they select are only offered as spot capacity.
+	// +kubebuilder:validation:XValidation:message="expected at least one, got none, ['id', 'name', 'nameRegex', 'minVCPUs', 'maxVCPUs', 'minMemoryMiB', 'maxMemoryMiB', 'extraSpecs', 'exclude']",rule="self.all(x, has(x.id) || has(x.name) || has(x.nameRegex) || has(x.minVCPUs) || has(x.maxVCPUs) || has(x.minMemoryMiB) || has(x.maxMemoryMiB) || has(x.extraSpecs) || has(x.exclude))"
+	// +kubebuilder:validation:XValidation:message="'id' is mutually exclusive, cannot be set with a combination of other fields in flavorSelectorTerms",rule="!self.exists(x, has(x.id) && (has(x.name) || has(x.nameRegex) || has(x.minVCPUs) || has(x.maxVCPUs) || has(x.minMemoryMiB) || has(x.maxMemoryMiB) || has(x.extraSpecs) || has(x.exclude)))"
+	// +kubebuilder:validation:XValidation:message="'name' and 'nameRegex' are mutually exclusive",rule="!self.exists(x, has(x.name) && has(x.nameRegex))"
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=30
+	// +optional
+	FlavorSelectorTerms []OpenStackFlavorSelectorTerm `json:"flavorSelectorTerms,omitempty"`
+
+	// Project offers every flavor of the NodeClass as spot capacity as well, launched in the
+	// preemptible project the controller is configured with. The networks and security groups of
+	// the NodeClass must be shared with that project.
+	// +optional
+	Project bool `json:"project,omitempty"`
+
+	// PriceDiscount is the percentage taken off the on-demand price of a flavor to price its spot
+	// offerings.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	PriceDiscount int32 `json:"priceDiscount,omitempty"`
 }
 
+// OpenStackImageSelectorTerm selects Glance images. The fields of a term are ANDed.
 // +k8s:deepcopy-gen=true
 type OpenStackImageSelectorTerm struct {
 	// Alias specifies the image name or family in OpenStack Glance.
@@ -79,6 +139,64 @@ type OpenStackImageSelectorTerm struct {
 	// +kubebuilder:validation:MaxLength=160
 	// +optional
 	ID string `json:"id,omitempty"`
+
+	// Tags selects images carrying all of the given Glance tags.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// Properties selects images whose Glance properties match all of the given values.
+	// +optional
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// OpenStackFlavorSelectorTerm selects Nova flavors. The fields of a term are ANDed.
+// +kubebuilder:validation:XValidation:message="minVCPUs must be less than or equal to maxVCPUs",rule="!has(self.minVCPUs) || !has(self.maxVCPUs) || self.minVCPUs <= self.maxVCPUs"
+// +kubebuilder:validation:XValidation:message="minMemoryMiB must be less than or equal to maxMemoryMiB",rule="!has(self.minMemoryMiB) || !has(self.maxMemoryMiB) || self.minMemoryMiB <= self.maxMemoryMiB"
+// +k8s:deepcopy-gen=true
+type OpenStackFlavorSelectorTerm struct {
+	// ID specifies the exact Nova flavor ID.
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// Name selects flavors whose name matches the glob pattern, e.g. "m1.*".
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// NameRegex selects flavors whose whole name matches the regular expression.
+	// +kubebuilder:validation:MaxLength=255
+	// +optional
+	NameRegex string `json:"nameRegex,omitempty"`
+
+	// MinVCPUs selects flavors with at least this many vCPUs.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinVCPUs *int32 `json:"minVCPUs,omitempty"`
+
+	// MaxVCPUs selects flavors with at most this many vCPUs.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxVCPUs *int32 `json:"maxVCPUs,omitempty"`
+
+	// MinMemoryMiB selects flavors with at least this much RAM.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinMemoryMiB *int32 `json:"minMemoryMiB,omitempty"`
+
+	// MaxMemoryMiB selects flavors with at most this much RAM.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxMemoryMiB *int32 `json:"maxMemoryMiB,omitempty"`
+
+	// ExtraSpecs selects flavors whose extra specs match all of the given values.
+	// +optional
+	ExtraSpecs map[string]string `json:"extraSpecs,omitempty"`
+
+	// Exclude lists flavor IDs and name glob patterns that are never selected by this term.
+	// +kubebuilder:validation:MaxItems=100
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
@@ -155,9 +273,67 @@ type Disk struct {
 
 // +k8s:deepcopy-gen=true
 type OpenStackNodeClassStatus struct {
+	// Images contains the images resolved from ImageSelectorTerms, the newest image for each
+	// architecture and operating system first.
+	// +optional
+	Images []Image `json:"images,omitempty"`
+
+	// Flavors contains the flavors selected by FlavorSelectorTerms, sorted by name.
+	// +optional
+	Flavors []Flavor `json:"flavors,omitempty"`
+
+	// Quota is the Nova quota left to the project. Flavors that would exceed it are not launched.
+	// +optional
+	Quota *Quota `json:"quota,omitempty"`
+
 	Conditions []status.Condition `json:"conditions,omitempty"`
 }
 
+// Image is a Glance image resolved from the ImageSelectorTerms.
+// +k8s:deepcopy-gen=true
+type Image struct {
+	// ID of the Glance image.
+	ID string `json:"id"`
+
+	// Name of the Glance image.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Architecture is the kubernetes.io/arch value derived from the image's architecture property.
+	Architecture string `json:"architecture"`
+
+	// OS is the kubernetes.io/os value derived from the image's os_type property. Images resolved
+	// before it was introduced have none and are Linux images.
+	// +optional
+	OS string `json:"os,omitempty"`
+}
+
+// Flavor is a Nova flavor selected by the FlavorSelectorTerms.
+// +k8s:deepcopy-gen=true
+type Flavor struct {
+	// ID of the Nova flavor.
+	ID string `json:"id"`
+
+	// Name of the Nova flavor.
+	Name string `json:"name"`
+}
+
+// Quota is the Nova quota left to the project. Resources without quota are omitted.
+// +k8s:deepcopy-gen=true
+type Quota struct {
+	// Cores is the number of vCPUs left.
+	// +optional
+	Cores *int64 `json:"cores,omitempty"`
+
+	// RAMMiB is the RAM left, in MiB.
+	// +optional
+	RAMMiB *int64 `json:"ramMiB,omitempty"`
+
+	// Instances is the number of servers left.
+	// +optional
+	Instances *int64 `json:"instances,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 type OpenStackNodeClassList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -165,20 +341,43 @@ type OpenStackNodeClassList struct {
 	Items           []OpenStackNodeClass `json:"items"`
 }
 
+// Hash returns a static hash of the spec. Fields that are resolved against OpenStack at runtime
+// (imageSelectorTerms, flavorSelectorTerms, networks, securityGroups and preemptible) are excluded, as
+// drift on those is detected dynamically against the servers.
+func (in *OpenStackNodeClass) Hash() string {
+	return fmt.Sprint(lo.Must(hashstructure.Hash(in.Spec, hashstructure.FormatV2, &hashstructure.HashOptions{
+		SlicesAsSets:    true,
+		IgnoreZeroValue: true,
+		ZeroNil:         true,
+	})))
+}
+
+const (
+	ConditionTypeImagesReady         = "ImagesReady"
+	ConditionTypeNetworksReady       = "NetworksReady"
+	ConditionTypeSecurityGroupsReady = "SecurityGroupsReady"
+	ConditionTypeKeyPairReady        = "KeyPairReady"
+	ConditionTypeFlavorsAvailable    = "FlavorsAvailable"
+)
+
 // StatusConditions retorna um ConditionSet vinculado a este objeto.
-// "Ready" é a condição padrão que define se o objeto está saudável.
+// "Ready" é a condição padrão que define se o objeto está saudável; ela só fica True
+// quando todas as subcondições abaixo são True.
 func (in *OpenStackNodeClass) StatusConditions() status.ConditionSet {
-    // 1. NewReadyConditions("Ready") define a regra.
-    // 2. .For(in) vincula a regra a ESTA instância do objeto.
-    return status.NewReadyConditions("Ready").For(in)
+	return status.NewReadyConditions(
+		ConditionTypeImagesReady,
+		ConditionTypeNetworksReady,
+		ConditionTypeSecurityGroupsReady,
+		ConditionTypeKeyPairReady,
+		ConditionTypeFlavorsAvailable,
+	).For(in)
 }
 
 // MANTENHA ESTES DOIS ABAIXO (Obrigatórios para o .For() funcionar)
 func (in *OpenStackNodeClass) GetConditions() []status.Condition {
-    return in.Status.Conditions
+	return in.Status.Conditions
 }
 
 func (in *OpenStackNodeClass) SetConditions(conditions []status.Condition) {
-    in.Status.Conditions = conditions
+	in.Status.Conditions = conditions
 }
-
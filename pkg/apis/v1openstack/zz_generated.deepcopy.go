@@ -25,6 +25,36 @@ func (in *Disk) DeepCopy() *Disk {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Flavor) DeepCopyInto(out *Flavor) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Flavor.
+func (in *Flavor) DeepCopy() *Flavor {
+	if in == nil {
+		return nil
+	}
+	out := new(Flavor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Image) DeepCopyInto(out *Image) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Image.
+func (in *Image) DeepCopy() *Image {
+	if in == nil {
+		return nil
+	}
+	out := new(Image)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeletConfiguration) DeepCopyInto(out *KubeletConfiguration) {
 	*out = *in
@@ -110,9 +140,68 @@ func (in *KubeletConfiguration) DeepCopy() *KubeletConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenStackFlavorSelectorTerm) DeepCopyInto(out *OpenStackFlavorSelectorTerm) {
+	*out = *in
+	if in.MinVCPUs != nil {
+		in, out := &in.MinVCPUs, &out.MinVCPUs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxVCPUs != nil {
+		in, out := &in.MaxVCPUs, &out.MaxVCPUs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinMemoryMiB != nil {
+		in, out := &in.MinMemoryMiB, &out.MinMemoryMiB
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxMemoryMiB != nil {
+		in, out := &in.MaxMemoryMiB, &out.MaxMemoryMiB
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ExtraSpecs != nil {
+		in, out := &in.ExtraSpecs, &out.ExtraSpecs
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackFlavorSelectorTerm.
+func (in *OpenStackFlavorSelectorTerm) DeepCopy() *OpenStackFlavorSelectorTerm {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenStackFlavorSelectorTerm)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenStackImageSelectorTerm) DeepCopyInto(out *OpenStackImageSelectorTerm) {
 	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackImageSelectorTerm.
@@ -195,7 +284,16 @@ func (in *OpenStackNodeClassSpec) DeepCopyInto(out *OpenStackNodeClassSpec) {
 	if in.ImageSelectorTerms != nil {
 		in, out := &in.ImageSelectorTerms, &out.ImageSelectorTerms
 		*out = make([]OpenStackImageSelectorTerm, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FlavorSelectorTerms != nil {
+		in, out := &in.FlavorSelectorTerms, &out.FlavorSelectorTerms
+		*out = make([]OpenStackFlavorSelectorTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Networks != nil {
 		in, out := &in.Networks, &out.Networks
@@ -226,6 +324,11 @@ func (in *OpenStackNodeClassSpec) DeepCopyInto(out *OpenStackNodeClassSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Preemptible != nil {
+		in, out := &in.Preemptible, &out.Preemptible
+		*out = new(Preemptible)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackNodeClassSpec.
@@ -241,6 +344,21 @@ func (in *OpenStackNodeClassSpec) DeepCopy() *OpenStackNodeClassSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenStackNodeClassStatus) DeepCopyInto(out *OpenStackNodeClassStatus) {
 	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]Image, len(*in))
+		copy(*out, *in)
+	}
+	if in.Flavors != nil {
+		in, out := &in.Flavors, &out.Flavors
+		*out = make([]Flavor, len(*in))
+		copy(*out, *in)
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(Quota)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]status.Condition, len(*in))
@@ -259,3 +377,55 @@ func (in *OpenStackNodeClassStatus) DeepCopy() *OpenStackNodeClassStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Preemptible) DeepCopyInto(out *Preemptible) {
+	*out = *in
+	if in.FlavorSelectorTerms != nil {
+		in, out := &in.FlavorSelectorTerms, &out.FlavorSelectorTerms
+		*out = make([]OpenStackFlavorSelectorTerm, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Preemptible.
+func (in *Preemptible) DeepCopy() *Preemptible {
+	if in == nil {
+		return nil
+	}
+	out := new(Preemptible)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Quota) DeepCopyInto(out *Quota) {
+	*out = *in
+	if in.Cores != nil {
+		in, out := &in.Cores, &out.Cores
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RAMMiB != nil {
+		in, out := &in.RAMMiB, &out.RAMMiB
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Quota.
+func (in *Quota) DeepCopy() *Quota {
+	if in == nil {
+		return nil
+	}
+	out := new(Quota)
+	in.DeepCopyInto(out)
+	return out
+}
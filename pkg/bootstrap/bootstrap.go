@@ -0,0 +1,303 @@
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/karpenter/pkg/apis"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+const (
+	// Boundary separates the parts of the generated multipart user data.
+	Boundary = "//"
+
+	joinConfigPath = "/etc/kubernetes/kubeadm-join.yaml"
+	// mergeHow makes cloud-init append the lists of the generated cloud-config, e.g. runcmd, to the
+	// ones of the user's cloud-config instead of replacing them.
+	mergeHow = "list(append)+dict(recurse_array)+str()"
+)
+
+// ClusterConfig holds what a node needs to discover the cluster and join it with kubeadm.
+type ClusterConfig struct {
+	// APIServerEndpoint is the host:port of the Kubernetes API server.
+	APIServerEndpoint string
+	// Token is a kubeadm bootstrap token, in the abcdef.0123456789abcdef format.
+	Token string
+	// CACertHashes pin the cluster CA, in the sha256:<hex> format printed by kubeadm.
+	CACertHashes []string
+}
+
+// Options describes the node being bootstrapped.
+type Options struct {
+	ClusterConfig
+
+	KubeletConfiguration *v1openstack.KubeletConfiguration
+	// KubeReserved is the kube-reserved the allocatable of the instance type was computed with: the
+	// defaults of its capacity, overridden by the KubeletConfiguration. It is passed to the kubelet
+	// in place of the KubeletConfiguration one, so that the node reserves what Karpenter expects.
+	KubeReserved  corev1.ResourceList
+	Taints        []corev1.Taint
+	StartupTaints []corev1.Taint
+	Labels        map[string]string
+	// CustomUserData is the user data of the NodeClass. It runs before the node joins the cluster.
+	CustomUserData string
+}
+
+// UserData renders the multipart MIME user data that joins the node to the cluster with kubeadm.
+// The user's own user data is kept as separate parts, in front of the generated cloud-config.
+func UserData(opts Options) (string, error) {
+	customParts, err := customUserDataParts(opts.CustomUserData)
+	if err != nil {
+		return "", fmt.Errorf("parsing custom user data: %w", err)
+	}
+	cloudConfig, err := opts.cloudConfig()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%q\r\n\r\n", Boundary)
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(Boundary); err != nil {
+		return "", err
+	}
+	for _, p := range append(customParts, part{contentType: "text/cloud-config", content: cloudConfig}) {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {fmt.Sprintf("%s; charset=%q", p.contentType, "us-ascii")}})
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.WriteString(w, p.content); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type part struct {
+	contentType string
+	content     string
+}
+
+// customUserDataParts splits the user data into MIME parts. Multipart user data keeps its parts,
+// anything else becomes a single part typed after its first line.
+func customUserDataParts(userData string) ([]part, error) {
+	if strings.TrimSpace(userData) == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(userData, "MIME-Version:") && !strings.HasPrefix(userData, "Content-Type:") {
+		return []part{{contentType: contentType(userData), content: userData}}, nil
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(msg.Body)
+		if err != nil {
+			return nil, err
+		}
+		return []part{{contentType: mediaType, content: string(body)}}, nil
+	}
+	var parts []part
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		partType, _, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if err != nil {
+			partType = contentType(string(body))
+		}
+		parts = append(parts, part{contentType: partType, content: string(body)})
+	}
+}
+
+// contentType maps the user data to the cloud-init part handler for it. cloud-init guesses the
+// handler of text/plain parts from their first line.
+func contentType(userData string) string {
+	switch {
+	case strings.HasPrefix(userData, "#cloud-config"):
+		return "text/cloud-config"
+	case strings.HasPrefix(userData, "#!"):
+		return "text/x-shellscript"
+	default:
+		return "text/plain"
+	}
+}
+
+type cloudConfig struct {
+	MergeHow   string      `json:"merge_how"`
+	WriteFiles []writeFile `json:"write_files"`
+	RunCmd     [][]string  `json:"runcmd"`
+}
+
+type writeFile struct {
+	Path        string `json:"path"`
+	Permissions string `json:"permissions"`
+	Content     string `json:"content"`
+}
+
+// joinConfiguration is the subset of the kubeadm.k8s.io/v1beta3 JoinConfiguration used by nodes.
+type joinConfiguration struct {
+	APIVersion       string           `json:"apiVersion"`
+	Kind             string           `json:"kind"`
+	Discovery        discovery        `json:"discovery"`
+	NodeRegistration nodeRegistration `json:"nodeRegistration"`
+}
+
+type discovery struct {
+	BootstrapToken bootstrapTokenDiscovery `json:"bootstrapToken"`
+}
+
+type bootstrapTokenDiscovery struct {
+	APIServerEndpoint string   `json:"apiServerEndpoint"`
+	Token             string   `json:"token"`
+	CACertHashes      []string `json:"caCertHashes"`
+}
+
+type nodeRegistration struct {
+	KubeletExtraArgs map[string]string `json:"kubeletExtraArgs,omitempty"`
+	// Taints are always sent, kubeadm would otherwise apply its defaults.
+	Taints []corev1.Taint `json:"taints"`
+}
+
+func (o Options) cloudConfig() (string, error) {
+	joinConfig, err := yaml.Marshal(joinConfiguration{
+		APIVersion: "kubeadm.k8s.io/v1beta3",
+		Kind:       "JoinConfiguration",
+		Discovery: discovery{BootstrapToken: bootstrapTokenDiscovery{
+			APIServerEndpoint: o.APIServerEndpoint,
+			Token:             o.Token,
+			CACertHashes:      o.CACertHashes,
+		}},
+		NodeRegistration: nodeRegistration{
+			KubeletExtraArgs: o.kubeletExtraArgs(),
+			Taints:           o.taints(),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering kubeadm join configuration: %w", err)
+	}
+	config, err := yaml.Marshal(cloudConfig{
+		MergeHow:   mergeHow,
+		WriteFiles: []writeFile{{Path: joinConfigPath, Permissions: "0600", Content: string(joinConfig)}},
+		RunCmd:     [][]string{{"kubeadm", "join", "--config", joinConfigPath}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("rendering cloud-config: %w", err)
+	}
+	return "#cloud-config\n" + string(config), nil
+}
+
+// taints registers the node with its NodePool taints and startup taints, so that nothing is
+// scheduled before Karpenter and the startup controllers have seen the node.
+func (o Options) taints() []corev1.Taint {
+	return lo.UniqBy(append(append([]corev1.Taint{}, o.Taints...), o.StartupTaints...), func(t corev1.Taint) string {
+		return t.Key + ":" + string(t.Effect)
+	})
+}
+
+func (o Options) kubeletExtraArgs() map[string]string {
+	args := map[string]string{
+		// The node's providerID is set by the OpenStack cloud controller manager.
+		"cloud-provider": "external",
+	}
+	if labels := nodeLabels(o.Labels); labels != "" {
+		args["node-labels"] = labels
+	}
+	if len(o.KubeReserved) > 0 {
+		args["kube-reserved"] = joinMap(lo.MapEntries(o.KubeReserved, func(name corev1.ResourceName, quantity resource.Quantity) (string, string) {
+			return string(name), quantity.String()
+		}), "=")
+	}
+	kc := o.KubeletConfiguration
+	if kc == nil {
+		return args
+	}
+	if len(kc.ClusterDNS) > 0 {
+		args["cluster-dns"] = strings.Join(kc.ClusterDNS, ",")
+	}
+	if kc.MaxPods != nil {
+		args["max-pods"] = strconv.Itoa(int(*kc.MaxPods))
+	}
+	if kc.PodsPerCore != nil {
+		args["pods-per-core"] = strconv.Itoa(int(*kc.PodsPerCore))
+	}
+	if len(kc.SystemReserved) > 0 {
+		args["system-reserved"] = joinMap(kc.SystemReserved, "=")
+	}
+	if len(kc.KubeReserved) > 0 && len(o.KubeReserved) == 0 {
+		args["kube-reserved"] = joinMap(kc.KubeReserved, "=")
+	}
+	if len(kc.EvictionHard) > 0 {
+		args["eviction-hard"] = joinMap(kc.EvictionHard, "<")
+	}
+	if len(kc.EvictionSoft) > 0 {
+		args["eviction-soft"] = joinMap(kc.EvictionSoft, "<")
+	}
+	if len(kc.EvictionSoftGracePeriod) > 0 {
+		args["eviction-soft-grace-period"] = joinMap(lo.MapValues(kc.EvictionSoftGracePeriod, func(d metav1.Duration, _ string) string {
+			return d.Duration.String()
+		}), "=")
+	}
+	if kc.EvictionMaxPodGracePeriod != nil {
+		args["eviction-max-pod-grace-period"] = strconv.Itoa(int(*kc.EvictionMaxPodGracePeriod))
+	}
+	if kc.ImageGCHighThresholdPercent != nil {
+		args["image-gc-high-threshold"] = strconv.Itoa(int(*kc.ImageGCHighThresholdPercent))
+	}
+	if kc.ImageGCLowThresholdPercent != nil {
+		args["image-gc-low-threshold"] = strconv.Itoa(int(*kc.ImageGCLowThresholdPercent))
+	}
+	if kc.CPUCFSQuota != nil {
+		args["cpu-cfs-quota"] = strconv.FormatBool(*kc.CPUCFSQuota)
+	}
+	return args
+}
+
+// nodeLabels returns the --node-labels value. Labels in the kubernetes.io and k8s.io domains are
+// rejected by the kubelet and are left to the cloud controller manager and to Karpenter.
+func nodeLabels(labels map[string]string) string {
+	return joinMap(lo.OmitBy(labels, func(key, _ string) bool {
+		return karpv1.IsRestrictedNodeLabel(key) && karpv1.GetLabelDomain(key) != apis.Group
+	}), "=")
+}
+
+// joinMap renders a map as a comma separated list of key<sep>value pairs, sorted by key.
+func joinMap(m map[string]string, sep string) string {
+	keys := lo.Keys(m)
+	sort.Strings(keys)
+	return strings.Join(lo.Map(keys, func(key string, _ int) string { return key + sep + m[key] }), ",")
+}
@@ -0,0 +1,196 @@
+package bootstrap
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+func newTestOptions() Options {
+	return Options{
+		ClusterConfig: ClusterConfig{
+			APIServerEndpoint: "10.0.0.10:6443",
+			Token:             "abcdef.0123456789abcdef",
+			CACertHashes:      []string{"sha256:0123"},
+		},
+	}
+}
+
+// parseUserData returns the content type and body of every part of the multipart user data.
+func parseUserData(t *testing.T, userData string) []part {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatalf("invalid user data: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed user data, got %q: %v", mediaType, err)
+	}
+	var parts []part
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("invalid part: %v", err)
+		}
+		body, _ := io.ReadAll(p)
+		contentType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts = append(parts, part{contentType: contentType, content: string(body)})
+	}
+}
+
+func parseJoinConfiguration(t *testing.T, p part) joinConfiguration {
+	t.Helper()
+	if p.contentType != "text/cloud-config" || !strings.HasPrefix(p.content, "#cloud-config\n") {
+		t.Fatalf("expected the generated cloud-config last, got %s:\n%s", p.contentType, p.content)
+	}
+	var config cloudConfig
+	if err := yaml.Unmarshal([]byte(p.content), &config); err != nil {
+		t.Fatalf("invalid cloud-config: %v", err)
+	}
+	if len(config.WriteFiles) != 1 || len(config.RunCmd) != 1 || strings.Join(config.RunCmd[0], " ") != "kubeadm join --config "+joinConfigPath {
+		t.Fatalf("unexpected cloud-config:\n%s", p.content)
+	}
+	var joinConfig joinConfiguration
+	if err := yaml.Unmarshal([]byte(config.WriteFiles[0].Content), &joinConfig); err != nil {
+		t.Fatalf("invalid join configuration: %v", err)
+	}
+	return joinConfig
+}
+
+func TestUserDataJoinConfiguration(t *testing.T) {
+	opts := newTestOptions()
+	opts.KubeletConfiguration = &v1openstack.KubeletConfiguration{
+		ClusterDNS:                  []string{"10.96.0.10", "10.96.0.11"},
+		MaxPods:                     lo.ToPtr(int32(58)),
+		SystemReserved:              map[string]string{"memory": "200Mi", "cpu": "100m"},
+		KubeReserved:                map[string]string{"cpu": "200m"},
+		EvictionHard:                map[string]string{"memory.available": "5%"},
+		EvictionSoft:                map[string]string{"nodefs.available": "15%"},
+		EvictionSoftGracePeriod:     map[string]metav1.Duration{"nodefs.available": {Duration: time.Minute}},
+		ImageGCHighThresholdPercent: lo.ToPtr(int32(85)),
+		ImageGCLowThresholdPercent:  lo.ToPtr(int32(80)),
+	}
+	// The kube-reserved of the instance type overhead includes the one of the KubeletConfiguration.
+	opts.KubeReserved = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("1843Mi")}
+	opts.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	opts.StartupTaints = []corev1.Taint{
+		{Key: "node.cilium.io/agent-not-ready", Effect: corev1.TaintEffectNoExecute},
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+	}
+	opts.Labels = map[string]string{
+		"team":                       "platform",
+		karpv1.NodePoolLabelKey:      "general",
+		corev1.LabelTopologyZone:     "nova",
+		"node.kubernetes.io/role":    "worker",
+		"kubernetes.io/custom-label": "rejected",
+	}
+
+	userData, err := UserData(opts)
+	if err != nil {
+		t.Fatalf("UserData failed: %v", err)
+	}
+	parts := parseUserData(t, userData)
+	if len(parts) != 1 {
+		t.Fatalf("expected a single part, got %d", len(parts))
+	}
+	joinConfig := parseJoinConfiguration(t, parts[0])
+
+	expectedDiscovery := bootstrapTokenDiscovery{APIServerEndpoint: "10.0.0.10:6443", Token: "abcdef.0123456789abcdef", CACertHashes: []string{"sha256:0123"}}
+	if !reflect.DeepEqual(joinConfig.Discovery.BootstrapToken, expectedDiscovery) {
+		t.Errorf("unexpected discovery: %+v", joinConfig.Discovery.BootstrapToken)
+	}
+	expectedArgs := map[string]string{
+		"cloud-provider":             "external",
+		"node-labels":                "karpenter.sh/nodepool=general,node.kubernetes.io/role=worker,team=platform",
+		"cluster-dns":                "10.96.0.10,10.96.0.11",
+		"max-pods":                   "58",
+		"system-reserved":            "cpu=100m,memory=200Mi",
+		"kube-reserved":              "cpu=200m,memory=1843Mi",
+		"eviction-hard":              "memory.available<5%",
+		"eviction-soft":              "nodefs.available<15%",
+		"eviction-soft-grace-period": "nodefs.available=1m0s",
+		"image-gc-high-threshold":    "85",
+		"image-gc-low-threshold":     "80",
+	}
+	if !reflect.DeepEqual(joinConfig.NodeRegistration.KubeletExtraArgs, expectedArgs) {
+		t.Errorf("unexpected kubelet args:\nexpected: %v\ngot:      %v", expectedArgs, joinConfig.NodeRegistration.KubeletExtraArgs)
+	}
+	expectedTaints := []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node.cilium.io/agent-not-ready", Effect: corev1.TaintEffectNoExecute},
+	}
+	if !reflect.DeepEqual(joinConfig.NodeRegistration.Taints, expectedTaints) {
+		t.Errorf("unexpected taints: %v", joinConfig.NodeRegistration.Taints)
+	}
+}
+
+func TestUserDataMergesCustomUserData(t *testing.T) {
+	multipartUserData := "MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"==BOUNDARY==\"\r\n\r\n" +
+		"--==BOUNDARY==\r\nContent-Type: text/x-shellscript\r\n\r\n#!/bin/bash\necho first\r\n" +
+		"--==BOUNDARY==\r\nContent-Type: text/cloud-config\r\n\r\n#cloud-config\npackages: [jq]\r\n" +
+		"--==BOUNDARY==--\r\n"
+
+	tests := []struct {
+		name     string
+		userData string
+		expected []part
+	}{
+		{
+			name:     "shell script",
+			userData: "#!/bin/bash\necho 'hello world'",
+			expected: []part{{contentType: "text/x-shellscript", content: "#!/bin/bash\necho 'hello world'"}},
+		},
+		{
+			name:     "cloud-config",
+			userData: "#cloud-config\nruncmd:\n- echo hello",
+			expected: []part{{contentType: "text/cloud-config", content: "#cloud-config\nruncmd:\n- echo hello"}},
+		},
+		{
+			name:     "multipart",
+			userData: multipartUserData,
+			expected: []part{
+				{contentType: "text/x-shellscript", content: "#!/bin/bash\necho first"},
+				{contentType: "text/cloud-config", content: "#cloud-config\npackages: [jq]"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := newTestOptions()
+			opts.CustomUserData = tc.userData
+
+			userData, err := UserData(opts)
+			if err != nil {
+				t.Fatalf("UserData failed: %v", err)
+			}
+			parts := parseUserData(t, userData)
+			if len(parts) != len(tc.expected)+1 {
+				t.Fatalf("expected %d parts, got %d", len(tc.expected)+1, len(parts))
+			}
+			if !reflect.DeepEqual(parts[:len(tc.expected)], tc.expected) {
+				t.Errorf("unexpected custom parts:\nexpected: %q\ngot:      %q", tc.expected, parts[:len(tc.expected)])
+			}
+			parseJoinConfiguration(t, parts[len(parts)-1])
+		})
+	}
+}
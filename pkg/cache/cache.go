@@ -0,0 +1,11 @@
+package cache
+
+import "time"
+
+const (
+	// ImageTTL is how long the images resolved from a set of ImageSelectorTerms are reused before
+	// Glance is queried again. New images are picked up, at most, this long after being published.
+	ImageTTL = 5 * time.Minute
+	// DefaultCleanupInterval triggers cache cleanup (lazy eviction) at this interval.
+	DefaultCleanupInterval = 10 * time.Minute
+)
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// UnavailableOfferingsTTL is how long an offering is left out after Nova failed to place it.
+// Capacity frees up as servers are deleted, so the offering is tried again once it expires.
+const UnavailableOfferingsTTL = 3 * time.Minute
+
+// UnavailableOfferings remembers the offerings Nova recently had no capacity for, so that the next
+// launches go to other flavors and zones rather than to the same full aggregate.
+type UnavailableOfferings struct {
+	cache *cache.Cache
+}
+
+func NewUnavailableOfferings() *UnavailableOfferings {
+	return &UnavailableOfferings{cache: cache.New(UnavailableOfferingsTTL, DefaultCleanupInterval)}
+}
+
+// MarkUnavailable records that the flavor can't be launched in the zone with the capacity type. The
+// zone is empty when Nova picked it.
+func (u *UnavailableOfferings) MarkUnavailable(ctx context.Context, reason, flavor, zone, capacityType string) {
+	log.FromContext(ctx).V(1).Info("marking offering unavailable", "reason", reason, "flavor", flavor, "zone", zone, "capacityType", capacityType, "ttl", UnavailableOfferingsTTL)
+	u.cache.SetDefault(offeringKey(flavor, zone, capacityType), struct{}{})
+}
+
+// IsUnavailable reports whether the offering was marked unavailable and hasn't expired yet.
+func (u *UnavailableOfferings) IsUnavailable(flavor, zone, capacityType string) bool {
+	_, found := u.cache.Get(offeringKey(flavor, zone, capacityType))
+	return found
+}
+
+// Flush forgets every unavailable offering.
+func (u *UnavailableOfferings) Flush() {
+	u.cache.Flush()
+}
+
+func offeringKey(flavor, zone, capacityType string) string {
+	return fmt.Sprintf("%s:%s:%s", capacityType, flavor, zone)
+}
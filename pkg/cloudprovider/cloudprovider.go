@@ -6,15 +6,20 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/awslabs/operatorpkg/option"
 	"github.com/awslabs/operatorpkg/status"
+	"github.com/gophercloud/gophercloud"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/utils"
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
@@ -31,6 +36,7 @@ type CloudProvider struct {
 
 	instanceTypeProvider instancetype.Provider
 	instanceProvider     instance.Provider
+	networkClient        *gophercloud.ServiceClient
 }
 
 func (c *CloudProvider) GetSupportedNodeClasses() []status.Object {
@@ -38,12 +44,13 @@ func (c *CloudProvider) GetSupportedNodeClasses() []status.Object {
 }
 
 func New(kubeClient client.Client, recorder events.Recorder,
-	instanceProvider instance.Provider, instanceTypeProvider instancetype.Provider) *CloudProvider {
+	instanceProvider instance.Provider, instanceTypeProvider instancetype.Provider, networkClient *gophercloud.ServiceClient) *CloudProvider {
 	return &CloudProvider{
 		kubeClient:           kubeClient,
 		recorder:             recorder,
 		instanceProvider:     instanceProvider,
 		instanceTypeProvider: instanceTypeProvider,
+		networkClient:        networkClient,
 	}
 }
 
@@ -52,6 +59,9 @@ func (c *CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	if err != nil {
 		return nil, err
 	}
+	if ready := nodeClass.StatusConditions().Get(status.ConditionReady); !ready.IsTrue() {
+		return nil, cloudprovider.NewNodeClassNotReadyError(fmt.Errorf("NodeClass %s is not ready: %s", nodeClass.Name, ready.Message))
+	}
 
 	instancetypes, err := c.resolveInstanceTypes(ctx, nodeClaim, nodeClass)
 	if err != nil {
@@ -69,10 +79,14 @@ func (c *CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	}
 
 	instanceType, _ := lo.Find(instancetypes, func(it *cloudprovider.InstanceType) bool {
-		return it.Name == instance.Type
+		return isInstanceTypeOf(it, instance)
 	})
 
 	nc := c.instanceToNodeClaim(instance, instanceType)
+	nc.Annotations = lo.Assign(nc.Annotations, map[string]string{
+		v1openstack.AnnotationOpenStackNodeClassHash:        nodeClass.Annotations[v1openstack.AnnotationOpenStackNodeClassHash],
+		v1openstack.AnnotationOpenStackNodeClassHashVersion: nodeClass.Annotations[v1openstack.AnnotationOpenStackNodeClassHashVersion],
+	})
 
 	return nc, nil
 }
@@ -84,12 +98,22 @@ func (c *CloudProvider) resolveInstanceTypes(ctx context.Context, nodeClaim *kar
 
 	reqs := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...)
 	return lo.Filter(instanceTypes, func(i *cloudprovider.InstanceType, _ int) bool {
-		return reqs.Compatible(i.Requirements, scheduling.AllowUndefinedWellKnownLabels) == nil &&
+		return reqs.Compatible(i.Requirements, allowUndefinedFlavorLabels(i)) == nil &&
 			len(i.Offerings.Compatible(reqs).Available()) > 0 &&
 			resources.Fits(nodeClaim.Spec.Resources.Requests, i.Allocatable())
 	}), nil
 }
 
+// allowUndefinedFlavorLabels lets the NodeClaim leave undefined the well-known labels and the labels
+// derived from the flavor extra specs, whose keys are only known once the flavors are listed.
+func allowUndefinedFlavorLabels(instanceType *cloudprovider.InstanceType) option.Function[scheduling.CompatibilityOptions] {
+	return func(opts *scheduling.CompatibilityOptions) {
+		opts.AllowUndefined = karpv1.WellKnownLabels.Union(sets.New(lo.Filter(instanceType.Requirements.Keys().UnsortedList(), func(key string, _ int) bool {
+			return strings.HasPrefix(key, v1openstack.GroupName+"/")
+		})...))
+	}
+}
+
 func (c *CloudProvider) resolveNodeClassFromNodeClaim(ctx context.Context, nodeClaim *karpv1.NodeClaim) (*v1openstack.OpenStackNodeClass, error) {
 	ref := nodeClaim.Spec.NodeClassRef
 	if ref == nil {
@@ -116,9 +140,31 @@ func (c *CloudProvider) instanceToNodeClaim(instance *instance.Instance, instanc
 		nodeClaim.Status.Allocatable = lo.PickBy(instanceType.Allocatable(), resourceFilter)
 	}
 
-	labels["instance-type"] = instance.Type
+	if _, ok := labels[corev1.LabelInstanceTypeStable]; !ok && instance.Type != "" {
+		labels[corev1.LabelInstanceTypeStable] = instance.Type
+	}
+	if instance.Zone != "" {
+		labels[corev1.LabelTopologyZone] = instance.Zone
+	}
+	// The instance type offers several capacity types and reservations, the server runs in one.
+	delete(labels, v1openstack.LabelReservationID)
+	delete(labels, v1openstack.LabelReservationType)
+	if instance.CapacityType != "" {
+		labels[karpv1.CapacityTypeLabelKey] = instance.CapacityType
+	}
+	if instance.ReservationID != "" {
+		labels[v1openstack.LabelReservationID] = instance.ReservationID
+		labels[v1openstack.LabelReservationType] = instance.ReservationType
+	}
+	if nodePool, ok := instance.Metadata[v1openstack.NodePoolMetadataKey]; ok {
+		labels[karpv1.NodePoolLabelKey] = nodePool
+	}
+	if instance.FloatingIP != "" {
+		annotations[v1openstack.AnnotationFloatingIP] = instance.FloatingIP
+	}
 
 	nodeClaim.ObjectMeta.Name = instance.Name
+	nodeClaim.ObjectMeta.CreationTimestamp = metav1.Time{Time: instance.CreationTime}
 	nodeClaim.ObjectMeta.Labels = labels
 	nodeClaim.ObjectMeta.Annotations = annotations
 
@@ -140,6 +186,9 @@ func parseOSProviderID(providerID string) (serverID string, err error) {
 	}
 
 	serverID = strings.TrimPrefix(providerID, prefix)
+	if serverID == "" {
+		return "", fmt.Errorf("invalid OpenStack providerID, expected format openstack:///serverID")
+	}
 
 	return serverID, nil
 }
@@ -161,11 +210,84 @@ func (c *CloudProvider) Delete(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 }
 
 func (c *CloudProvider) List(ctx context.Context) ([]*karpv1.NodeClaim, error) {
-	return nil, nil
+	instances, err := c.instanceProvider.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing instances: %w", err)
+	}
+
+	instanceTypesByNodeClass := map[string][]*cloudprovider.InstanceType{}
+	var nodeClaims []*karpv1.NodeClaim
+	for _, instance := range instances {
+		instanceType, err := c.resolveInstanceTypeFromInstance(ctx, instance, instanceTypesByNodeClass)
+		if err != nil {
+			return nil, fmt.Errorf("resolving instance type for %s: %w", instance.InstanceID, err)
+		}
+		nodeClaims = append(nodeClaims, c.instanceToNodeClaim(instance, instanceType))
+	}
+	return nodeClaims, nil
+}
+
+// resolveInstanceTypeFromInstance looks up the instance type of a server through the NodeClass it
+// was launched with. A nil instance type is returned when the NodeClass no longer exists, so that
+// orphaned servers are still reported and can be garbage collected.
+func (c *CloudProvider) resolveInstanceTypeFromInstance(ctx context.Context, instance *instance.Instance, cache map[string][]*cloudprovider.InstanceType) (*cloudprovider.InstanceType, error) {
+	nodeClassName, ok := instance.Metadata[v1openstack.NodeClassMetadataKey]
+	if !ok {
+		return nil, nil
+	}
+
+	instanceTypes, ok := cache[nodeClassName]
+	if !ok {
+		nodeClass := &v1openstack.OpenStackNodeClass{}
+		if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClassName}, nodeClass); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		var err error
+		instanceTypes, err = c.instanceTypeProvider.List(ctx, nodeClass)
+		if err != nil {
+			return nil, fmt.Errorf("listing instance types: %w", err)
+		}
+		cache[nodeClassName] = instanceTypes
+	}
+
+	instanceType, _ := lo.Find(instanceTypes, func(it *cloudprovider.InstanceType) bool {
+		return isInstanceTypeOf(it, instance)
+	})
+	return instanceType, nil
+}
+
+// isInstanceTypeOf reports whether the instance type is the flavor of the instance. Instance types
+// are named after their flavor, or after its ID when flavors share the name, yet Nova only reports the
+// flavor ID of servers before microversion 2.47. The servers of a Blazar instance reservation run the flavor Blazar created for it, and
+// belong to the instance type offering the reservation.
+func isInstanceTypeOf(instanceType *cloudprovider.InstanceType, instance *instance.Instance) bool {
+	if instanceType.Name == instance.Type {
+		return true
+	}
+	if instance.ReservationType == v1openstack.ReservationTypeInstance {
+		reservationID := instanceType.Requirements.Get(v1openstack.LabelReservationID)
+		return reservationID.Operator() == corev1.NodeSelectorOpIn && reservationID.Has(instance.ReservationID)
+	}
+	flavorID := instanceType.Requirements.Get(v1openstack.LabelFlavorID)
+	return instance.FlavorID != "" && flavorID.Operator() == corev1.NodeSelectorOpIn && flavorID.Has(instance.FlavorID)
 }
 
 func (c *CloudProvider) Get(ctx context.Context, providerID string) (*karpv1.NodeClaim, error) {
-	return nil, nil
+	instanceID, err := parseOSProviderID(providerID)
+	if err != nil {
+		return nil, fmt.Errorf("parsing provider ID: %w", err)
+	}
+
+	instance, err := c.instanceProvider.Get(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("getting instance: %w", err)
+	}
+
+	instanceType, err := c.resolveInstanceTypeFromInstance(ctx, instance, map[string][]*cloudprovider.InstanceType{})
+	if err != nil {
+		return nil, fmt.Errorf("resolving instance type for %s: %w", instance.InstanceID, err)
+	}
+	return c.instanceToNodeClaim(instance, instanceType), nil
 }
 
 func (c *CloudProvider) GetInstanceTypes(ctx context.Context, nodePool *karpv1.NodePool) ([]*cloudprovider.InstanceType, error) {
@@ -183,7 +305,18 @@ func (c *CloudProvider) GetInstanceTypes(ctx context.Context, nodePool *karpv1.N
 }
 
 func (c *CloudProvider) IsDrifted(ctx context.Context, nodeClaim *karpv1.NodeClaim) (cloudprovider.DriftReason, error) {
-	return "", nil
+	nodeClass, err := c.resolveNodeClassFromNodeClaim(ctx, nodeClaim)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	driftReason, err := c.isDrifted(ctx, nodeClaim, nodeClass)
+	if err != nil {
+		return "", err
+	}
+	return driftReason, nil
 }
 
 func (c *CloudProvider) RepairPolicies() []cloudprovider.RepairPolicy {
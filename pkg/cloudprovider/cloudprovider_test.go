@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/awslabs/operatorpkg/status"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
@@ -58,7 +59,7 @@ func createRealComputeClient(t *testing.T) *gophercloud.ServiceClient {
 
 func TestCloudProviderCreate_Integration(t *testing.T) {
 	err := godotenv.Load("../../.env")
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		t.Fatalf("Erro ao carregar .env: %v", err)
 	}
 	if os.Getenv("RUN_INTEGRATION_TESTS") == "" {
@@ -108,7 +109,11 @@ func TestCloudProviderCreate_Integration(t *testing.T) {
 			ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{ID: imageID}},
 			UserData:           "#!/bin/bash",
 		},
+		Status: v1openstack.OpenStackNodeClassStatus{
+			Images: []v1openstack.Image{{ID: imageID, Architecture: "amd64"}},
+		},
 	}
+	nodeClass.StatusConditions().SetTrue(status.ConditionReady)
 
 	//Objeto NodeClaim que será passado para a função Create
 	nodeClaim := &karpv1.NodeClaim{
@@ -142,7 +147,7 @@ func TestCloudProviderCreate_Integration(t *testing.T) {
 		InstanceTypesInfo: flavorsList,
 	}
 
-	realInstanceProvider := instance.NewProvider(realComputeClient, "test-cluster")
+	realInstanceProvider := instance.NewProvider(realComputeClient, nil, nil, "test-cluster", nil, nil)
 
 	// Configurar o fake KubeClient
 	scheme := runtime.NewScheme()
@@ -0,0 +1,175 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+const (
+	NodeClassDrift     cloudprovider.DriftReason = "NodeClassDrift"
+	ImageDrift         cloudprovider.DriftReason = "ImageDrift"
+	NetworkDrift       cloudprovider.DriftReason = "NetworkDrift"
+	SecurityGroupDrift cloudprovider.DriftReason = "SecurityGroupDrift"
+	FlavorDrift        cloudprovider.DriftReason = "FlavorDrift"
+	CapacityTypeDrift  cloudprovider.DriftReason = "CapacityTypeDrift"
+)
+
+func (c *CloudProvider) isDrifted(ctx context.Context, nodeClaim *karpv1.NodeClaim, nodeClass *v1openstack.OpenStackNodeClass) (cloudprovider.DriftReason, error) {
+	if drifted := c.isNodeClassDrifted(nodeClaim, nodeClass); drifted != "" {
+		return drifted, nil
+	}
+
+	instanceID, err := parseOSProviderID(nodeClaim.Status.ProviderID)
+	if err != nil {
+		return "", fmt.Errorf("parsing provider ID: %w", err)
+	}
+	instance, err := c.instanceProvider.Get(ctx, instanceID)
+	if err != nil {
+		// The server is gone, the lifecycle controller takes care of the NodeClaim.
+		if cloudprovider.IsNodeClaimNotFoundError(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("getting instance: %w", err)
+	}
+	if drifted := c.isImageDrifted(nodeClaim, instance, nodeClass); drifted != "" {
+		return drifted, nil
+	}
+	if drifted := c.areNetworksDrifted(instance, nodeClass); drifted != "" {
+		return drifted, nil
+	}
+	if drifted, err := c.areSecurityGroupsDrifted(instance, nodeClass); err != nil || drifted != "" {
+		return drifted, err
+	}
+	if drifted, err := c.isFlavorDrifted(ctx, instance, nodeClass); err != nil || drifted != "" {
+		return drifted, err
+	}
+	return c.isCapacityTypeDrifted(ctx, instance, nodeClass)
+}
+
+// isNodeClassDrifted compares the static spec hash stamped on the NodeClaim at launch with the
+// current one. NodeClaims launched with a different hash version are never considered drifted.
+func (c *CloudProvider) isNodeClassDrifted(nodeClaim *karpv1.NodeClaim, nodeClass *v1openstack.OpenStackNodeClass) cloudprovider.DriftReason {
+	nodeClassHash, foundNodeClassHash := nodeClass.Annotations[v1openstack.AnnotationOpenStackNodeClassHash]
+	nodeClassHashVersion, foundNodeClassHashVersion := nodeClass.Annotations[v1openstack.AnnotationOpenStackNodeClassHashVersion]
+	nodeClaimHash, foundNodeClaimHash := nodeClaim.Annotations[v1openstack.AnnotationOpenStackNodeClassHash]
+	nodeClaimHashVersion, foundNodeClaimHashVersion := nodeClaim.Annotations[v1openstack.AnnotationOpenStackNodeClassHashVersion]
+
+	if !foundNodeClassHash || !foundNodeClaimHash || !foundNodeClassHashVersion || !foundNodeClaimHashVersion {
+		return ""
+	}
+	if nodeClassHashVersion != nodeClaimHashVersion {
+		return ""
+	}
+	return lo.Ternary(nodeClassHash != nodeClaimHash, NodeClassDrift, "")
+}
+
+// isImageDrifted compares the image of the server with the images of the NodeClass. Servers booted
+// from a volume report no image, so the image the NodeClaim was launched with is used for them.
+func (c *CloudProvider) isImageDrifted(nodeClaim *karpv1.NodeClaim, instance *instance.Instance, nodeClass *v1openstack.OpenStackNodeClass) cloudprovider.DriftReason {
+	imageIDs := sets.New(lo.Map(nodeClass.Status.Images, func(image v1openstack.Image, _ int) string { return image.ID })...)
+	imageID := lo.Ternary(instance.ImageID != "", instance.ImageID, nodeClaim.Status.ImageID)
+	// A NodeClass whose images have not been resolved yet gives us nothing to compare against.
+	if imageID == "" || imageIDs.Len() == 0 {
+		return ""
+	}
+	return lo.Ternary(!imageIDs.Has(imageID), ImageDrift, "")
+}
+
+func (c *CloudProvider) areNetworksDrifted(instance *instance.Instance, nodeClass *v1openstack.OpenStackNodeClass) cloudprovider.DriftReason {
+	if len(nodeClass.Spec.Networks) == 0 {
+		return ""
+	}
+	return lo.Ternary(!sets.New(instance.Networks...).Equal(sets.New(nodeClass.Spec.Networks...)), NetworkDrift, "")
+}
+
+// areSecurityGroupsDrifted only applies when the NodeClass lists security groups, otherwise
+// Nova attaches the project's default group, which is not something the NodeClass controls.
+// Nova reports the groups of a server by name while the NodeClass may list their IDs, so the
+// groups of the NodeClass are resolved to their names first.
+func (c *CloudProvider) areSecurityGroupsDrifted(instance *instance.Instance, nodeClass *v1openstack.OpenStackNodeClass) (cloudprovider.DriftReason, error) {
+	if len(nodeClass.Spec.SecurityGroups) == 0 {
+		return "", nil
+	}
+	names, err := c.securityGroupNames(nodeClass.Spec.SecurityGroups)
+	if err != nil {
+		return "", err
+	}
+	return lo.Ternary(!sets.New(instance.SecurityGroups...).Equal(sets.New(names...)), SecurityGroupDrift, ""), nil
+}
+
+// securityGroupNames maps the security groups, given by name or ID, to their names. Groups Neutron
+// does not know are kept as they are, which reports them as drifted.
+func (c *CloudProvider) securityGroupNames(securityGroups []string) ([]string, error) {
+	if c.networkClient == nil {
+		return securityGroups, nil
+	}
+	pages, err := groups.List(c.networkClient, groups.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("listing security groups: %w", err)
+	}
+	existing, err := groups.ExtractGroups(pages)
+	if err != nil {
+		return nil, fmt.Errorf("extracting security groups: %w", err)
+	}
+	namesByID := lo.SliceToMap(existing, func(sg groups.SecGroup) (string, string) { return sg.ID, sg.Name })
+	return lo.Map(securityGroups, func(sg string, _ int) string {
+		if name, ok := namesByID[sg]; ok {
+			return name
+		}
+		return sg
+	}), nil
+}
+
+// isFlavorDrifted reports servers whose flavor is no longer in the catalog or no longer selected by
+// the NodeClass. Only the flavors are selected, rather than listing every instance type, as it runs
+// for every NodeClaim on every disruption pass. The servers of a Blazar instance reservation run the
+// flavor Blazar created for it, which the NodeClass doesn't select.
+func (c *CloudProvider) isFlavorDrifted(ctx context.Context, instance *instance.Instance, nodeClass *v1openstack.OpenStackNodeClass) (cloudprovider.DriftReason, error) {
+	if instance.ReservationType == v1openstack.ReservationTypeInstance {
+		return "", nil
+	}
+	selected, err := c.instanceTypeProvider.SelectFlavors(ctx, nodeClass)
+	if err != nil {
+		return "", fmt.Errorf("selecting flavors: %w", err)
+	}
+	offered := lo.ContainsBy(selected, func(flavor flavors.Flavor) bool { return isFlavorOf(flavor, instance) })
+	return lo.Ternary(!offered, FlavorDrift, ""), nil
+}
+
+// isCapacityTypeDrifted reports spot servers whose flavor the NodeClass no longer offers as spot
+// capacity, and on-demand servers whose flavor the preemptible tier of the NodeClass now only offers
+// as spot capacity. Reserved servers are left to their reservation.
+func (c *CloudProvider) isCapacityTypeDrifted(ctx context.Context, instance *instance.Instance, nodeClass *v1openstack.OpenStackNodeClass) (cloudprovider.DriftReason, error) {
+	spot := instance.CapacityType == karpv1.CapacityTypeSpot
+	preemptible := nodeClass.Spec.Preemptible
+	switch {
+	case instance.CapacityType == karpv1.CapacityTypeReserved:
+		return "", nil
+	case preemptible == nil:
+		return lo.Ternary(spot, CapacityTypeDrift, ""), nil
+	case preemptible.Project:
+		// Every flavor is offered both on demand and in the preemptible project.
+		return "", nil
+	}
+	spotFlavors, err := c.instanceTypeProvider.SelectPreemptibleFlavors(ctx, nodeClass)
+	if err != nil {
+		return "", fmt.Errorf("selecting preemptible flavors: %w", err)
+	}
+	spotFlavor := lo.ContainsBy(spotFlavors, func(flavor flavors.Flavor) bool { return isFlavorOf(flavor, instance) })
+	return lo.Ternary(spot != spotFlavor, CapacityTypeDrift, ""), nil
+}
+
+// isFlavorOf reports whether the flavor is the flavor of the server, which Nova reports by ID before
+// microversion 2.47 and by name since.
+func isFlavorOf(flavor flavors.Flavor, instance *instance.Instance) bool {
+	return flavor.Name == instance.Type || (instance.FlavorID != "" && flavor.ID == instance.FlavorID)
+}
@@ -0,0 +1,248 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCloudProviderIsDrifted(t *testing.T) {
+	const (
+		nodeClassName = "drift-node-class"
+		flavorID      = "flavor-small"
+	)
+
+	newNodeClass := func() *v1openstack.OpenStackNodeClass {
+		nodeClass := &v1openstack.OpenStackNodeClass{
+			ObjectMeta: metav1.ObjectMeta{Name: nodeClassName},
+			Spec: v1openstack.OpenStackNodeClassSpec{
+				ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{ID: "image-1"}},
+				Networks:           []string{"net-1"},
+				SecurityGroups:     []string{"default"},
+				UserData:           "#!/bin/bash",
+			},
+			Status: v1openstack.OpenStackNodeClassStatus{
+				Images: []v1openstack.Image{{ID: "image-1", Architecture: "amd64"}},
+			},
+		}
+		nodeClass.Annotations = map[string]string{
+			v1openstack.AnnotationOpenStackNodeClassHash:        nodeClass.Hash(),
+			v1openstack.AnnotationOpenStackNodeClassHashVersion: v1openstack.OpenStackNodeClassHashVersion,
+		}
+		return nodeClass
+	}
+	newInstance := func() *instance.Instance {
+		return &instance.Instance{
+			InstanceID:     "server-1",
+			Type:           flavorID,
+			FlavorID:       flavorID,
+			ImageID:        "image-1",
+			Networks:       []string{"net-1"},
+			SecurityGroups: []string{"default"},
+		}
+	}
+
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddSecurityGroup(fake.SecurityGroup{ID: "sg-default", Name: "default"})
+	neutron.AddSecurityGroup(fake.SecurityGroup{ID: "sg-ssh", Name: "ssh"})
+
+	cases := []struct {
+		name      string
+		nodeClass func(*v1openstack.OpenStackNodeClass)
+		nodeClaim func(*karpv1.NodeClaim)
+		instance  func(*instance.Instance)
+		getErr    error
+		expected  cloudprovider.DriftReason
+	}{
+		{name: "not drifted"},
+		{
+			name: "static fields changed",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Annotations[v1openstack.AnnotationOpenStackNodeClassHash] = "changed"
+			},
+			expected: NodeClassDrift,
+		},
+		{
+			name: "hash version changed",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Annotations[v1openstack.AnnotationOpenStackNodeClassHash] = "changed"
+				nc.Annotations[v1openstack.AnnotationOpenStackNodeClassHashVersion] = "v0"
+			},
+		},
+		{
+			name:     "image changed",
+			instance: func(i *instance.Instance) { i.ImageID = "image-0" },
+			expected: ImageDrift,
+		},
+		{
+			name:      "boot-from-volume image changed",
+			nodeClaim: func(nc *karpv1.NodeClaim) { nc.Status.ImageID = "image-0" },
+			instance:  func(i *instance.Instance) { i.ImageID = "" },
+			expected:  ImageDrift,
+		},
+		{
+			name:      "boot-from-volume image unchanged",
+			nodeClaim: func(nc *karpv1.NodeClaim) { nc.Status.ImageID = "image-1" },
+			instance:  func(i *instance.Instance) { i.ImageID = "" },
+		},
+		{
+			name:     "network removed",
+			instance: func(i *instance.Instance) { i.Networks = []string{"net-1", "net-2"} },
+			expected: NetworkDrift,
+		},
+		{
+			name:     "security group removed",
+			instance: func(i *instance.Instance) { i.SecurityGroups = []string{"default", "ssh"} },
+			expected: SecurityGroupDrift,
+		},
+		{
+			name:      "security groups listed by ID",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.SecurityGroups = []string{"sg-default", "ssh"} },
+			instance:  func(i *instance.Instance) { i.SecurityGroups = []string{"default", "ssh"} },
+		},
+		{
+			name:      "security group listed by ID removed",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.SecurityGroups = []string{"sg-default"} },
+			instance:  func(i *instance.Instance) { i.SecurityGroups = []string{"default", "ssh"} },
+			expected:  SecurityGroupDrift,
+		},
+		{
+			name:     "flavor no longer offered",
+			instance: func(i *instance.Instance) { i.Type, i.FlavorID = "flavor-retired", "flavor-retired" },
+			expected: FlavorDrift,
+		},
+		{
+			name: "flavor no longer selected",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.FlavorSelectorTerms = []v1openstack.OpenStackFlavorSelectorTerm{{Name: "general.large"}}
+			},
+			expected: FlavorDrift,
+		},
+		{
+			name:     "spot server without a preemptible tier",
+			instance: func(i *instance.Instance) { i.CapacityType = karpv1.CapacityTypeSpot },
+			expected: CapacityTypeDrift,
+		},
+		{
+			name: "flavor now only offered as spot capacity",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Preemptible = &v1openstack.Preemptible{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "general.*"}}}
+			},
+			instance: func(i *instance.Instance) { i.CapacityType = karpv1.CapacityTypeOnDemand },
+			expected: CapacityTypeDrift,
+		},
+		{
+			name: "spot server of a preemptible flavor",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Preemptible = &v1openstack.Preemptible{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "general.*"}}}
+			},
+			instance: func(i *instance.Instance) { i.CapacityType = karpv1.CapacityTypeSpot },
+		},
+		{
+			name: "spot server of a flavor no longer preemptible",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Preemptible = &v1openstack.Preemptible{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "gpu.*"}}}
+			},
+			instance: func(i *instance.Instance) { i.CapacityType = karpv1.CapacityTypeSpot },
+			expected: CapacityTypeDrift,
+		},
+		{
+			name: "spot server in the preemptible project",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Preemptible = &v1openstack.Preemptible{Project: true}
+			},
+			instance: func(i *instance.Instance) { i.CapacityType = karpv1.CapacityTypeSpot },
+		},
+		{
+			name:   "instance gone",
+			getErr: cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance not found")),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClass := newNodeClass()
+			nodeClaim := &karpv1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "drift-nodeclaim",
+					Annotations: map[string]string{
+						v1openstack.AnnotationOpenStackNodeClassHash:        nodeClass.Hash(),
+						v1openstack.AnnotationOpenStackNodeClassHashVersion: v1openstack.OpenStackNodeClassHashVersion,
+					},
+				},
+				Spec:   karpv1.NodeClaimSpec{NodeClassRef: &karpv1.NodeClassReference{Name: nodeClassName}},
+				Status: karpv1.NodeClaimStatus{ProviderID: "openstack:///server-1"},
+			}
+			if tc.nodeClass != nil {
+				tc.nodeClass(nodeClass)
+			}
+			if tc.nodeClaim != nil {
+				tc.nodeClaim(nodeClaim)
+			}
+			inst := newInstance()
+			if tc.instance != nil {
+				tc.instance(inst)
+			}
+
+			scheme := runtime.NewScheme()
+			require.NoError(t, v1openstack.AddToScheme(scheme))
+			cp := &CloudProvider{
+				kubeClient: clientfake.NewClientBuilder().WithScheme(scheme).WithObjects(nodeClass).Build(),
+				instanceTypeProvider: &instancetype.DefaultProvider{
+					InstanceTypesInfo: []flavors.Flavor{{ID: flavorID, Name: "general.small", VCPUs: 2, RAM: 4096}},
+				},
+				instanceProvider: &mockProvider{
+					GetFunc: func(_ context.Context, instanceID string) (*instance.Instance, error) {
+						assert.Equal(t, "server-1", instanceID)
+						if tc.getErr != nil {
+							return nil, tc.getErr
+						}
+						return inst, nil
+					},
+				},
+				networkClient: neutron.ServiceClient(),
+			}
+
+			reason, err := cp.IsDrifted(ctx, nodeClaim)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, reason)
+		})
+	}
+}
+
+func TestNodeClassHashIgnoresDynamicFields(t *testing.T) {
+	nodeClass := &v1openstack.OpenStackNodeClass{
+		Spec: v1openstack.OpenStackNodeClassSpec{
+			ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{ID: "image-1"}},
+			Networks:           []string{"net-1"},
+			UserData:           "#!/bin/bash",
+		},
+	}
+	hash := nodeClass.Hash()
+
+	nodeClass.Spec.ImageSelectorTerms = []v1openstack.OpenStackImageSelectorTerm{{ID: "image-2"}}
+	nodeClass.Spec.Networks = []string{"net-2"}
+	nodeClass.Spec.SecurityGroups = []string{"ssh"}
+	nodeClass.Spec.FlavorSelectorTerms = []v1openstack.OpenStackFlavorSelectorTerm{{Name: "general.*"}}
+	nodeClass.Spec.Preemptible = &v1openstack.Preemptible{Project: true}
+	assert.Equal(t, hash, nodeClass.Hash())
+
+	nodeClass.Spec.UserData = "#!/bin/sh"
+	assert.NotEqual(t, hash, nodeClass.Hash())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/awslabs/operatorpkg/status"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
@@ -39,6 +40,14 @@ func (m *mockInstanceProvider) Delete(ctx context.Context, providerID string) er
     return nil
 }
 
+func (m *mockInstanceProvider) Get(ctx context.Context, providerID string) (*instance.Instance, error) {
+	return nil, fmt.Errorf("GetFunc não implementado")
+}
+
+func (m *mockInstanceProvider) List(ctx context.Context) ([]*instance.Instance, error) {
+	return nil, nil
+}
+
 func TestCloudProviderCreate(t *testing.T) {
 
 	//Valores requeridos pelo kubernetes
@@ -84,6 +93,7 @@ func TestCloudProviderCreate(t *testing.T) {
 			ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{ID: imageID}},
 		},
 	}
+	nodeClass.StatusConditions().SetTrue(status.ConditionReady)
 
 	realITProvider := &instancetype.DefaultProvider{
 		InstanceTypesInfo: flavorsList,
@@ -161,7 +171,7 @@ func TestCloudProviderCreate(t *testing.T) {
 	require.NotNil(t, createdNodeClaim, "O NodeClaim retornado não deve ser nulo")
 
 	// Verificar Status
-	expectedProviderID := fmt.Sprintf("openstack://%s/%s", instanceID, returnedInstance.Name)
+	expectedProviderID := fmt.Sprintf("openstack:///%s", instanceID)
 	assert.Equal(t, expectedProviderID, createdNodeClaim.Status.ProviderID)
 	assert.Equal(t, imageID, createdNodeClaim.Status.ImageID)
 
@@ -169,7 +179,7 @@ func TestCloudProviderCreate(t *testing.T) {
 	assert.Equal(t, flavorSmall, createdNodeClaim.Labels[corev1.LabelInstanceTypeStable])
 	assert.Equal(t, "amd64", createdNodeClaim.Labels[corev1.LabelArchStable])
 	assert.Equal(t, "linux", createdNodeClaim.Labels[corev1.LabelOSStable])
-	assert.Equal(t, flavorSmall, createdNodeClaim.Labels["instance-type"])
+	assert.Equal(t, flavorSmall, createdNodeClaim.Labels[corev1.LabelInstanceTypeStable])
 
 	// Verificar Capacity
 	expectedCPU := resource.MustParse("2")
@@ -210,3 +220,35 @@ func TestCloudProviderCreate(t *testing.T) {
 	)
 
 }
+
+func TestCloudProviderCreateNodeClassNotReady(t *testing.T) {
+	ctx := context.Background()
+	nodeClass := &v1openstack.OpenStackNodeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "not-ready-node-class"},
+		Spec: v1openstack.OpenStackNodeClassSpec{
+			ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{Alias: "typo"}},
+		},
+	}
+	nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeImagesReady, "ImagesNotFound", "ImageSelectorTerms did not match any active image")
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1openstack.AddToScheme(scheme))
+	cp := &CloudProvider{
+		kubeClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(nodeClass).Build(),
+		instanceProvider: &mockInstanceProvider{
+			CreateFunc: func(context.Context, *v1openstack.OpenStackNodeClass, *karpv1.NodeClaim, []*cloudprovider.InstanceType) (*instance.Instance, error) {
+				t.Fatalf("instance provider must not be called for a NodeClass that is not ready")
+				return nil, nil
+			},
+		},
+	}
+
+	_, err := cp.Create(ctx, &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-claim"},
+		Spec: karpv1.NodeClaimSpec{
+			NodeClassRef: &karpv1.NodeClassReference{Name: nodeClass.Name},
+		},
+	})
+	require.Error(t, err)
+	assert.True(t, cloudprovider.IsNodeClassNotReadyError(err), "expected NodeClassNotReadyError, got %v", err)
+}
@@ -18,6 +18,8 @@ import (
 type mockProvider struct {
 	CreateFunc func(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*instance.Instance, error)
 	DeleteFunc func(ctx context.Context, providerID string) error // Adicionado o retorno de erro para o DeleteFunc
+	GetFunc    func(ctx context.Context, instanceID string) (*instance.Instance, error)
+	ListFunc   func(ctx context.Context) ([]*instance.Instance, error)
 }
 
 func (m *mockProvider) Create(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*instance.Instance, error) {
@@ -34,6 +36,20 @@ func (m *mockProvider) Delete(ctx context.Context, providerID string) error {
 	return nil
 }
 
+func (m *mockProvider) Get(ctx context.Context, instanceID string) (*instance.Instance, error) {
+	if m.GetFunc != nil {
+		return m.GetFunc(ctx, instanceID)
+	}
+	return nil, fmt.Errorf("GetFunc não implementado")
+}
+
+func (m *mockProvider) List(ctx context.Context) ([]*instance.Instance, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx)
+	}
+	return nil, nil
+}
+
 func TestCloudProviderDelete(t *testing.T) {
 	const (
 		nodeClaimName = "delete-test-nodeclaim"
@@ -103,3 +119,24 @@ func TestCloudProviderDelete(t *testing.T) {
 		require.Error(t, err, "A exclusão deve retornar erro se ProviderID tiver um formato incorreto")
 		assert.Contains(t, err.Error(), "unexpected providerID format", "Mensagem de erro incorreta para formato de ProviderID inválido")	})
 	}
+
+func TestDeleteInvalidProviderID(t *testing.T) {
+	deleteCalled := false
+	cp := &CloudProvider{
+		instanceProvider: &mockProvider{
+			DeleteFunc: func(context.Context, string) error {
+				deleteCalled = true
+				return nil
+			},
+		},
+	}
+
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "delete-test-nodeclaim"},
+		Status:     karpv1.NodeClaimStatus{ProviderID: "wrong-format"},
+	}
+	err := cp.Delete(context.Background(), nodeClaim)
+
+	require.Error(t, err, "expected parsing error but got nil")
+	assert.False(t, deleteCalled, "the instance provider must not be called with a malformed provider ID")
+}
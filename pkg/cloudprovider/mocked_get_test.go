@@ -0,0 +1,111 @@
+package cloudprovider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+	corev1 "k8s.io/api/core/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// init registers the reservation labels, as the operator does.
+func init() {
+	cloudprovider.ReservationIDLabel = v1openstack.LabelReservationID
+	cloudprovider.ReservedCapacityLabels.Insert(v1openstack.LabelReservationType)
+	karpv1.WellKnownLabels = karpv1.WellKnownLabels.Insert(v1openstack.LabelReservationID, v1openstack.LabelReservationType)
+}
+
+func TestCloudProviderGet(t *testing.T) {
+	const providerID = "openstack:///server-1"
+	ctx := context.Background()
+
+	t.Run("existing instance", func(t *testing.T) {
+		getCalledWith := ""
+		cp := &CloudProvider{
+			instanceProvider: &mockProvider{
+				GetFunc: func(_ context.Context, id string) (*instance.Instance, error) {
+					getCalledWith = id
+					return &instance.Instance{
+						Name:       "karpenter-test",
+						Type:       "general.small",
+						ImageID:    "image-1",
+						InstanceID: "server-1",
+						Status:     "ACTIVE",
+						Metadata:   map[string]string{},
+						FloatingIP: "203.0.113.10",
+					}, nil
+				},
+			},
+		}
+
+		nodeClaim, err := cp.Get(ctx, providerID)
+		require.NoError(t, err)
+		assert.Equal(t, "server-1", getCalledWith)
+		assert.Equal(t, providerID, nodeClaim.Status.ProviderID)
+		assert.Equal(t, "image-1", nodeClaim.Status.ImageID)
+		assert.Equal(t, "general.small", nodeClaim.Labels[corev1.LabelInstanceTypeStable])
+		assert.Equal(t, "203.0.113.10", nodeClaim.Annotations[v1openstack.AnnotationFloatingIP])
+	})
+
+	t.Run("reserved instance", func(t *testing.T) {
+		cp := &CloudProvider{
+			instanceProvider: &mockProvider{
+				GetFunc: func(context.Context, string) (*instance.Instance, error) {
+					return &instance.Instance{
+						Name:            "karpenter-test",
+						Type:            "reservation:reservation-1",
+						FlavorID:        "reservation-1",
+						InstanceID:      "server-1",
+						Metadata:        map[string]string{},
+						CapacityType:    karpv1.CapacityTypeReserved,
+						ReservationID:   "reservation-1",
+						ReservationType: v1openstack.ReservationTypeInstance,
+					}, nil
+				},
+			},
+		}
+
+		nodeClaim, err := cp.Get(ctx, providerID)
+		require.NoError(t, err)
+		assert.Equal(t, karpv1.CapacityTypeReserved, nodeClaim.Labels[karpv1.CapacityTypeLabelKey])
+		assert.Equal(t, "reservation-1", nodeClaim.Labels[v1openstack.LabelReservationID])
+		assert.Equal(t, v1openstack.ReservationTypeInstance, nodeClaim.Labels[v1openstack.LabelReservationType])
+	})
+
+	t.Run("instance gone", func(t *testing.T) {
+		cp := &CloudProvider{
+			instanceProvider: &mockProvider{
+				GetFunc: func(context.Context, string) (*instance.Instance, error) {
+					return nil, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance not found"))
+				},
+			},
+		}
+
+		_, err := cp.Get(ctx, providerID)
+		require.Error(t, err)
+		assert.True(t, cloudprovider.IsNodeClaimNotFoundError(err), "expected NodeClaimNotFoundError, got %v", err)
+	})
+
+	t.Run("invalid providerID format", func(t *testing.T) {
+		cp := &CloudProvider{instanceProvider: &mockProvider{}}
+
+		_, err := cp.Get(ctx, "invalid-format-id")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected providerID format")
+	})
+
+	t.Run("empty server ID", func(t *testing.T) {
+		cp := &CloudProvider{instanceProvider: &mockProvider{}}
+
+		_, err := cp.Get(ctx, "openstack:///")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid OpenStack providerID")
+	})
+}
@@ -0,0 +1,96 @@
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCloudProviderList(t *testing.T) {
+	const (
+		nodeClassName = "test-node-class"
+		flavorID      = "7441c7d9-2648-4a33-907e-4d28c2270da3"
+	)
+	ctx := context.Background()
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	nodeClass := &v1openstack.OpenStackNodeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeClassName},
+		Spec: v1openstack.OpenStackNodeClassSpec{
+			ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{ID: "image-1"}},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1openstack.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(nodeClass).Build()
+
+	cp := &CloudProvider{
+		kubeClient: fakeClient,
+		instanceTypeProvider: &instancetype.DefaultProvider{
+			InstanceTypesInfo: []flavors.Flavor{{ID: flavorID, Name: "general.small", VCPUs: 2, RAM: 4096}},
+		},
+		instanceProvider: &mockProvider{
+			ListFunc: func(context.Context) ([]*instance.Instance, error) {
+				return []*instance.Instance{
+					{
+						Name:         "karpenter-managed",
+						Type:         flavorID,
+						FlavorID:     flavorID,
+						ImageID:      "image-1",
+						InstanceID:   "server-1",
+						Status:       "ACTIVE",
+						CreationTime: created,
+						Metadata: map[string]string{
+							v1openstack.NodeClassMetadataKey: nodeClassName,
+							v1openstack.NodePoolMetadataKey:  "default",
+						},
+					},
+					{
+						Name:       "karpenter-orphaned",
+						Type:       flavorID,
+						InstanceID: "server-2",
+						Status:     "ACTIVE",
+						Metadata: map[string]string{
+							v1openstack.NodeClassMetadataKey: "deleted-node-class",
+						},
+					},
+				}, nil
+			},
+		},
+	}
+
+	nodeClaims, err := cp.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, nodeClaims, 2)
+
+	managed := nodeClaims[0]
+	assert.Equal(t, "openstack:///server-1", managed.Status.ProviderID)
+	assert.Equal(t, "image-1", managed.Status.ImageID)
+	assert.Equal(t, "default", managed.Labels[karpv1.NodePoolLabelKey])
+	assert.Equal(t, "amd64", managed.Labels[corev1.LabelArchStable])
+	assert.Equal(t, "general.small", managed.Labels[corev1.LabelInstanceTypeStable])
+	assert.Equal(t, flavorID, managed.Labels[v1openstack.LabelFlavorID])
+	assert.True(t, created.Equal(managed.CreationTimestamp.Time))
+	expectedMem := resource.MustParse("4Gi")
+	actualMem := managed.Status.Capacity[corev1.ResourceMemory]
+	assert.Zerof(t, expectedMem.Cmp(actualMem), "Memory capacity mismatch: expected %s, got %s", expectedMem.String(), actualMem.String())
+
+	orphaned := nodeClaims[1]
+	assert.Equal(t, "openstack:///server-2", orphaned.Status.ProviderID)
+	assert.Empty(t, orphaned.Status.Capacity)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+)
+
+// DefaultCapacityRefreshInterval is how often the free capacity of the compute nodes is read from
+// Placement. Each refresh lists the resource providers and their allocation candidates once.
+const DefaultCapacityRefreshInterval = time.Minute
+
+var capacityRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "karpenter_openstack",
+	Name:      "capacity_refresh_errors_total",
+	Help:      "Number of failed Placement capacity refreshes.",
+})
+
+func init() {
+	crmetrics.Registry.MustRegister(capacityRefreshErrors)
+}
+
+// CapacityRefresher keeps the free capacity of the compute nodes up to date, so that the offerings
+// of the flavors that no longer fit in a zone are unavailable before a launch fails. While Placement
+// can't be read, offerings are not restricted by capacity.
+type CapacityRefresher struct {
+	perReplica
+
+	InstanceTypeProvider *instancetype.DefaultProvider
+	Interval             time.Duration
+}
+
+func (r *CapacityRefresher) Start(ctx context.Context) error {
+	runPeriodically(ctx, r.refresh, r.Interval)
+	return nil
+}
+
+func (r *CapacityRefresher) refresh(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("capacity.refresher")
+	if err := r.InstanceTypeProvider.UpdateCapacity(ctx); err != nil {
+		capacityRefreshErrors.Inc()
+		logger.Error(err, "failed to refresh Placement capacity, offerings are not restricted by capacity")
+	}
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/floatingip"
+)
+
+// DefaultFloatingIPGCInterval is how often leaked floating IPs are looked for.
+const DefaultFloatingIPGCInterval = 5 * time.Minute
+
+// FloatingIPGarbageCollector periodically releases the floating IPs that outlived their server,
+// e.g. because the controller crashed between deleting a server and its floating IP.
+type FloatingIPGarbageCollector struct {
+	FloatingIPProvider floatingip.Provider
+	Interval           time.Duration
+}
+
+func (g *FloatingIPGarbageCollector) Start(ctx context.Context) error {
+	runPeriodically(ctx, g.sweep, g.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes sure only one replica sweeps at a time.
+func (g *FloatingIPGarbageCollector) NeedLeaderElection() bool {
+	return true
+}
+
+func (g *FloatingIPGarbageCollector) sweep(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("floatingip.gc")
+	released, err := g.FloatingIPProvider.ReleaseLeaked(ctx)
+	if len(released) > 0 {
+		logger.Info("released leaked floating IPs", "floatingIPs", released)
+	}
+	if err != nil {
+		logger.Error(err, "failed to release leaked floating IPs")
+	}
+}
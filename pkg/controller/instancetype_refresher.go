@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+)
+
+const (
+	// DefaultInstanceTypeRefreshInterval is how often the flavor catalog is listed from Nova.
+	DefaultInstanceTypeRefreshInterval = 5 * time.Minute
+	// instanceTypeRetryInterval is the first retry delay after a failed refresh. It doubles on
+	// every failure, up to the refresh interval.
+	instanceTypeRetryInterval = 10 * time.Second
+)
+
+var (
+	instanceTypesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "karpenter_openstack",
+		Name:      "instance_types",
+		Help:      "Number of flavors in the instance type catalog.",
+	})
+	instanceTypeCatalogChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "karpenter_openstack",
+		Name:      "instance_type_catalog_changes_total",
+		Help:      "Number of times a refresh found flavors added, removed or modified.",
+	})
+	instanceTypeRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "karpenter_openstack",
+		Name:      "instance_type_refresh_errors_total",
+		Help:      "Number of failed flavor catalog refreshes.",
+	})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(instanceTypesGauge, instanceTypeCatalogChanges, instanceTypeRefreshErrors)
+}
+
+// InstanceTypeRefresher keeps the flavor catalog up to date. When Nova can't be reached the last
+// known catalog keeps being served and the refresh is retried with exponential backoff.
+type InstanceTypeRefresher struct {
+	InstanceTypeProvider *instancetype.DefaultProvider
+	Interval             time.Duration
+}
+
+func (r *InstanceTypeRefresher) Start(ctx context.Context) error {
+	instanceTypesGauge.Set(float64(len(r.InstanceTypeProvider.Flavors())))
+	backoff := r.newBackoff()
+	delay := r.Interval
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		if r.refresh(ctx) {
+			backoff = r.newBackoff()
+			delay = r.Interval
+		} else {
+			delay = backoff.Step()
+		}
+	}
+}
+
+// NeedLeaderElection is false, every replica serves instance types from its own catalog.
+func (r *InstanceTypeRefresher) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *InstanceTypeRefresher) newBackoff() *wait.Backoff {
+	return &wait.Backoff{
+		Duration: instanceTypeRetryInterval,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+		Cap:      r.Interval,
+	}
+}
+
+// refresh lists the flavors once and reports whether it succeeded.
+func (r *InstanceTypeRefresher) refresh(ctx context.Context) bool {
+	logger := log.FromContext(ctx).WithName("instancetype.refresher")
+	changed, err := r.InstanceTypeProvider.UpdateInstanceTypes(ctx)
+	if err != nil {
+		instanceTypeRefreshErrors.Inc()
+		logger.Error(err, "failed to refresh instance types, serving the last known catalog")
+		return false
+	}
+	if changed {
+		instanceTypeCatalogChanges.Inc()
+		instanceTypesGauge.Set(float64(len(r.InstanceTypeProvider.Flavors())))
+		logger.Info("instance type catalog changed", "count", len(r.InstanceTypeProvider.Flavors()))
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+)
+
+// DefaultInterruptionCheckInterval is how often the spot servers are checked for preemption. The
+// cloud reclaims them without notice, so the sooner their nodes are drained the better.
+const DefaultInterruptionCheckInterval = 15 * time.Second
+
+// preemptedStatuses are the statuses of the spot servers the cloud reclaimed without deleting them.
+var preemptedStatuses = sets.New("SHUTOFF", "SHELVED", "SHELVED_OFFLOADED")
+
+var (
+	spotInterruptions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "karpenter_openstack",
+		Name:      "spot_interruptions_total",
+		Help:      "Number of spot servers found preempted.",
+	})
+	interruptionCheckErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "karpenter_openstack",
+		Name:      "interruption_check_errors_total",
+		Help:      "Number of failed checks of the spot servers for preemption.",
+	})
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(spotInterruptions, interruptionCheckErrors)
+}
+
+// InterruptionController deletes the NodeClaims of the spot servers the cloud preempted, which were
+// stopped, shelved or deleted behind Karpenter's back, so that their nodes are drained and the pods
+// rescheduled. The offering is left out for a while, the preemptible tier being short of capacity.
+type InterruptionController struct {
+	Client               client.Client
+	InstanceProvider     instance.Provider
+	UnavailableOfferings *cache.UnavailableOfferings
+	Interval             time.Duration
+}
+
+func (c *InterruptionController) Start(ctx context.Context) error {
+	runPeriodically(ctx, c.check, c.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes sure only one replica deletes the NodeClaims.
+func (c *InterruptionController) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *InterruptionController) check(ctx context.Context) {
+	if err := c.handleInterruptions(ctx); err != nil {
+		interruptionCheckErrors.Inc()
+		log.FromContext(ctx).WithName("interruption").Error(err, "failed to check spot servers for preemption")
+	}
+}
+
+func (c *InterruptionController) handleInterruptions(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("interruption")
+	nodeClaimList := &karpv1.NodeClaimList{}
+	if err := c.Client.List(ctx, nodeClaimList, client.MatchingLabels{karpv1.CapacityTypeLabelKey: karpv1.CapacityTypeSpot}); err != nil {
+		return fmt.Errorf("listing spot NodeClaims: %w", err)
+	}
+	// NodeClaims being deleted are already drained, and those without a provider ID not launched yet.
+	nodeClaims := lo.Filter(nodeClaimList.Items, func(nodeClaim karpv1.NodeClaim, _ int) bool {
+		return nodeClaim.Status.ProviderID != "" && nodeClaim.DeletionTimestamp.IsZero()
+	})
+	if len(nodeClaims) == 0 {
+		return nil
+	}
+	instances, err := c.InstanceProvider.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing instances: %w", err)
+	}
+	instancesByProviderID := lo.SliceToMap(instances, func(instance *instance.Instance) (string, *instance.Instance) {
+		return fmt.Sprintf("openstack:///%s", instance.InstanceID), instance
+	})
+
+	var errs []error
+	for i := range nodeClaims {
+		nodeClaim := &nodeClaims[i]
+		status := "DELETED"
+		if instance, ok := instancesByProviderID[nodeClaim.Status.ProviderID]; ok {
+			if !preemptedStatuses.Has(instance.Status) {
+				continue
+			}
+			status = instance.Status
+		}
+		logger.Info("spot server was preempted, deleting its NodeClaim", "nodeClaim", nodeClaim.Name, "providerID", nodeClaim.Status.ProviderID, "status", status)
+		spotInterruptions.Inc()
+		if c.UnavailableOfferings != nil {
+			c.UnavailableOfferings.MarkUnavailable(ctx, "SpotInterruption", nodeClaim.Labels[corev1.LabelInstanceTypeStable], nodeClaim.Labels[corev1.LabelTopologyZone], karpv1.CapacityTypeSpot)
+		}
+		if err := c.Client.Delete(ctx, nodeClaim); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("deleting NodeClaim %s: %w", nodeClaim.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
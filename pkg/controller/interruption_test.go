@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
+)
+
+func TestHandleInterruptions(t *testing.T) {
+	ctx := context.Background()
+	nova := fake.NewNova()
+	defer nova.Close()
+	metadata := map[string]string{v1openstack.ClusterMetadataKey: "test-cluster", v1openstack.CapacityTypeMetadataKey: karpv1.CapacityTypeSpot}
+	nova.AddServer(fake.Server{ID: "running", Status: "ACTIVE", Metadata: metadata})
+	nova.AddServer(fake.Server{ID: "stopped", Status: "SHUTOFF", Metadata: metadata})
+	nova.AddServer(fake.Server{ID: "stopped-on-demand", Status: "SHUTOFF", Metadata: map[string]string{v1openstack.ClusterMetadataKey: "test-cluster"}})
+
+	newNodeClaim := func(name, capacityType, providerID string) *karpv1.NodeClaim {
+		return &karpv1.NodeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+				karpv1.CapacityTypeLabelKey:    capacityType,
+				corev1.LabelInstanceTypeStable: "m1.large",
+				corev1.LabelTopologyZone:       "az-1",
+			}},
+			Status: karpv1.NodeClaimStatus{ProviderID: providerID},
+		}
+	}
+	kubeClient := clientfake.NewClientBuilder().WithObjects(
+		newNodeClaim("running", karpv1.CapacityTypeSpot, "openstack:///running"),
+		newNodeClaim("stopped", karpv1.CapacityTypeSpot, "openstack:///stopped"),
+		newNodeClaim("deleted", karpv1.CapacityTypeSpot, "openstack:///deleted"),
+		newNodeClaim("launching", karpv1.CapacityTypeSpot, ""),
+		newNodeClaim("stopped-on-demand", karpv1.CapacityTypeOnDemand, "openstack:///stopped-on-demand"),
+	).Build()
+	controller := &InterruptionController{
+		Client:               kubeClient,
+		InstanceProvider:     instance.NewProvider(nova.ServiceClient(), nil, nil, "test-cluster", nil, nil),
+		UnavailableOfferings: cache.NewUnavailableOfferings(),
+	}
+
+	require.NoError(t, controller.handleInterruptions(ctx))
+	for name, interrupted := range map[string]bool{"running": false, "stopped": true, "deleted": true, "launching": false, "stopped-on-demand": false} {
+		err := kubeClient.Get(ctx, client.ObjectKey{Name: name}, &karpv1.NodeClaim{})
+		if interrupted {
+			assert.True(t, errors.IsNotFound(err), "expected NodeClaim %s to be deleted, got %v", name, err)
+		} else {
+			assert.NoError(t, err, "expected NodeClaim %s to be kept", name)
+		}
+	}
+	assert.True(t, controller.UnavailableOfferings.IsUnavailable("m1.large", "az-1", karpv1.CapacityTypeSpot))
+	assert.False(t, controller.UnavailableOfferings.IsUnavailable("m1.large", "az-1", karpv1.CapacityTypeOnDemand))
+}
@@ -3,15 +3,28 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/awslabs/operatorpkg/status"
+	"github.com/gophercloud/gophercloud"
 	v1openstack "github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/image"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/equality"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// readinessRequeueInterval is how often a NodeClass is re-validated against OpenStack, so that
+// resources deleted or created out of band are reflected in its conditions.
+const readinessRequeueInterval = 5 * time.Minute
+
 type OpenStackNodeClassReconciler struct {
-	Client client.Client
+	Client               client.Client
+	ImageProvider        image.Provider
+	InstanceTypeProvider instancetype.Provider
+	ComputeClient        *gophercloud.ServiceClient
+	NetworkClient        *gophercloud.ServiceClient
 }
 
 func (r *OpenStackNodeClassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -20,18 +33,42 @@ func (r *OpenStackNodeClassReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	conditionSet := nodeClass.StatusConditions()
+	if err := r.reconcileHash(ctx, nodeClass); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	stored := nodeClass.DeepCopy()
+	r.reconcileImages(ctx, nodeClass)
+	r.reconcileNetworks(nodeClass)
+	r.reconcileSecurityGroups(nodeClass)
+	r.reconcileKeyPair(nodeClass)
+	r.reconcileFlavors(ctx, nodeClass)
+	r.reconcileQuota(nodeClass)
 
-	conditionSet.SetTrueWithReason(
-		status.ConditionReady,
-		"BypassedValidation",
-		"Status forced to Ready: True for debugging.",
-	)
-	if err := r.Client.Status().Update(ctx, nodeClass); err != nil {
-		return ctrl.Result{}, fmt.Errorf("error updating OpenStackNodeClass status: %w", err)
+	if !equality.Semantic.DeepEqual(stored.Status, nodeClass.Status) {
+		if err := r.Client.Status().Update(ctx, nodeClass); err != nil {
+			return ctrl.Result{}, fmt.Errorf("error updating OpenStackNodeClass status: %w", err)
+		}
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: readinessRequeueInterval}, nil
+}
+
+// reconcileHash stamps the static spec hash on the NodeClass so that it can be copied onto the
+// NodeClaims launched from it and compared later for drift.
+func (r *OpenStackNodeClassReconciler) reconcileHash(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass) error {
+	stored := nodeClass.DeepCopy()
+	nodeClass.Annotations = lo.Assign(nodeClass.Annotations, map[string]string{
+		v1openstack.AnnotationOpenStackNodeClassHash:        nodeClass.Hash(),
+		v1openstack.AnnotationOpenStackNodeClassHashVersion: v1openstack.OpenStackNodeClassHashVersion,
+	})
+	if equality.Semantic.DeepEqual(stored.Annotations, nodeClass.Annotations) {
+		return nil
+	}
+	if err := r.Client.Patch(ctx, nodeClass, client.MergeFrom(stored)); err != nil {
+		return fmt.Errorf("error patching OpenStackNodeClass hash annotations: %w", err)
+	}
+	return nil
 }
 
 func (r *OpenStackNodeClassReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/image"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/quota"
+)
+
+func TestReconcileReadiness(t *testing.T) {
+	glance := fake.NewGlance()
+	defer glance.Close()
+	glance.AddImage(fake.Image{ID: "image-1", Name: "ubuntu-24.04"})
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddNetwork(fake.Network{ID: "net-1", Name: "private"})
+	neutron.AddSecurityGroup(fake.SecurityGroup{ID: "sg-1", Name: "default"})
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddKeyPair("admin")
+
+	newNodeClass := func() *v1openstack.OpenStackNodeClass {
+		return &v1openstack.OpenStackNodeClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: v1openstack.OpenStackNodeClassSpec{
+				ImageSelectorTerms: []v1openstack.OpenStackImageSelectorTerm{{Alias: "ubuntu-24.04"}},
+				Networks:           []string{"net-1"},
+				SecurityGroups:     []string{"default"},
+				KeyPair:            "admin",
+			},
+		}
+	}
+
+	cases := []struct {
+		name      string
+		nodeClass func(*v1openstack.OpenStackNodeClass)
+		flavors   []flavors.Flavor
+		condition string
+		reason    string
+	}{
+		{name: "ready"},
+		{
+			name:      "image not found",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.ImageSelectorTerms[0].Alias = "ubuntu-2404" },
+			condition: v1openstack.ConditionTypeImagesReady,
+			reason:    "ImagesNotFound",
+		},
+		{
+			name:      "network not found",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.Networks = []string{"net-1", "net-2"} },
+			condition: v1openstack.ConditionTypeNetworksReady,
+			reason:    "NetworksNotFound",
+		},
+		{
+			name:      "security group not found",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.SecurityGroups = []string{"defualt"} },
+			condition: v1openstack.ConditionTypeSecurityGroupsReady,
+			reason:    "SecurityGroupsNotFound",
+		},
+		{
+			name:      "key pair not found",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.KeyPair = "root" },
+			condition: v1openstack.ConditionTypeKeyPairReady,
+			reason:    "KeyPairNotFound",
+		},
+		{
+			name:      "no flavors",
+			flavors:   []flavors.Flavor{},
+			condition: v1openstack.ConditionTypeFlavorsAvailable,
+			reason:    "FlavorsNotFound",
+		},
+		{
+			name: "no flavors selected",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.FlavorSelectorTerms = []v1openstack.OpenStackFlavorSelectorTerm{{Name: "g1.*"}}
+			},
+			condition: v1openstack.ConditionTypeFlavorsAvailable,
+			reason:    "FlavorsNotFound",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClass := newNodeClass()
+			if tc.nodeClass != nil {
+				tc.nodeClass(nodeClass)
+			}
+			flavorList := tc.flavors
+			if flavorList == nil {
+				flavorList = []flavors.Flavor{
+					{ID: "flavor-2", Name: "m1.small", VCPUs: 2, RAM: 4096},
+					{ID: "flavor-1", Name: "m1.medium", VCPUs: 4, RAM: 8192},
+				}
+			}
+
+			scheme := runtime.NewScheme()
+			require.NoError(t, v1openstack.AddToScheme(scheme))
+			kubeClient := clientfake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(nodeClass).
+				WithStatusSubresource(&v1openstack.OpenStackNodeClass{}).
+				Build()
+			instanceTypeProvider := &instancetype.DefaultProvider{
+				InstanceTypesInfo: flavorList,
+				Quota:             &quota.Remaining{Cores: 12, RAMMiB: quota.Unlimited, Instances: 3},
+			}
+			reconciler := &OpenStackNodeClassReconciler{
+				Client:               kubeClient,
+				ImageProvider:        image.NewProvider(glance.ServiceClient(), cache.New(time.Minute, time.Minute)),
+				InstanceTypeProvider: instanceTypeProvider,
+				ComputeClient:        nova.ServiceClient(),
+				NetworkClient:        neutron.ServiceClient(),
+			}
+
+			result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeClass.Name}})
+			require.NoError(t, err)
+			assert.Equal(t, readinessRequeueInterval, result.RequeueAfter)
+
+			updated := &v1openstack.OpenStackNodeClass{}
+			require.NoError(t, kubeClient.Get(ctx, client.ObjectKeyFromObject(nodeClass), updated))
+			if tc.condition == "" {
+				assert.True(t, updated.StatusConditions().IsTrue(status.ConditionReady), "expected Ready, got %v", updated.Status.Conditions)
+				assert.Equal(t, []v1openstack.Image{{ID: "image-1", Name: "ubuntu-24.04", Architecture: "amd64", OS: "linux"}}, updated.Status.Images)
+				assert.Equal(t, []v1openstack.Flavor{{ID: "flavor-1", Name: "m1.medium"}, {ID: "flavor-2", Name: "m1.small"}}, updated.Status.Flavors)
+				assert.Equal(t, &v1openstack.Quota{Cores: lo.ToPtr(int64(12)), Instances: lo.ToPtr(int64(3))}, updated.Status.Quota)
+				return
+			}
+			assert.True(t, updated.StatusConditions().Get(status.ConditionReady).IsFalse())
+			condition := updated.StatusConditions().Get(tc.condition)
+			assert.True(t, condition.IsFalse())
+			assert.Equal(t, tc.reason, condition.Reason)
+		})
+	}
+}
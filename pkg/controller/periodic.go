@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// periodJitter spreads the calls of the replicas to the OpenStack APIs, which would otherwise all
+// start at the same time after a rollout.
+const periodJitter = 0.1
+
+// runPeriodically calls f right away, then every interval after the previous call returned, until
+// the context is done.
+func runPeriodically(ctx context.Context, f func(context.Context), interval time.Duration) {
+	wait.JitterUntilWithContext(ctx, f, interval, periodJitter, true)
+}
+
+// perReplica is embedded in the refreshers of what the offerings are built from. They run on every
+// replica, each one serving offerings from its own view of the cloud.
+type perReplica struct{}
+
+func (perReplica) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPeriodically(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runPeriodically(ctx, func(context.Context) {
+			if calls.Add(1) == 3 {
+				cancel()
+			}
+		}, time.Millisecond)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected runPeriodically to return once the context is done")
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
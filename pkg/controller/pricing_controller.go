@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+)
+
+// PricingReconciler loads the flavor prices from the pricing ConfigMap. The default prices are used
+// while the ConfigMap doesn't exist, and the last valid prices are kept when it can't be parsed.
+type PricingReconciler struct {
+	// Client reads the pricing ConfigMap. SetupWithManager sets it to a cache holding that ConfigMap
+	// only, so that watching it requires no access to the ConfigMaps of other namespaces.
+	Client          client.Reader
+	PricingProvider *pricing.DefaultProvider
+	ConfigMap       types.NamespacedName
+}
+
+func (r *PricingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithName("pricing")
+	configMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, r.ConfigMap, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			logger.Info("pricing ConfigMap not found, using the default prices", "configMap", r.ConfigMap)
+			r.PricingProvider.UpdateConfig(pricing.DefaultConfig)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// A ConfigMap without the key is as invalid as an empty one, rather than free of charge.
+	config, err := pricing.Parse(configMap.Data[pricing.ConfigMapKey])
+	if err != nil {
+		// Requeuing would not help until the ConfigMap is fixed, which triggers a new reconcile.
+		logger.Error(err, "invalid pricing ConfigMap, keeping the previous prices", "configMap", r.ConfigMap)
+		return ctrl.Result{}, nil
+	}
+	r.PricingProvider.UpdateConfig(config)
+	logger.Info("loaded flavor prices", "configMap", r.ConfigMap, "flavors", len(config.Flavors), "zones", len(config.ZoneMultipliers))
+	return ctrl.Result{}, nil
+}
+
+func (r *PricingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	configMaps, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:            mgr.GetScheme(),
+		Mapper:            mgr.GetRESTMapper(),
+		DefaultNamespaces: map[string]cache.Config{r.ConfigMap.Namespace: {}},
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", r.ConfigMap.Name)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("creating pricing ConfigMap cache: %w", err)
+	}
+	if err := mgr.Add(configMaps); err != nil {
+		return fmt.Errorf("adding pricing ConfigMap cache: %w", err)
+	}
+	r.Client = configMaps
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pricing").
+		WatchesRawSource(source.Kind(configMaps, &corev1.ConfigMap{}, &handler.TypedEnqueueRequestForObject[*corev1.ConfigMap]{})).
+		Complete(r)
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+)
+
+func TestPricingReconcilerKeepsPricesOfInvalidConfigMaps(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "kube-system", Name: "karpenter-openstack-pricing"}
+	flavor := flavors.Flavor{Name: "m1.large", VCPUs: 4, RAM: 8192}
+
+	for name, data := range map[string]map[string]string{
+		"missing key": {"prices.yaml": "flavors:\n  m1.large: 1\n"},
+		"invalid":     {pricing.ConfigMapKey: "vcpu: -1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}, Data: map[string]string{pricing.ConfigMapKey: "flavors:\n  m1.large: 0.5\n"}}
+			kubeClient := clientfake.NewClientBuilder().WithObjects(configMap).Build()
+			reconciler := &PricingReconciler{Client: kubeClient, PricingProvider: pricing.NewProvider(), ConfigMap: key}
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			require.NoError(t, err)
+			require.Equal(t, 0.5, reconciler.PricingProvider.Price(flavor, ""))
+
+			configMap.Data = data
+			require.NoError(t, kubeClient.Update(ctx, configMap))
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+			require.NoError(t, err)
+			assert.Equal(t, 0.5, reconciler.PricingProvider.Price(flavor, ""))
+		})
+	}
+}
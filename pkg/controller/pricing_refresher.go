@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+)
+
+// DefaultPricingRefreshInterval is how often the flavor ratings are read from CloudKitty.
+const DefaultPricingRefreshInterval = 10 * time.Minute
+
+// DefaultPricingMaxStaleness is how long the last ratings read from CloudKitty are kept while it
+// can't be reached. Rating rules rarely change, so an outage shouldn't reorder the offerings.
+const DefaultPricingMaxStaleness = time.Hour
+
+var pricingRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "karpenter_openstack",
+	Name:      "pricing_refresh_errors_total",
+	Help:      "Number of failed flavor rating refreshes from CloudKitty.",
+})
+
+func init() {
+	crmetrics.Registry.MustRegister(pricingRefreshErrors)
+}
+
+// CloudKittyPricingRefresher keeps the flavor prices in line with the CloudKitty rating rules. While
+// CloudKitty can't be reached, flavors keep the last ratings read, and are priced from the static
+// pricing table once these are older than MaxStaleness.
+type CloudKittyPricingRefresher struct {
+	perReplica
+
+	PricingProvider *pricing.DefaultProvider
+	RatingClient    *gophercloud.ServiceClient
+	Interval        time.Duration
+	MaxStaleness    time.Duration
+
+	// lastRefresh is when the ratings were last read.
+	lastRefresh time.Time
+}
+
+func (r *CloudKittyPricingRefresher) Start(ctx context.Context) error {
+	runPeriodically(ctx, r.refresh, r.Interval)
+	return nil
+}
+
+func (r *CloudKittyPricingRefresher) refresh(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("pricing.cloudkitty")
+	ratings, err := pricing.Ratings(r.RatingClient)
+	if err != nil {
+		pricingRefreshErrors.Inc()
+		if !r.lastRefresh.IsZero() && time.Since(r.lastRefresh) < r.MaxStaleness {
+			logger.Error(err, "failed to refresh flavor ratings, keeping the last ones", "age", time.Since(r.lastRefresh).Round(time.Second))
+			return
+		}
+		logger.Error(err, "failed to refresh flavor ratings, pricing flavors from the static table")
+		r.PricingProvider.UpdateRatings(nil)
+		return
+	}
+	r.lastRefresh = time.Now()
+	r.PricingProvider.UpdateRatings(ratings)
+	logger.V(1).Info("refreshed flavor ratings", "flavors", len(ratings))
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+)
+
+func TestCloudKittyPricingRefresherKeepsLastRatings(t *testing.T) {
+	cloudKitty := fake.NewCloudKitty()
+	defer cloudKitty.Close()
+	cloudKitty.AddService("instance")
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "flavor_name", Value: "m1.large", Cost: "0.12"})
+
+	pricingProvider := pricing.NewProvider()
+	pricingProvider.UpdateConfig(pricing.Config{Flavors: map[string]float64{"m1.large": 0.3}})
+	refresher := &CloudKittyPricingRefresher{
+		PricingProvider: pricingProvider,
+		RatingClient:    cloudKitty.ServiceClient(),
+		MaxStaleness:    time.Hour,
+	}
+	flavor := flavors.Flavor{ID: "1", Name: "m1.large"}
+	ctx := context.Background()
+
+	refresher.refresh(ctx)
+	require.Equal(t, 0.12, pricingProvider.Price(flavor, ""))
+
+	cloudKitty.SetUnavailable(true)
+	refresher.refresh(ctx)
+	assert.Equal(t, 0.12, pricingProvider.Price(flavor, ""), "the last rating is kept while CloudKitty is unavailable")
+
+	refresher.lastRefresh = time.Now().Add(-2 * time.Hour)
+	refresher.refresh(ctx)
+	assert.Equal(t, 0.3, pricingProvider.Price(flavor, ""), "flavors are priced from the static table once the ratings are stale")
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+)
+
+// DefaultQuotaRefreshInterval is how often the quota left to the project is read from Nova. Usage
+// changes with every launch, so it is refreshed more often than the flavor catalog.
+const DefaultQuotaRefreshInterval = time.Minute
+
+var quotaRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "karpenter_openstack",
+	Name:      "quota_refresh_errors_total",
+	Help:      "Number of failed compute quota refreshes.",
+})
+
+func init() {
+	crmetrics.Registry.MustRegister(quotaRefreshErrors)
+}
+
+// QuotaRefresher keeps the quota left to the project up to date, so that the offerings of the
+// flavors that would exceed it are unavailable. While Nova can't be reached the last known quota is
+// kept.
+type QuotaRefresher struct {
+	perReplica
+
+	InstanceTypeProvider *instancetype.DefaultProvider
+	Interval             time.Duration
+}
+
+func (r *QuotaRefresher) Start(ctx context.Context) error {
+	runPeriodically(ctx, r.refresh, r.Interval)
+	return nil
+}
+
+func (r *QuotaRefresher) refresh(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("quota.refresher")
+	if err := r.InstanceTypeProvider.UpdateQuota(ctx); err != nil {
+		quotaRefreshErrors.Inc()
+		logger.Error(err, "failed to refresh compute quota, keeping the last known quota")
+	}
+}
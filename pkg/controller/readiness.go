@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	v1openstack "github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/quota"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func (r *OpenStackNodeClassReconciler) reconcileImages(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass) {
+	images, err := r.ImageProvider.List(ctx, nodeClass)
+	if err != nil {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeImagesReady, "ImagesResolutionFailed", fmt.Sprintf("Failed to resolve images: %s", err))
+		return
+	}
+	nodeClass.Status.Images = images
+	if len(images) == 0 {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeImagesReady, "ImagesNotFound", "ImageSelectorTerms did not match any active image")
+		return
+	}
+	nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeImagesReady)
+}
+
+func (r *OpenStackNodeClassReconciler) reconcileNetworks(nodeClass *v1openstack.OpenStackNodeClass) {
+	if len(nodeClass.Spec.Networks) == 0 {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeNetworksReady, "NetworksNotFound", "No networks specified")
+		return
+	}
+	networkIDs := nodeClass.Spec.Networks
+	if nodeClass.Spec.FloatingIP && nodeClass.Spec.FloatingIPNetwork != "" {
+		networkIDs = append(append([]string{}, networkIDs...), nodeClass.Spec.FloatingIPNetwork)
+	}
+	var missing []string
+	for _, id := range networkIDs {
+		if _, err := networks.Get(r.NetworkClient, id).Extract(); err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); ok {
+				missing = append(missing, id)
+				continue
+			}
+			nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeNetworksReady, "NetworksResolutionFailed", fmt.Sprintf("Failed to get network %s: %s", id, err))
+			return
+		}
+	}
+	if len(missing) > 0 {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeNetworksReady, "NetworksNotFound", fmt.Sprintf("Networks not found: %s", strings.Join(missing, ", ")))
+		return
+	}
+	nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeNetworksReady)
+}
+
+// reconcileSecurityGroups checks that every security group exists. Nova accepts either the name or
+// the ID of a group, so both are matched.
+func (r *OpenStackNodeClassReconciler) reconcileSecurityGroups(nodeClass *v1openstack.OpenStackNodeClass) {
+	if len(nodeClass.Spec.SecurityGroups) == 0 {
+		nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeSecurityGroupsReady)
+		return
+	}
+	pages, err := groups.List(r.NetworkClient, groups.ListOpts{}).AllPages()
+	if err != nil {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeSecurityGroupsReady, "SecurityGroupsResolutionFailed", fmt.Sprintf("Failed to list security groups: %s", err))
+		return
+	}
+	existing, err := groups.ExtractGroups(pages)
+	if err != nil {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeSecurityGroupsReady, "SecurityGroupsResolutionFailed", fmt.Sprintf("Failed to extract security groups: %s", err))
+		return
+	}
+	known := sets.New[string]()
+	for _, sg := range existing {
+		known.Insert(sg.ID, sg.Name)
+	}
+	if missing := lo.Reject(nodeClass.Spec.SecurityGroups, func(sg string, _ int) bool { return known.Has(sg) }); len(missing) > 0 {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeSecurityGroupsReady, "SecurityGroupsNotFound", fmt.Sprintf("Security groups not found: %s", strings.Join(missing, ", ")))
+		return
+	}
+	nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeSecurityGroupsReady)
+}
+
+func (r *OpenStackNodeClassReconciler) reconcileKeyPair(nodeClass *v1openstack.OpenStackNodeClass) {
+	if nodeClass.Spec.KeyPair == "" {
+		nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeKeyPairReady)
+		return
+	}
+	if _, err := keypairs.Get(r.ComputeClient, nodeClass.Spec.KeyPair, nil).Extract(); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeKeyPairReady, "KeyPairNotFound", fmt.Sprintf("Key pair %s not found", nodeClass.Spec.KeyPair))
+			return
+		}
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeKeyPairReady, "KeyPairResolutionFailed", fmt.Sprintf("Failed to get key pair %s: %s", nodeClass.Spec.KeyPair, err))
+		return
+	}
+	nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeKeyPairReady)
+}
+
+func (r *OpenStackNodeClassReconciler) reconcileFlavors(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass) {
+	selected, err := r.InstanceTypeProvider.SelectFlavors(ctx, nodeClass)
+	if err != nil {
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeFlavorsAvailable, "FlavorsResolutionFailed", fmt.Sprintf("Failed to list flavors: %s", err))
+		return
+	}
+	nodeClass.Status.Flavors = lo.Map(selected, func(flavor flavors.Flavor, _ int) v1openstack.Flavor {
+		return v1openstack.Flavor{ID: flavor.ID, Name: flavor.Name}
+	})
+	sort.Slice(nodeClass.Status.Flavors, func(i, j int) bool { return nodeClass.Status.Flavors[i].Name < nodeClass.Status.Flavors[j].Name })
+	if len(selected) == 0 {
+		message := "No flavors are available"
+		if len(nodeClass.Spec.FlavorSelectorTerms) > 0 {
+			message = "FlavorSelectorTerms did not match any flavor"
+		}
+		nodeClass.StatusConditions().SetFalse(v1openstack.ConditionTypeFlavorsAvailable, "FlavorsNotFound", message)
+		return
+	}
+	nodeClass.StatusConditions().SetTrue(v1openstack.ConditionTypeFlavorsAvailable)
+}
+
+// reconcileQuota reports the Nova quota left to the project. It is informational: running out of
+// quota makes the offerings unavailable rather than the NodeClass not ready.
+func (r *OpenStackNodeClassReconciler) reconcileQuota(nodeClass *v1openstack.OpenStackNodeClass) {
+	remaining := r.InstanceTypeProvider.RemainingQuota()
+	if remaining == nil {
+		nodeClass.Status.Quota = nil
+		return
+	}
+	limited := func(value int) *int64 {
+		return lo.Ternary(value == quota.Unlimited, nil, lo.ToPtr(int64(value)))
+	}
+	nodeClass.Status.Quota = &v1openstack.Quota{
+		Cores:     limited(remaining.Cores),
+		RAMMiB:    limited(remaining.RAMMiB),
+		Instances: limited(remaining.Instances),
+	}
+}
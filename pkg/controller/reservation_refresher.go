@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+)
+
+// DefaultReservationRefreshInterval is how often the Blazar leases of the project are read. Leases
+// start and end on schedule, and the reserved capacity left changes with every launch.
+const DefaultReservationRefreshInterval = time.Minute
+
+var reservationRefreshErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "karpenter_openstack",
+	Name:      "reservation_refresh_errors_total",
+	Help:      "Number of failed Blazar reservation refreshes.",
+})
+
+func init() {
+	crmetrics.Registry.MustRegister(reservationRefreshErrors)
+}
+
+// ReservationRefresher keeps the active Blazar reservations of the project up to date, so that the
+// flavors they can take are offered with the reserved capacity type. While Blazar can't be reached,
+// only on-demand offerings are served.
+type ReservationRefresher struct {
+	perReplica
+
+	InstanceTypeProvider *instancetype.DefaultProvider
+	Interval             time.Duration
+}
+
+func (r *ReservationRefresher) Start(ctx context.Context) error {
+	runPeriodically(ctx, r.refresh, r.Interval)
+	return nil
+}
+
+func (r *ReservationRefresher) refresh(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("reservation.refresher")
+	if err := r.InstanceTypeProvider.UpdateReservations(ctx); err != nil {
+		reservationRefreshErrors.Inc()
+		logger.Error(err, "failed to refresh Blazar reservations, offering on-demand capacity only")
+	}
+}
@@ -0,0 +1,206 @@
+package fake
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// Lease is the in-memory representation of a Blazar lease.
+type Lease struct {
+	ID           string
+	Name         string
+	Status       string
+	Reservations []Reservation
+}
+
+// Reservation is a reservation of a Blazar lease. Hosts lists the IDs of the hosts allocated to it.
+type Reservation struct {
+	ID           string
+	Status       string
+	ResourceType string
+	Amount       int
+	VCPUs        int
+	MemoryMB     int
+	DiskGB       int
+	Hosts        []string
+}
+
+// BlazarHost is a compute host enrolled in Blazar.
+type BlazarHost struct {
+	ID               string
+	Name             string
+	AvailabilityZone string
+	VCPUs            int
+	MemoryMB         int
+	LocalGB          int
+}
+
+// Blazar is an in-memory stand-in for the leases and hosts of the OpenStack reservation API.
+type Blazar struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	leases      []Lease
+	hosts       []BlazarHost
+	hostsDenied bool
+	unavailable bool
+}
+
+func NewBlazar() *Blazar {
+	b := &Blazar{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/leases", b.handleListLeases)
+	mux.HandleFunc("/v1/os-hosts", b.handleListHosts)
+	mux.HandleFunc("/v1/os-hosts/allocations", b.handleListAllocations)
+	b.Server = httptest.NewServer(mux)
+	return b
+}
+
+// ServiceClient returns a reservation client pointed at the fake endpoint.
+func (b *Blazar) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       b.URL + "/",
+		ResourceBase:   b.URL + "/v1/",
+	}
+}
+
+// AddLease seeds a lease. Status defaults to ACTIVE, and that of its reservations to active.
+func (b *Blazar) AddLease(lease Lease) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lease.Status == "" {
+		lease.Status = "ACTIVE"
+	}
+	for i := range lease.Reservations {
+		if lease.Reservations[i].Status == "" {
+			lease.Reservations[i].Status = "active"
+		}
+	}
+	b.leases = append(b.leases, lease)
+}
+
+// AddHost seeds a host that can be allocated to reservations.
+func (b *Blazar) AddHost(host BlazarHost) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hosts = append(b.hosts, host)
+}
+
+// SetHostsDenied makes the host requests fail with 403, like the default policy for non-admins.
+func (b *Blazar) SetHostsDenied(denied bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hostsDenied = denied
+}
+
+// SetUnavailable makes every request fail as if Blazar could not be reached.
+func (b *Blazar) SetUnavailable(unavailable bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unavailable = unavailable
+}
+
+func (b *Blazar) checkAvailable(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "error", "method not allowed")
+		return false
+	}
+	if b.unavailable {
+		writeError(w, http.StatusServiceUnavailable, "error", "Blazar is unavailable")
+		return false
+	}
+	return true
+}
+
+func (b *Blazar) handleListLeases(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.checkAvailable(w, r) {
+		return
+	}
+	out := []map[string]interface{}{}
+	for _, lease := range b.leases {
+		reservations := []map[string]interface{}{}
+		for _, reservation := range lease.Reservations {
+			view := map[string]interface{}{
+				"id":            reservation.ID,
+				"lease_id":      lease.ID,
+				"status":        reservation.Status,
+				"resource_type": reservation.ResourceType,
+			}
+			if reservation.ResourceType == "virtual:instance" {
+				view["amount"] = reservation.Amount
+				view["vcpus"] = reservation.VCPUs
+				view["memory_mb"] = reservation.MemoryMB
+				view["disk_gb"] = reservation.DiskGB
+				view["flavor_id"] = reservation.ID
+			} else {
+				view["min"] = len(reservation.Hosts)
+				view["max"] = len(reservation.Hosts)
+			}
+			reservations = append(reservations, view)
+		}
+		out = append(out, map[string]interface{}{
+			"id":           lease.ID,
+			"name":         lease.Name,
+			"status":       lease.Status,
+			"reservations": reservations,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leases": out})
+}
+
+func (b *Blazar) handleListHosts(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.checkAvailable(w, r) || !b.checkHostsAllowed(w) {
+		return
+	}
+	out := []map[string]interface{}{}
+	for _, host := range b.hosts {
+		out = append(out, map[string]interface{}{
+			"id":                  host.ID,
+			"hypervisor_hostname": host.Name,
+			"availability_zone":   host.AvailabilityZone,
+			"vcpus":               host.VCPUs,
+			"memory_mb":           host.MemoryMB,
+			"local_gb":            host.LocalGB,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"hosts": out})
+}
+
+func (b *Blazar) handleListAllocations(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.checkAvailable(w, r) || !b.checkHostsAllowed(w) {
+		return
+	}
+	allocations := map[string][]map[string]string{}
+	for _, lease := range b.leases {
+		for _, reservation := range lease.Reservations {
+			for _, host := range reservation.Hosts {
+				allocations[host] = append(allocations[host], map[string]string{"id": reservation.ID, "lease_id": lease.ID})
+			}
+		}
+	}
+	out := []map[string]interface{}{}
+	for _, host := range b.hosts {
+		if reservations, ok := allocations[host.ID]; ok {
+			out = append(out, map[string]interface{}{"resource_id": host.ID, "reservations": reservations})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"allocations": out})
+}
+
+func (b *Blazar) checkHostsAllowed(w http.ResponseWriter) bool {
+	if b.hostsDenied {
+		writeError(w, http.StatusForbidden, "error", "Policy doesn't allow blazar:oshosts:get to be performed.")
+		return false
+	}
+	return true
+}
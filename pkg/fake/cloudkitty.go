@@ -0,0 +1,141 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/samber/lo"
+)
+
+// RatingMapping is the in-memory representation of a CloudKitty hashmap mapping.
+type RatingMapping struct {
+	Field    string
+	Value    string
+	Cost     string
+	Type     string
+	TenantID string
+}
+
+// CloudKitty is an in-memory stand-in for the hashmap module of the CloudKitty rating API.
+type CloudKitty struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	services    map[string]string
+	mappings    []RatingMapping
+	unavailable bool
+}
+
+func NewCloudKitty() *CloudKitty {
+	c := &CloudKitty{services: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/rating/module_config/hashmap/services", c.handleListServices)
+	mux.HandleFunc("/v1/rating/module_config/hashmap/fields", c.handleListFields)
+	mux.HandleFunc("/v1/rating/module_config/hashmap/mappings", c.handleListMappings)
+	c.Server = httptest.NewServer(mux)
+	return c
+}
+
+// ServiceClient returns a rating client pointed at the fake endpoint.
+func (c *CloudKitty) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       c.URL + "/",
+	}
+}
+
+// AddService seeds a hashmap service, e.g. "instance".
+func (c *CloudKitty) AddService(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services[name] = fmt.Sprintf("service-%s", name)
+}
+
+// AddMapping seeds a mapping of a field of the instance service. The type defaults to flat.
+func (c *CloudKitty) AddMapping(mapping RatingMapping) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mapping.Type == "" {
+		mapping.Type = "flat"
+	}
+	c.mappings = append(c.mappings, mapping)
+}
+
+// SetUnavailable makes every request fail with 503, like a rating service that is down.
+func (c *CloudKitty) SetUnavailable(unavailable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unavailable = unavailable
+}
+
+func (c *CloudKitty) checkAvailable(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "error", "method not allowed")
+		return false
+	}
+	if c.unavailable {
+		writeError(w, http.StatusServiceUnavailable, "error", "rating service unavailable")
+		return false
+	}
+	return true
+}
+
+func (c *CloudKitty) handleListServices(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkAvailable(w, r) {
+		return
+	}
+	out := []map[string]interface{}{}
+	for name, id := range c.services {
+		out = append(out, map[string]interface{}{"service_id": id, "name": name})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"services": out})
+}
+
+func (c *CloudKitty) handleListFields(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkAvailable(w, r) {
+		return
+	}
+	serviceID := r.URL.Query().Get("service_id")
+	out := []map[string]interface{}{}
+	if serviceID == c.services["instance"] {
+		for _, field := range lo.Uniq(lo.Map(c.mappings, func(m RatingMapping, _ int) string { return m.Field })) {
+			out = append(out, map[string]interface{}{"field_id": "field-" + field, "name": field, "service_id": serviceID})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"fields": out})
+}
+
+func (c *CloudKitty) handleListMappings(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkAvailable(w, r) {
+		return
+	}
+	fieldID := r.URL.Query().Get("field_id")
+	out := []map[string]interface{}{}
+	for i, mapping := range c.mappings {
+		if "field-"+mapping.Field != fieldID {
+			continue
+		}
+		view := map[string]interface{}{
+			"mapping_id": fmt.Sprintf("mapping-%d", i),
+			"field_id":   fieldID,
+			"value":      mapping.Value,
+			"cost":       mapping.Cost,
+			"type":       mapping.Type,
+			"tenant_id":  nil,
+		}
+		if mapping.TenantID != "" {
+			view["tenant_id"] = mapping.TenantID
+		}
+		out = append(out, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"mappings": out})
+}
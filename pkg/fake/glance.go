@@ -0,0 +1,131 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/samber/lo"
+)
+
+// Image is the in-memory representation of a Glance image.
+type Image struct {
+	ID         string
+	Name       string
+	Status     string
+	Tags       []string
+	Properties map[string]string
+	Created    time.Time
+}
+
+// Glance is an in-memory stand-in for the OpenStack Image v2 API. It implements image get and
+// list with the name, status and tag filters.
+type Glance struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	images   map[string]*Image
+	requests int
+}
+
+func NewGlance() *Glance {
+	g := &Glance{
+		images: map[string]*Image{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/images", g.handleListImages)
+	mux.HandleFunc("/v2/images/", g.handleImage)
+	g.Server = httptest.NewServer(mux)
+	return g
+}
+
+// ServiceClient returns an image client pointed at the fake endpoint.
+func (g *Glance) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       g.URL + "/",
+		ResourceBase:   g.URL + "/v2/",
+	}
+}
+
+// AddImage seeds an image. Images default to active.
+func (g *Glance) AddImage(img Image) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if img.Status == "" {
+		img.Status = "active"
+	}
+	if img.Created.IsZero() {
+		img.Created = time.Now().UTC()
+	}
+	g.images[img.ID] = &img
+}
+
+// Requests returns the number of requests served so far.
+func (g *Glance) Requests() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.requests
+}
+
+func (g *Glance) handleListImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests++
+	out := []interface{}{}
+	for _, img := range g.images {
+		if name := query.Get("name"); name != "" && img.Name != name {
+			continue
+		}
+		if status := query.Get("status"); status != "" && img.Status != status {
+			continue
+		}
+		if len(lo.Without(query["tag"], img.Tags...)) > 0 {
+			continue
+		}
+		out = append(out, img.view())
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"images": out})
+}
+
+func (g *Glance) handleImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v2/images/")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests++
+	img, ok := g.images[id]
+	if !ok {
+		http.Error(w, fmt.Sprintf("No image found with ID %s", id), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, img.view())
+}
+
+func (img *Image) view() map[string]interface{} {
+	v := map[string]interface{}{
+		"id":         img.ID,
+		"name":       img.Name,
+		"status":     img.Status,
+		"tags":       lo.Ternary(img.Tags == nil, []string{}, img.Tags),
+		"visibility": "public",
+		"created_at": img.Created.Format(time.RFC3339),
+		"updated_at": img.Created.Format(time.RFC3339),
+	}
+	for k, value := range img.Properties {
+		v[k] = value
+	}
+	return v
+}
@@ -0,0 +1,269 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/samber/lo"
+)
+
+// Network is the in-memory representation of a Neutron network.
+type Network struct {
+	ID   string
+	Name string
+}
+
+// SecurityGroup is the in-memory representation of a Neutron security group.
+type SecurityGroup struct {
+	ID   string
+	Name string
+}
+
+// Port is the in-memory representation of a Neutron port.
+type Port struct {
+	ID        string
+	NetworkID string
+	DeviceID  string
+}
+
+// FloatingIP is the in-memory representation of a Neutron floating IP.
+type FloatingIP struct {
+	ID                string
+	Address           string
+	FloatingNetworkID string
+	PortID            string
+	Description       string
+}
+
+// Neutron is an in-memory stand-in for the OpenStack Networking v2 API.
+type Neutron struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	networks       map[string]*Network
+	securityGroups map[string]*SecurityGroup
+	ports          map[string]*Port
+	floatingIPs    map[string]*FloatingIP
+	nextID         int
+}
+
+func NewNeutron() *Neutron {
+	n := &Neutron{
+		networks:       map[string]*Network{},
+		securityGroups: map[string]*SecurityGroup{},
+		ports:          map[string]*Port{},
+		floatingIPs:    map[string]*FloatingIP{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2.0/networks/", n.handleNetwork)
+	mux.HandleFunc("/v2.0/security-groups", n.handleListSecurityGroups)
+	mux.HandleFunc("/v2.0/ports", n.handleListPorts)
+	mux.HandleFunc("/v2.0/floatingips", n.handleFloatingIPs)
+	mux.HandleFunc("/v2.0/floatingips/", n.handleFloatingIP)
+	n.Server = httptest.NewServer(mux)
+	return n
+}
+
+// ServiceClient returns a network client pointed at the fake endpoint.
+func (n *Neutron) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       n.URL + "/",
+		ResourceBase:   n.URL + "/v2.0/",
+	}
+}
+
+// AddNetwork seeds a network.
+func (n *Neutron) AddNetwork(network Network) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.networks[network.ID] = &network
+}
+
+// AddSecurityGroup seeds a security group.
+func (n *Neutron) AddSecurityGroup(sg SecurityGroup) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.securityGroups[sg.ID] = &sg
+}
+
+// AddPort seeds a port, e.g. the port Nova would have created for a server.
+func (n *Neutron) AddPort(port Port) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.ports[port.ID] = &port
+}
+
+// AddFloatingIP seeds a floating IP.
+func (n *Neutron) AddFloatingIP(fip FloatingIP) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.floatingIPs[fip.ID] = &fip
+}
+
+// FloatingIPs returns copies of all stored floating IPs.
+func (n *Neutron) FloatingIPs() []FloatingIP {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]FloatingIP, 0, len(n.floatingIPs))
+	for _, fip := range n.floatingIPs {
+		out = append(out, *fip)
+	}
+	return out
+}
+
+func (n *Neutron) handleNetwork(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v2.0/networks/")
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	network, ok := n.networks[id]
+	if !ok {
+		writeNeutronError(w, http.StatusNotFound, "NetworkNotFound", fmt.Sprintf("Network %s could not be found.", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"network": map[string]interface{}{
+			"id":             network.ID,
+			"name":           network.Name,
+			"status":         "ACTIVE",
+			"admin_state_up": true,
+			"subnets":        []string{},
+		},
+	})
+}
+
+func (n *Neutron) handleListSecurityGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := []interface{}{}
+	for _, sg := range n.securityGroups {
+		if name := query.Get("name"); name != "" && sg.Name != name {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"id":                   sg.ID,
+			"name":                 sg.Name,
+			"security_group_rules": []interface{}{},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"security_groups": out})
+}
+
+func (n *Neutron) handleListPorts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := []interface{}{}
+	for _, port := range n.ports {
+		if deviceID := query.Get("device_id"); deviceID != "" && port.DeviceID != deviceID {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"id":         port.ID,
+			"network_id": port.NetworkID,
+			"device_id":  port.DeviceID,
+			"status":     "ACTIVE",
+			"fixed_ips":  []interface{}{},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ports": out})
+}
+
+func (n *Neutron) handleFloatingIPs(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		out := []interface{}{}
+		for _, fip := range n.floatingIPs {
+			if description := query.Get("description"); description != "" && fip.Description != description {
+				continue
+			}
+			if portID := query.Get("port_id"); portID != "" && fip.PortID != portID {
+				continue
+			}
+			out = append(out, fip.view())
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"floatingips": out})
+	case http.MethodPost:
+		var body struct {
+			FloatingIP struct {
+				FloatingNetworkID string `json:"floating_network_id"`
+				PortID            string `json:"port_id"`
+				Description       string `json:"description"`
+			} `json:"floatingip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeNeutronError(w, http.StatusBadRequest, "HTTPBadRequest", err.Error())
+			return
+		}
+		if _, ok := n.networks[body.FloatingIP.FloatingNetworkID]; !ok {
+			writeNeutronError(w, http.StatusNotFound, "ExternalGatewayForFloatingIPNotFound", fmt.Sprintf("Network %s could not be found.", body.FloatingIP.FloatingNetworkID))
+			return
+		}
+		n.nextID++
+		fip := &FloatingIP{
+			ID:                fmt.Sprintf("fip-%d", n.nextID),
+			Address:           fmt.Sprintf("203.0.113.%d", n.nextID),
+			FloatingNetworkID: body.FloatingIP.FloatingNetworkID,
+			PortID:            body.FloatingIP.PortID,
+			Description:       body.FloatingIP.Description,
+		}
+		n.floatingIPs[fip.ID] = fip
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"floatingip": fip.view()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (n *Neutron) handleFloatingIP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v2.0/floatingips/")
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.floatingIPs[id]; !ok {
+		writeNeutronError(w, http.StatusNotFound, "FloatingIPNotFound", fmt.Sprintf("Floating IP %s could not be found", id))
+		return
+	}
+	delete(n.floatingIPs, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fip *FloatingIP) view() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  fip.ID,
+		"floating_ip_address": fip.Address,
+		"floating_network_id": fip.FloatingNetworkID,
+		"port_id":             lo.Ternary[interface{}](fip.PortID == "", nil, fip.PortID),
+		"description":         fip.Description,
+		"status":              lo.Ternary(fip.PortID == "", "DOWN", "ACTIVE"),
+	}
+}
+
+func writeNeutronError(w http.ResponseWriter, statusCode int, errorType, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"NeutronError": map[string]interface{}{"type": errorType, "message": message, "detail": ""},
+	})
+}
@@ -0,0 +1,581 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+const TokenID = "fake-token"
+
+// Fault describes the error Nova reports on a server that ended up in ERROR.
+type Fault struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Rejection is an HTTP error returned by POST /servers before any server is scheduled.
+type Rejection struct {
+	StatusCode int
+	Message    string
+}
+
+// Server is the in-memory representation of a Nova server.
+type Server struct {
+	ID             string
+	Name           string
+	Status         string
+	FlavorID       string
+	ImageID        string
+	Zone           string
+	Metadata       map[string]string
+	Networks       []string
+	SecurityGroups []string
+	Fault          *Fault
+	Created        time.Time
+
+	// buildPolls is the number of GET requests left before the server leaves BUILD.
+	buildPolls int
+	// settleStatus is the status the server takes once it leaves BUILD.
+	settleStatus string
+}
+
+// Flavor is the in-memory representation of a Nova flavor.
+type Flavor struct {
+	ID    string
+	Name  string
+	VCPUs int
+	RAM   int
+	Disk  int
+
+	ExtraSpecs map[string]string
+}
+
+// Aggregate is the in-memory representation of a Nova host aggregate.
+type Aggregate struct {
+	Name             string
+	AvailabilityZone string
+	Hosts            []string
+}
+
+// Limits is the compute quota of the project. -1 means unlimited.
+type Limits struct {
+	MaxTotalCores     int
+	MaxTotalRAMSize   int
+	MaxTotalInstances int
+}
+
+// Nova is an in-memory stand-in for the OpenStack Compute API. It implements enough of
+// the servers API to exercise the full create, get, list and delete lifecycle.
+type Nova struct {
+	*httptest.Server
+
+	// BuildPolls is the number of GET requests a new server answers with BUILD before it settles.
+	BuildPolls int
+	// Faults sends servers launched with the given flavor to ERROR with the given fault.
+	Faults map[string]Fault
+	// Rejections makes POST /servers fail for the given flavor with the given HTTP error.
+	Rejections map[string]Rejection
+	// ExtraSpecsFailures makes GET /flavors/{id}/os-extra_specs fail with 503 for the given flavors.
+	ExtraSpecsFailures map[string]bool
+	// GetFailures is the number of GET requests on a server answered with 503 before Nova recovers.
+	GetFailures int
+
+	mu           sync.Mutex
+	servers      map[string]*Server
+	keyPairs     map[string]bool
+	flavors      map[string]*Flavor
+	zones        map[string]bool
+	createBodies [][]byte
+	nextID       int
+	// flavorsUnavailable makes GET /flavors/detail fail as if Nova could not be reached.
+	flavorsUnavailable bool
+	limits             Limits
+	aggregates         []Aggregate
+}
+
+func NewNova() *Nova {
+	n := &Nova{
+		Faults:             map[string]Fault{},
+		Rejections:         map[string]Rejection{},
+		ExtraSpecsFailures: map[string]bool{},
+		servers:            map[string]*Server{},
+		keyPairs:           map[string]bool{},
+		flavors:            map[string]*Flavor{},
+		zones:              map[string]bool{},
+		limits:             Limits{MaxTotalCores: -1, MaxTotalRAMSize: -1, MaxTotalInstances: -1},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/servers", n.handleServers)
+	mux.HandleFunc("/servers/detail", n.handleListServers)
+	mux.HandleFunc("/servers/", n.handleServer)
+	mux.HandleFunc("/os-keypairs/", n.handleKeyPair)
+	mux.HandleFunc("/flavors/detail", n.handleListFlavors)
+	mux.HandleFunc("/limits", n.handleGetLimits)
+	mux.HandleFunc("/flavors/", n.handleFlavorExtraSpecs)
+	mux.HandleFunc("/os-availability-zone", n.handleListAvailabilityZones)
+	mux.HandleFunc("/os-aggregates", n.handleListAggregates)
+	n.Server = httptest.NewServer(mux)
+	return n
+}
+
+// ServiceClient returns a compute client pointed at the fake endpoint.
+func (n *Nova) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       n.URL + "/",
+	}
+}
+
+// AddServer seeds a server as if it had been created earlier.
+func (n *Nova) AddServer(s Server) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if s.Status == "" {
+		s.Status = "ACTIVE"
+	}
+	if s.Created.IsZero() {
+		s.Created = time.Now().UTC()
+	}
+	n.servers[s.ID] = &s
+}
+
+// AddKeyPair seeds a key pair.
+func (n *Nova) AddKeyPair(name string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.keyPairs[name] = true
+}
+
+// AddFlavor seeds or replaces a flavor.
+func (n *Nova) AddFlavor(f Flavor) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flavors[f.ID] = &f
+}
+
+// RemoveFlavor deletes a flavor.
+func (n *Nova) RemoveFlavor(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.flavors, id)
+}
+
+// AddAvailabilityZone seeds an availability zone. Once a zone is seeded, servers can only be created
+// in the available zones.
+func (n *Nova) AddAvailabilityZone(name string, available bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.zones[name] = available
+}
+
+// SetFlavorsUnavailable makes listing flavors fail with 503 Service Unavailable.
+func (n *Nova) SetFlavorsUnavailable(unavailable bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flavorsUnavailable = unavailable
+}
+
+// AddAggregate seeds a host aggregate.
+func (n *Nova) AddAggregate(a Aggregate) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.aggregates = append(n.aggregates, a)
+}
+
+// SetLimits sets the compute quota of the project. The quota is not enforced, usage is reported from
+// the stored servers and the flavors they were launched with.
+func (n *Nova) SetLimits(limits Limits) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.limits = limits
+}
+
+// GetServer returns a copy of the stored server, if it exists.
+func (n *Nova) GetServer(id string) (Server, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, ok := n.servers[id]
+	if !ok {
+		return Server{}, false
+	}
+	return *s, true
+}
+
+// Servers returns copies of all stored servers.
+func (n *Nova) Servers() []Server {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([]Server, 0, len(n.servers))
+	for _, s := range n.servers {
+		out = append(out, *s)
+	}
+	return out
+}
+
+// CreateRequests returns the raw bodies of every POST /servers request received.
+func (n *Nova) CreateRequests() [][]byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([][]byte{}, n.createBodies...)
+}
+
+func (n *Nova) handleServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Server struct {
+			Name           string              `json:"name"`
+			FlavorRef      string              `json:"flavorRef"`
+			ImageRef       string              `json:"imageRef"`
+			Zone           string              `json:"availability_zone"`
+			Metadata       map[string]string   `json:"metadata"`
+			SecurityGroups []map[string]string `json:"security_groups"`
+			Networks       []map[string]string `json:"networks"`
+		} `json:"server"`
+	}
+	raw, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		writeError(w, http.StatusBadRequest, "badRequest", err.Error())
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.createBodies = append(n.createBodies, raw)
+
+	if rejection, ok := n.Rejections[body.Server.FlavorRef]; ok {
+		writeError(w, rejection.StatusCode, errorKey(rejection.StatusCode), rejection.Message)
+		return
+	}
+
+	zone := body.Server.Zone
+	if zone == "" {
+		zone = "nova"
+	} else if len(n.zones) > 0 && !n.zones[zone] {
+		writeError(w, http.StatusBadRequest, "badRequest", "The requested availability zone is not available")
+		return
+	}
+
+	n.nextID++
+	s := &Server{
+		ID:           fmt.Sprintf("server-%d", n.nextID),
+		Name:         body.Server.Name,
+		Status:       "BUILD",
+		FlavorID:     body.Server.FlavorRef,
+		ImageID:      body.Server.ImageRef,
+		Zone:         zone,
+		Metadata:     body.Server.Metadata,
+		Created:      time.Now().UTC(),
+		buildPolls:   n.BuildPolls,
+		settleStatus: "ACTIVE",
+	}
+	for _, sg := range body.Server.SecurityGroups {
+		s.SecurityGroups = append(s.SecurityGroups, sg["name"])
+	}
+	for _, network := range body.Server.Networks {
+		s.Networks = append(s.Networks, network["uuid"])
+	}
+	if fault, ok := n.Faults[body.Server.FlavorRef]; ok {
+		s.settleStatus = "ERROR"
+		s.Fault = &fault
+	}
+	n.servers[s.ID] = s
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"server": map[string]interface{}{"id": s.ID, "links": []interface{}{}},
+	})
+}
+
+func (n *Nova) handleListServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := []interface{}{}
+	for _, s := range n.servers {
+		out = append(out, s.view())
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"servers": out})
+}
+
+func (n *Nova) handleServer(w http.ResponseWriter, r *http.Request) {
+	id, subresource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/servers/"), "/")
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, ok := n.servers[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", fmt.Sprintf("Instance %s could not be found.", id))
+		return
+	}
+	switch subresource {
+	case "":
+	case "os-interface":
+		n.handleInterfaces(w, r, s)
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if n.GetFailures > 0 {
+			n.GetFailures--
+			writeError(w, http.StatusServiceUnavailable, "serviceUnavailable", "The server is currently unavailable.")
+			return
+		}
+		if s.Status == "BUILD" {
+			if s.buildPolls > 0 {
+				s.buildPolls--
+			} else {
+				s.Status = s.settleStatus
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"server": s.view()})
+	case http.MethodDelete:
+		delete(n.servers, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (n *Nova) handleInterfaces(w http.ResponseWriter, r *http.Request, s *Server) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	attachments := []interface{}{}
+	for i, network := range s.Networks {
+		attachments = append(attachments, map[string]interface{}{
+			"net_id":     network,
+			"port_id":    fmt.Sprintf("%s-port-%d", s.ID, i),
+			"port_state": "ACTIVE",
+			"fixed_ips":  []interface{}{},
+			"mac_addr":   fmt.Sprintf("fa:16:3e:00:00:%02x", i),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"interfaceAttachments": attachments})
+}
+
+func (n *Nova) handleKeyPair(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/os-keypairs/")
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.keyPairs[name] {
+		writeError(w, http.StatusNotFound, "itemNotFound", fmt.Sprintf("Keypair %s not found for user fake", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keypair": map[string]interface{}{"name": name, "public_key": "ssh-ed25519 AAAA fake", "fingerprint": "fake", "user_id": "fake"},
+	})
+}
+
+func (n *Nova) handleListFlavors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.flavorsUnavailable {
+		writeError(w, http.StatusServiceUnavailable, "computeFault", "Service Unavailable")
+		return
+	}
+	out := []interface{}{}
+	for _, f := range n.flavors {
+		out = append(out, map[string]interface{}{
+			"id":                         f.ID,
+			"name":                       f.Name,
+			"vcpus":                      f.VCPUs,
+			"ram":                        f.RAM,
+			"disk":                       f.Disk,
+			"swap":                       "",
+			"rxtx_factor":                1.0,
+			"os-flavor-access:is_public": true,
+			"OS-FLV-EXT-DATA:ephemeral":  0,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"flavors": out})
+}
+
+func (n *Nova) handleGetLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var cores, ram int
+	for _, s := range n.servers {
+		if f, ok := n.flavors[s.FlavorID]; ok {
+			cores += f.VCPUs
+			ram += f.RAM
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"limits": map[string]interface{}{
+			"rate": []interface{}{},
+			"absolute": map[string]interface{}{
+				"maxTotalCores":      n.limits.MaxTotalCores,
+				"maxTotalRAMSize":    n.limits.MaxTotalRAMSize,
+				"maxTotalInstances":  n.limits.MaxTotalInstances,
+				"totalCoresUsed":     cores,
+				"totalRAMUsed":       ram,
+				"totalInstancesUsed": len(n.servers),
+			},
+		},
+	})
+}
+
+func (n *Nova) handleFlavorExtraSpecs(w http.ResponseWriter, r *http.Request) {
+	id, subresource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/flavors/"), "/")
+	if r.Method != http.MethodGet || subresource != "os-extra_specs" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, ok := n.flavors[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "itemNotFound", fmt.Sprintf("Flavor %s could not be found.", id))
+		return
+	}
+	if n.ExtraSpecsFailures[id] {
+		writeError(w, http.StatusServiceUnavailable, "serviceUnavailable", "The server is currently unavailable.")
+		return
+	}
+	extraSpecs := f.ExtraSpecs
+	if extraSpecs == nil {
+		extraSpecs = map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"extra_specs": extraSpecs})
+}
+
+func (n *Nova) handleListAggregates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := []interface{}{}
+	for i, a := range n.aggregates {
+		view := map[string]interface{}{
+			"id":                i + 1,
+			"name":              a.Name,
+			"availability_zone": nil,
+			"hosts":             a.Hosts,
+			"metadata":          map[string]string{},
+			"deleted":           false,
+		}
+		if a.AvailabilityZone != "" {
+			view["availability_zone"] = a.AvailabilityZone
+			view["metadata"] = map[string]string{"availability_zone": a.AvailabilityZone}
+		}
+		out = append(out, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"aggregates": out})
+}
+
+func (n *Nova) handleListAvailabilityZones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := []interface{}{}
+	for name, available := range n.zones {
+		out = append(out, map[string]interface{}{
+			"zoneName":  name,
+			"zoneState": map[string]bool{"available": available},
+			"hosts":     nil,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"availabilityZoneInfo": out})
+}
+
+func (s *Server) view() map[string]interface{} {
+	securityGroups := []map[string]string{}
+	for _, sg := range s.SecurityGroups {
+		securityGroups = append(securityGroups, map[string]string{"name": sg})
+	}
+	v := map[string]interface{}{
+		"id":              s.ID,
+		"name":            s.Name,
+		"status":          s.Status,
+		"created":         s.Created.Format(time.RFC3339),
+		"updated":         s.Created.Format(time.RFC3339),
+		"flavor":          map[string]string{"id": s.FlavorID},
+		"image":           map[string]string{"id": s.ImageID},
+		"metadata":        s.Metadata,
+		"security_groups": securityGroups,
+		"addresses":       map[string]interface{}{},
+	}
+	if s.Zone != "" {
+		v["OS-EXT-AZ:availability_zone"] = s.Zone
+	}
+	if s.ImageID == "" {
+		v["image"] = ""
+	}
+	if s.Status == "ERROR" && s.Fault != nil {
+		v["fault"] = map[string]interface{}{
+			"code":    s.Fault.Code,
+			"message": s.Fault.Message,
+			"created": s.Created.Format(time.RFC3339),
+		}
+	}
+	return v
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+func errorKey(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "badRequest"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "itemNotFound"
+	case http.StatusConflict:
+		return "conflictingRequest"
+	default:
+		return "computeFault"
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, key, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		key: map[string]interface{}{"code": statusCode, "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,88 @@
+package fake
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+)
+
+func TestNovaServerLifecycle(t *testing.T) {
+	nova := NewNova()
+	defer nova.Close()
+	nova.BuildPolls = 1
+	client := nova.ServiceClient()
+
+	created, err := servers.Create(client, servers.CreateOpts{
+		Name:      "test",
+		FlavorRef: "m1.small",
+		ImageRef:  "image-1",
+		Metadata:  map[string]string{"owner": "karpenter"},
+	}).Extract()
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	for _, expected := range []string{"BUILD", "ACTIVE"} {
+		server, err := servers.Get(client, created.ID).Extract()
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if server.Status != expected {
+			t.Fatalf("expected status %s, got %s", expected, server.Status)
+		}
+		if server.Image["id"] != "image-1" || server.Flavor["id"] != "m1.small" {
+			t.Fatalf("unexpected image/flavor: %v %v", server.Image, server.Flavor)
+		}
+	}
+
+	pages, err := servers.List(client, nil).AllPages()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	all, err := servers.ExtractServers(pages)
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if len(all) != 1 || all[0].Metadata["owner"] != "karpenter" {
+		t.Fatalf("unexpected servers: %+v", all)
+	}
+
+	if err := servers.Delete(client, created.ID).ExtractErr(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := servers.Get(client, created.ID).Extract(); err == nil {
+		t.Fatalf("expected not found after delete")
+	} else if _, ok := err.(gophercloud.ErrDefault404); !ok {
+		t.Fatalf("expected 404, got %T", err)
+	}
+}
+
+func TestNovaFaultsAndRejections(t *testing.T) {
+	nova := NewNova()
+	defer nova.Close()
+	nova.Faults["m1.large"] = Fault{Code: 500, Message: "No valid host was found."}
+	nova.Rejections["m1.xlarge"] = Rejection{StatusCode: http.StatusForbidden, Message: "Quota exceeded for instances"}
+	client := nova.ServiceClient()
+
+	created, err := servers.Create(client, servers.CreateOpts{Name: "test", FlavorRef: "m1.large"}).Extract()
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	server, err := servers.Get(client, created.ID).Extract()
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if server.Status != "ERROR" || server.Fault.Message != "No valid host was found." {
+		t.Fatalf("expected ERROR with fault, got %s %+v", server.Status, server.Fault)
+	}
+
+	_, err = servers.Create(client, servers.CreateOpts{Name: "test", FlavorRef: "m1.xlarge"}).Extract()
+	if _, ok := err.(gophercloud.ErrDefault403); !ok {
+		t.Fatalf("expected 403, got %T (%v)", err, err)
+	}
+	if len(nova.CreateRequests()) != 2 {
+		t.Fatalf("expected 2 recorded create requests, got %d", len(nova.CreateRequests()))
+	}
+}
@@ -0,0 +1,199 @@
+package fake
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/samber/lo"
+)
+
+// Inventory is the inventory of a resource class of a resource provider.
+type Inventory struct {
+	Total           int
+	Reserved        int
+	AllocationRatio float32
+}
+
+// ResourceProvider is the in-memory representation of a Placement resource provider. Compute nodes
+// are named after their host, nested providers such as GPUs have a parent.
+type ResourceProvider struct {
+	UUID        string
+	Name        string
+	ParentUUID  string
+	Inventories map[string]Inventory
+	Usages      map[string]int
+}
+
+// Placement is an in-memory stand-in for the resource providers of the OpenStack Placement API.
+type Placement struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	providers   []*ResourceProvider
+	unavailable bool
+}
+
+func NewPlacement() *Placement {
+	p := &Placement{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/resource_providers", p.handleListResourceProviders)
+	mux.HandleFunc("/allocation_candidates", p.handleAllocationCandidates)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// ServiceClient returns a Placement client pointed at the fake endpoint.
+func (p *Placement) ServiceClient() *gophercloud.ServiceClient {
+	return &gophercloud.ServiceClient{
+		ProviderClient: &gophercloud.ProviderClient{TokenID: TokenID},
+		Endpoint:       p.URL + "/",
+		Microversion:   "1.29",
+	}
+}
+
+// AddResourceProvider seeds a resource provider.
+func (p *Placement) AddResourceProvider(rp ResourceProvider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.providers = append(p.providers, &rp)
+}
+
+// SetUsage sets the usage of a resource class of a resource provider, as if servers had been
+// scheduled to it.
+func (p *Placement) SetUsage(uuid, resourceClass string, used int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, rp := range p.providers {
+		if rp.UUID == uuid {
+			if rp.Usages == nil {
+				rp.Usages = map[string]int{}
+			}
+			rp.Usages[resourceClass] = used
+		}
+	}
+}
+
+// SetUnavailable makes every request fail with 403, like a user without the reader role.
+func (p *Placement) SetUnavailable(unavailable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unavailable = unavailable
+}
+
+func (p *Placement) checkAvailable(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "error", "method not allowed")
+		return false
+	}
+	if p.unavailable {
+		writeError(w, http.StatusForbidden, "error", "Policy does not allow placement:resource_providers:list to be performed.")
+		return false
+	}
+	return true
+}
+
+// root returns the UUID of the compute node a provider belongs to.
+func (p *Placement) root(rp *ResourceProvider) string {
+	for rp.ParentUUID != "" {
+		parent := p.find(rp.ParentUUID)
+		if parent == nil {
+			break
+		}
+		rp = parent
+	}
+	return rp.UUID
+}
+
+func (p *Placement) find(uuid string) *ResourceProvider {
+	for _, rp := range p.providers {
+		if rp.UUID == uuid {
+			return rp
+		}
+	}
+	return nil
+}
+
+func (p *Placement) handleListResourceProviders(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checkAvailable(w, r) {
+		return
+	}
+	out := []map[string]interface{}{}
+	for _, rp := range p.providers {
+		view := map[string]interface{}{
+			"uuid":                 rp.UUID,
+			"name":                 rp.Name,
+			"generation":           1,
+			"root_provider_uuid":   p.root(rp),
+			"parent_provider_uuid": nil,
+			"links":                []interface{}{},
+		}
+		if rp.ParentUUID != "" {
+			view["parent_provider_uuid"] = rp.ParentUUID
+		}
+		out = append(out, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"resource_providers": out})
+}
+
+// handleAllocationCandidates serves the provider summaries of the trees with the requested amount of
+// each resource class left, given as resources=<class>:<amount>,... and summed over the tree.
+func (p *Placement) handleAllocationCandidates(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checkAvailable(w, r) {
+		return
+	}
+	requested := map[string]int{}
+	for _, resource := range strings.Split(r.URL.Query().Get("resources"), ",") {
+		resourceClass, amount, _ := strings.Cut(resource, ":")
+		requested[resourceClass], _ = strconv.Atoi(amount)
+	}
+	free := map[string]map[string]int{}
+	for _, rp := range p.providers {
+		root := p.root(rp)
+		if free[root] == nil {
+			free[root] = map[string]int{}
+		}
+		for resourceClass := range rp.Inventories {
+			free[root][resourceClass] += rp.capacity(resourceClass) - rp.Usages[resourceClass]
+		}
+	}
+	summaries := map[string]interface{}{}
+	for _, rp := range p.providers {
+		root := p.root(rp)
+		if !lo.EveryBy(lo.Keys(requested), func(resourceClass string) bool {
+			_, ok := free[root][resourceClass]
+			return ok && free[root][resourceClass] >= requested[resourceClass]
+		}) {
+			continue
+		}
+		resources := map[string]interface{}{}
+		for resourceClass := range rp.Inventories {
+			resources[resourceClass] = map[string]int{"capacity": rp.capacity(resourceClass), "used": rp.Usages[resourceClass]}
+		}
+		summaries[rp.UUID] = map[string]interface{}{
+			"resources":            resources,
+			"traits":               []string{},
+			"root_provider_uuid":   root,
+			"parent_provider_uuid": lo.Ternary[interface{}](rp.ParentUUID != "", rp.ParentUUID, nil),
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"allocation_requests": []interface{}{}, "provider_summaries": summaries})
+}
+
+// capacity returns the total of the resource class less the reserved amount, overcommitted by the
+// allocation ratio.
+func (rp *ResourceProvider) capacity(resourceClass string) int {
+	inventory := rp.Inventories[resourceClass]
+	ratio := inventory.AllocationRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	return int(float32(inventory.Total-inventory.Reserved) * ratio)
+}
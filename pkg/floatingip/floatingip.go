@@ -0,0 +1,140 @@
+package floatingip
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+type Provider interface {
+	// Allocate creates a floating IP on the external network and associates it with the server's
+	// port on the given internal network. It returns the floating IP address.
+	Allocate(ctx context.Context, externalNetworkID, serverID, internalNetworkID string) (string, error)
+	// Release deletes every floating IP allocated for the server.
+	Release(ctx context.Context, serverID string) error
+	// ReleaseLeaked deletes the floating IPs owned by the cluster that are no longer associated
+	// with a port, e.g. because the controller crashed between deleting a server and its floating IP.
+	ReleaseLeaked(ctx context.Context) ([]string, error)
+}
+
+type DefaultProvider struct {
+	clusterName   string
+	networkClient *gophercloud.ServiceClient
+}
+
+func NewProvider(networkClient *gophercloud.ServiceClient, clusterName string) *DefaultProvider {
+	return &DefaultProvider{
+		clusterName:   clusterName,
+		networkClient: networkClient,
+	}
+}
+
+// description identifies the owner of a floating IP. Neutron floating IPs have no metadata, so the
+// cluster and server are encoded in the description, which the list API can filter on.
+func (p *DefaultProvider) description(serverID string) string {
+	return fmt.Sprintf("%s%s", p.clusterPrefix(), serverID)
+}
+
+func (p *DefaultProvider) clusterPrefix() string {
+	return fmt.Sprintf("%s=%s,server=", v1openstack.ClusterMetadataKey, p.clusterName)
+}
+
+func (p *DefaultProvider) Allocate(ctx context.Context, externalNetworkID, serverID, internalNetworkID string) (string, error) {
+	// A previous attempt may have allocated the floating IP before failing, reuse it.
+	existing, err := p.list(floatingips.ListOpts{Description: p.description(serverID)})
+	if err != nil {
+		return "", err
+	}
+	if len(existing) > 0 {
+		return existing[0].FloatingIP, nil
+	}
+
+	pages, err := ports.List(p.networkClient, ports.ListOpts{DeviceID: serverID}).AllPages()
+	if err != nil {
+		return "", fmt.Errorf("failed to list ports of server %s: %w", serverID, err)
+	}
+	serverPorts, err := ports.ExtractPorts(pages)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract ports of server %s: %w", serverID, err)
+	}
+	if len(serverPorts) == 0 {
+		return "", fmt.Errorf("server %s has no ports", serverID)
+	}
+	port, ok := lo.Find(serverPorts, func(port ports.Port) bool { return port.NetworkID == internalNetworkID })
+	if !ok {
+		port = serverPorts[0]
+	}
+
+	fip, err := floatingips.Create(p.networkClient, floatingips.CreateOpts{
+		Description:       p.description(serverID),
+		FloatingNetworkID: externalNetworkID,
+		PortID:            port.ID,
+	}).Extract()
+	if err != nil {
+		return "", fmt.Errorf("failed to create floating IP on network %s: %w", externalNetworkID, err)
+	}
+	log.FromContext(ctx).Info("allocated floating IP", "instanceID", serverID, "floatingIP", fip.FloatingIP, "portID", port.ID)
+	return fip.FloatingIP, nil
+}
+
+func (p *DefaultProvider) Release(ctx context.Context, serverID string) error {
+	fips, err := p.list(floatingips.ListOpts{Description: p.description(serverID)})
+	if err != nil {
+		return err
+	}
+	for _, fip := range fips {
+		if err := p.delete(fip); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("released floating IP", "instanceID", serverID, "floatingIP", fip.FloatingIP)
+	}
+	return nil
+}
+
+func (p *DefaultProvider) ReleaseLeaked(ctx context.Context) ([]string, error) {
+	fips, err := p.list(floatingips.ListOpts{})
+	if err != nil {
+		return nil, err
+	}
+	var released []string
+	for _, fip := range fips {
+		if fip.PortID != "" || !strings.HasPrefix(fip.Description, p.clusterPrefix()) {
+			continue
+		}
+		if err := p.delete(fip); err != nil {
+			return released, err
+		}
+		released = append(released, fip.FloatingIP)
+	}
+	return released, nil
+}
+
+func (p *DefaultProvider) list(opts floatingips.ListOpts) ([]floatingips.FloatingIP, error) {
+	pages, err := floatingips.List(p.networkClient, opts).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list floating IPs: %w", err)
+	}
+	fips, err := floatingips.ExtractFloatingIPs(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract floating IPs: %w", err)
+	}
+	return fips, nil
+}
+
+func (p *DefaultProvider) delete(fip floatingips.FloatingIP) error {
+	if err := floatingips.Delete(p.networkClient, fip.ID).ExtractErr(); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			return nil
+		}
+		return fmt.Errorf("failed to delete floating IP %s: %w", fip.FloatingIP, err)
+	}
+	return nil
+}
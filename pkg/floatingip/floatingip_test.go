@@ -0,0 +1,52 @@
+package floatingip
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func TestReleaseLeaked(t *testing.T) {
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	// Associated with a live server.
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "in-use", Address: "203.0.113.1", PortID: "port-1", Description: "karpenter.k8s.openstack/cluster=test-cluster,server=server-1"})
+	// Its server was deleted but the floating IP was not.
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "leaked", Address: "203.0.113.2", Description: "karpenter.k8s.openstack/cluster=test-cluster,server=server-2"})
+	// Owned by another cluster.
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "other-cluster", Address: "203.0.113.3", Description: "karpenter.k8s.openstack/cluster=other,server=server-3"})
+	// Not managed by Karpenter.
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "manual", Address: "203.0.113.4"})
+
+	released, err := NewProvider(neutron.ServiceClient(), "test-cluster").ReleaseLeaked(context.Background())
+	if err != nil {
+		t.Fatalf("ReleaseLeaked failed: %v", err)
+	}
+	if len(released) != 1 || released[0] != "203.0.113.2" {
+		t.Errorf("expected only 203.0.113.2 to be released, got %v", released)
+	}
+	if len(neutron.FloatingIPs()) != 3 {
+		t.Errorf("expected 3 floating IPs to remain, got %d", len(neutron.FloatingIPs()))
+	}
+}
+
+func TestAllocateReusesExistingFloatingIP(t *testing.T) {
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddNetwork(fake.Network{ID: "public"})
+	neutron.AddPort(fake.Port{ID: "port-1", NetworkID: "private", DeviceID: "server-1"})
+	provider := NewProvider(neutron.ServiceClient(), "test-cluster")
+
+	first, err := provider.Allocate(context.Background(), "public", "server-1", "private")
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	second, err := provider.Allocate(context.Background(), "public", "server-1", "private")
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if first != second || len(neutron.FloatingIPs()) != 1 {
+		t.Errorf("expected the floating IP to be reused, got %s and %s (%d allocated)", first, second, len(neutron.FloatingIPs()))
+	}
+}
@@ -0,0 +1,157 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/imageservice/v2/images"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+const (
+	// ArchitectureProperty is the Glance image property holding the CPU architecture of the image.
+	ArchitectureProperty = "architecture"
+	// OSProperty is the Glance image property holding the operating system of the image.
+	OSProperty = "os_type"
+)
+
+type Provider interface {
+	List(context.Context, *v1openstack.OpenStackNodeClass) ([]v1openstack.Image, error)
+}
+
+type DefaultProvider struct {
+	imageClient *gophercloud.ServiceClient
+	cache       *cache.Cache
+}
+
+func NewProvider(imageClient *gophercloud.ServiceClient, cache *cache.Cache) *DefaultProvider {
+	return &DefaultProvider{
+		imageClient: imageClient,
+		cache:       cache,
+	}
+}
+
+// List resolves the NodeClass ImageSelectorTerms against Glance. It returns the newest active image
+// for every architecture and operating system, ordered from newest to oldest.
+func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass) ([]v1openstack.Image, error) {
+	terms := nodeClass.Spec.ImageSelectorTerms
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("no imageSelectorTerms specified")
+	}
+	hash, err := hashstructure.Hash(terms, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash imageSelectorTerms: %w", err)
+	}
+	key := fmt.Sprint(hash)
+	if cached, ok := p.cache.Get(key); ok {
+		return append([]v1openstack.Image{}, cached.([]v1openstack.Image)...), nil
+	}
+
+	var matched []images.Image
+	for _, term := range terms {
+		found, err := p.resolveTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		matched = append(matched, found...)
+	}
+	matched = lo.UniqBy(matched, func(img images.Image) string { return img.ID })
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].CreatedAt.After(matched[j].CreatedAt) })
+
+	resolved := lo.UniqBy(lo.Map(matched, func(img images.Image, _ int) v1openstack.Image {
+		return v1openstack.Image{
+			ID:           img.ID,
+			Name:         img.Name,
+			Architecture: Architecture(img),
+			OS:           OS(img),
+		}
+	}), func(img v1openstack.Image) string { return img.Architecture + "/" + img.OS })
+
+	log.FromContext(ctx).V(1).Info("resolved images", "images", resolved)
+	p.cache.SetDefault(key, resolved)
+	return append([]v1openstack.Image{}, resolved...), nil
+}
+
+// resolveTerm returns the active images matching a single selector term. The fields of a term are
+// ANDed: ID is looked up directly, alias and tags are filtered by Glance and properties are
+// filtered here because the list API can't express arbitrary properties.
+func (p *DefaultProvider) resolveTerm(term v1openstack.OpenStackImageSelectorTerm) ([]images.Image, error) {
+	if term.ID != "" {
+		img, err := images.Get(p.imageClient, term.ID).Extract()
+		if err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); ok {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to get image %s: %w", term.ID, err)
+		}
+		if img.Status != images.ImageStatusActive {
+			return nil, nil
+		}
+		return []images.Image{*img}, nil
+	}
+
+	pages, err := images.List(p.imageClient, images.ListOpts{
+		Name:   term.Alias,
+		Tags:   term.Tags,
+		Status: images.ImageStatusActive,
+	}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	list, err := images.ExtractImages(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract images: %w", err)
+	}
+	return lo.Filter(list, func(img images.Image, _ int) bool {
+		return img.Status == images.ImageStatusActive && matchesProperties(img, term.Properties)
+	}), nil
+}
+
+func matchesProperties(img images.Image, properties map[string]string) bool {
+	for k, v := range properties {
+		if value, ok := img.Properties[k]; !ok || fmt.Sprint(value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Architecture maps the Glance architecture property of an image to a kubernetes.io/arch value.
+// Images without the property are assumed to be amd64.
+func Architecture(img images.Image) string {
+	arch, _ := img.Properties[ArchitectureProperty].(string)
+	return NormalizeArchitecture(arch)
+}
+
+// NormalizeArchitecture maps an OpenStack architecture name, as used by Glance and Nova, to a
+// kubernetes.io/arch value. An empty architecture is assumed to be amd64.
+func NormalizeArchitecture(arch string) string {
+	switch arch {
+	case "", "x86_64", "amd64":
+		return karpv1.ArchitectureAmd64
+	case "aarch64", "arm64":
+		return karpv1.ArchitectureArm64
+	default:
+		return arch
+	}
+}
+
+// OS maps the Glance os_type property of an image to a kubernetes.io/os value. Images without the
+// property are assumed to be Linux.
+func OS(img images.Image) string {
+	osType, _ := img.Properties[OSProperty].(string)
+	if osType == "" {
+		return string(corev1.Linux)
+	}
+	return strings.ToLower(osType)
+}
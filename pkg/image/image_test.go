@@ -0,0 +1,127 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	openstackcache "github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func newTestGlance() *fake.Glance {
+	glance := fake.NewGlance()
+	now := time.Now().UTC()
+	glance.AddImage(fake.Image{ID: "ubuntu-old", Name: "ubuntu-24.04", Tags: []string{"kubernetes"}, Properties: map[string]string{"architecture": "x86_64", "os_distro": "ubuntu"}, Created: now.Add(-2 * time.Hour)})
+	glance.AddImage(fake.Image{ID: "ubuntu-new", Name: "ubuntu-24.04", Tags: []string{"kubernetes"}, Properties: map[string]string{"architecture": "x86_64", "os_distro": "ubuntu"}, Created: now.Add(-time.Hour)})
+	glance.AddImage(fake.Image{ID: "ubuntu-arm", Name: "ubuntu-24.04-arm", Tags: []string{"kubernetes"}, Properties: map[string]string{"architecture": "aarch64", "os_distro": "ubuntu"}, Created: now.Add(-3 * time.Hour)})
+	glance.AddImage(fake.Image{ID: "ubuntu-queued", Name: "ubuntu-24.04", Tags: []string{"kubernetes"}, Status: "queued", Created: now})
+	glance.AddImage(fake.Image{ID: "debian", Name: "debian-12", Properties: map[string]string{"os_distro": "debian"}, Created: now})
+	glance.AddImage(fake.Image{ID: "windows", Name: "windows-2022", Tags: []string{"kubernetes"}, Properties: map[string]string{"os_type": "windows"}, Created: now.Add(-4 * time.Hour)})
+	return glance
+}
+
+func newTestProvider(glance *fake.Glance) *DefaultProvider {
+	return NewProvider(glance.ServiceClient(), cache.New(openstackcache.ImageTTL, openstackcache.DefaultCleanupInterval))
+}
+
+func newNodeClass(terms ...v1openstack.OpenStackImageSelectorTerm) *v1openstack.OpenStackNodeClass {
+	return &v1openstack.OpenStackNodeClass{
+		Spec: v1openstack.OpenStackNodeClassSpec{ImageSelectorTerms: terms},
+	}
+}
+
+func TestListImages(t *testing.T) {
+	glance := newTestGlance()
+	defer glance.Close()
+
+	tests := []struct {
+		name     string
+		terms    []v1openstack.OpenStackImageSelectorTerm
+		expected []v1openstack.Image
+	}{
+		{
+			name:     "by id",
+			terms:    []v1openstack.OpenStackImageSelectorTerm{{ID: "ubuntu-old"}},
+			expected: []v1openstack.Image{{ID: "ubuntu-old", Name: "ubuntu-24.04", Architecture: "amd64", OS: "linux"}},
+		},
+		{
+			name:     "by alias picks the newest active image",
+			terms:    []v1openstack.OpenStackImageSelectorTerm{{Alias: "ubuntu-24.04"}},
+			expected: []v1openstack.Image{{ID: "ubuntu-new", Name: "ubuntu-24.04", Architecture: "amd64", OS: "linux"}},
+		},
+		{
+			name:  "by tag returns the newest image per architecture and OS",
+			terms: []v1openstack.OpenStackImageSelectorTerm{{Tags: []string{"kubernetes"}}},
+			expected: []v1openstack.Image{
+				{ID: "ubuntu-new", Name: "ubuntu-24.04", Architecture: "amd64", OS: "linux"},
+				{ID: "ubuntu-arm", Name: "ubuntu-24.04-arm", Architecture: "arm64", OS: "linux"},
+				{ID: "windows", Name: "windows-2022", Architecture: "amd64", OS: "windows"},
+			},
+		},
+		{
+			name:     "by properties",
+			terms:    []v1openstack.OpenStackImageSelectorTerm{{Properties: map[string]string{"os_distro": "debian"}}},
+			expected: []v1openstack.Image{{ID: "debian", Name: "debian-12", Architecture: "amd64", OS: "linux"}},
+		},
+		{
+			name: "terms are ORed",
+			terms: []v1openstack.OpenStackImageSelectorTerm{
+				{ID: "missing"},
+				{Alias: "ubuntu-24.04-arm", Properties: map[string]string{"architecture": "aarch64"}},
+			},
+			expected: []v1openstack.Image{{ID: "ubuntu-arm", Name: "ubuntu-24.04-arm", Architecture: "arm64", OS: "linux"}},
+		},
+		{
+			name:  "no match",
+			terms: []v1openstack.OpenStackImageSelectorTerm{{Alias: "ubuntu-24.04", Tags: []string{"gpu"}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			images, err := newTestProvider(glance).List(context.Background(), newNodeClass(tc.terms...))
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(images) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, images)
+			}
+			for i := range images {
+				if images[i] != tc.expected[i] {
+					t.Errorf("expected %v, got %v", tc.expected, images)
+				}
+			}
+		})
+	}
+}
+
+func TestListImagesIsCached(t *testing.T) {
+	glance := newTestGlance()
+	defer glance.Close()
+	provider := newTestProvider(glance)
+	nodeClass := newNodeClass(v1openstack.OpenStackImageSelectorTerm{Alias: "ubuntu-24.04"})
+
+	if _, err := provider.List(context.Background(), nodeClass); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	requests := glance.Requests()
+	if _, err := provider.List(context.Background(), nodeClass); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if glance.Requests() != requests {
+		t.Errorf("expected the second List to be served from the cache, got %d new requests", glance.Requests()-requests)
+	}
+}
+
+func TestListImagesWithoutTerms(t *testing.T) {
+	glance := newTestGlance()
+	defer glance.Close()
+
+	if _, err := newTestProvider(glance).List(context.Background(), newNodeClass()); err == nil {
+		t.Errorf("expected an error when no imageSelectorTerms are specified")
+	}
+}
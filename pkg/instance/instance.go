@@ -2,143 +2,662 @@ package instance
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/attachinterfaces"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/bootfromvolume"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/keypairs"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/schedulerhints"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/bootstrap"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/floatingip"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/reservation"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
 )
 
+// Provider launches and manages the Nova servers of the cluster. Delete and Get take the ID of the
+// server, the caller parses it out of the openstack:///<serverID> provider ID.
 type Provider interface {
 	Create(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, error)
-	Delete(ctx context.Context, providerID string) error
+	Delete(ctx context.Context, instanceID string) error
+	Get(ctx context.Context, instanceID string) (*Instance, error)
+	List(ctx context.Context) ([]*Instance, error)
 }
 
+const (
+	serverStatusBuild       = "BUILD"
+	serverStatusActive      = "ACTIVE"
+	serverStatusError       = "ERROR"
+	serverStatusDeleted     = "DELETED"
+	serverStatusSoftDeleted = "SOFT_DELETED"
+
+	defaultPollInterval = 5 * time.Second
+	defaultBuildTimeout = 10 * time.Minute
+)
+
 type DefaultProvider struct {
-	clusterName   string
-	computeClient *gophercloud.ServiceClient
+	clusterName        string
+	computeClient      *gophercloud.ServiceClient
+	floatingIPProvider floatingip.Provider
+	// clusterConfig is used to render the kubeadm bootstrap of new nodes. When nil, the NodeClass
+	// user data is passed to the servers unchanged.
+	clusterConfig *bootstrap.ClusterConfig
+	// unavailableOfferings records the offerings Nova had no capacity for, so that the instance
+	// type provider stops offering them for a while.
+	unavailableOfferings *cache.UnavailableOfferings
+	// preemptibleComputeClient launches the spot servers of the NodeClasses using the preemptible
+	// project. It is nil when no preemptible project is configured.
+	preemptibleComputeClient *gophercloud.ServiceClient
+
+	pollInterval time.Duration
+	buildTimeout time.Duration
 }
 
-func NewProvider(client *gophercloud.ServiceClient, clusterName string) Provider {
+func NewProvider(client *gophercloud.ServiceClient, floatingIPProvider floatingip.Provider, clusterConfig *bootstrap.ClusterConfig, clusterName string, unavailableOfferings *cache.UnavailableOfferings, preemptibleClient *gophercloud.ServiceClient) Provider {
 	return &DefaultProvider{
-		clusterName:   clusterName,
-		computeClient: client,
+		clusterName:              clusterName,
+		computeClient:            client,
+		floatingIPProvider:       floatingIPProvider,
+		clusterConfig:            clusterConfig,
+		unavailableOfferings:     unavailableOfferings,
+		preemptibleComputeClient: preemptibleClient,
+		pollInterval:             defaultPollInterval,
+		buildTimeout:             defaultBuildTimeout,
+	}
+}
+
+// computeClients returns the clients of the projects the servers of the cluster run in: the project
+// of the controller, then the preemptible project when one is configured.
+func (p *DefaultProvider) computeClients() []*gophercloud.ServiceClient {
+	if p.preemptibleComputeClient == nil {
+		return []*gophercloud.ServiceClient{p.computeClient}
 	}
+	return []*gophercloud.ServiceClient{p.computeClient, p.preemptibleComputeClient}
+}
+
+// launchClient returns the client of the project the offering is launched in. Spot offerings of the
+// NodeClasses using the preemptible project are launched there, every other offering in the
+// project of the controller.
+func (p *DefaultProvider) launchClient(nodeClass *v1openstack.OpenStackNodeClass, offering *cloudprovider.Offering) (*gophercloud.ServiceClient, error) {
+	if offering.CapacityType() != karpv1.CapacityTypeSpot || nodeClass.Spec.Preemptible == nil || !nodeClass.Spec.Preemptible.Project {
+		return p.computeClient, nil
+	}
+	if p.preemptibleComputeClient == nil {
+		return nil, fmt.Errorf("no preemptible project is configured")
+	}
+	return p.preemptibleComputeClient, nil
+}
+
+// orderByPrice sorts the instance types by the price of their cheapest compatible offering, so the
+// cheapest flavor is tried first. Instance types whose cheapest offering is reserved go first, as
+// the reservation is paid for anyway, and those without a compatible offering go last.
+func orderByPrice(instanceTypes []*cloudprovider.InstanceType, requirements scheduling.Requirements) []*cloudprovider.InstanceType {
+	rank := func(instanceType *cloudprovider.InstanceType) (bool, float64) {
+		if offering := instanceType.Offerings.Available().Compatible(requirements).Cheapest(); offering != nil {
+			return offering.CapacityType() == karpv1.CapacityTypeReserved, offering.Price
+		}
+		return false, math.MaxFloat64
+	}
+	ordered := append([]*cloudprovider.InstanceType{}, instanceTypes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		reservedI, priceI := rank(ordered[i])
+		reservedJ, priceJ := rank(ordered[j])
+		if reservedI != reservedJ {
+			return reservedI
+		}
+		return priceI < priceJ
+	})
+	return ordered
 }
 
 func (p *DefaultProvider) Create(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) (*Instance, error) {
 	if len(instanceTypes) == 0 {
 		return nil, fmt.Errorf("no instance types provided")
 	}
-	capacityType := karpv1.CapacityTypeOnDemand
-	zone := "default-zone"
+	if nodeClass.Spec.FloatingIP && p.floatingIPProvider == nil {
+		return nil, cloudprovider.NewNodeClassNotReadyError(fmt.Errorf("NodeClass %s requests a floating IP but no floating IP provider is configured", nodeClass.Name))
+	}
+	requirements := scheduling.NewNodeSelectorRequirementsWithMinValues(nodeClaim.Spec.Requirements...)
+	logger := log.FromContext(ctx)
 
 	var errs []error
-	for _, instanceType := range instanceTypes {
+	// The launch only fails with an InsufficientCapacityError when Nova ran out of capacity or quota
+	// for every flavor it was tried with. Instance types skipped for their requirements, or launches
+	// failing for other reasons, are not a matter of capacity.
+	insufficientCapacity, otherFailure := false, false
+	for _, instanceType := range orderByPrice(instanceTypes, requirements) {
+		offering := instanceType.Offerings.Available().Compatible(requirements).Cheapest()
+		if offering == nil {
+			errs = append(errs, fmt.Errorf("no available offering of %s is compatible with the NodeClaim requirements", instanceType.Name))
+			continue
+		}
+		zone := offeringZone(offering)
+		capacityType := lo.Ternary(offering.CapacityType() == "", karpv1.CapacityTypeOnDemand, offering.CapacityType())
 		instanceName := fmt.Sprintf("karpenter-%s", nodeClaim.Name)
 
-		createdOpts, err := p.buildInstanceOpts(ctx, nodeClaim, nodeClass, instanceType, zone, instanceName, capacityType)
+		client, err := p.launchClient(nodeClass, offering)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to launch %s: %w", instanceType.Name, err))
+			otherFailure = true
+			continue
+		}
+		createdOpts, err := p.buildInstanceOpts(ctx, nodeClaim, nodeClass, instanceType, offering, requirements, instanceName)
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed to build instance options for %s: %w", instanceType.Name, err))
+			otherFailure = true
 			continue
 		}
 
-		logger := log.FromContext(ctx)
-
 		logger.V(1).Info("OpenStack Request Payload (servers.CreateOpts)",
 			"Flavor", createdOpts.FlavorRef,
 			"Image", createdOpts.ImageRef,
 			"Name", createdOpts.Name,
 			"UserData_Length", len(createdOpts.UserData),
-			"Request", fmt.Sprintf("%+v", createdOpts),
 		)
-		//Real instance
-		// server, err := servers.Create(p.computeClient, createdOpts).Extract()
-		// if err != nil {
-		// 	errs = append(errs, fmt.Errorf("failed to create instance for %s: %w", instanceType.Name, err))
-		// 	continue
-		// }
-
-		// instance := &Instance{
-		// 	Name:       server.Name,
-		// 	Type:       instanceType.Name,
-		// 	ImageID:    createdOpts.ImageRef,
-		// 	Metadata:   server.Metadata,
-		// 	UserData:   createdOpts.UserData,
-		// 	InstanceID: server.ID,
-		// 	Status:     server.Status,
-		// }
-
-		log.FromContext(ctx).Info("Creating instance OpenStack", "instanceName", instanceName, "flavor", instanceType.Name, "zone", zone)
-		// Mocked creation
-		instance := &Instance{
-			Name:       createdOpts.Name,
-			Type:       createdOpts.FlavorRef,
-			ImageID:    createdOpts.ImageRef,
-			Metadata:   createdOpts.Metadata,
-			UserData:   createdOpts.UserData,
-			InstanceID: "mock-server-id-123",
-			Status:     "BUILD",
-		}
-
-		fmt.Printf("Instance successfully created | instanceName=%s | providerID=%s | status=%s | UserData=%s | MetaData=%s | ImageId=%s | Type=%s\n",
-			instance.Name, instance.InstanceID, instance.Status, string(instance.UserData), instance.Metadata, instance.ImageID, instance.Type)
+
+		logger.Info("Creating instance OpenStack", "instanceName", instanceName, "flavor", instanceType.Name, "zone", zone, "capacityType", capacityType)
+		server, err := p.createServer(ctx, client, withReservation(withExtensions(nodeClass, createdOpts), offering))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create instance for %s: %w", instanceType.Name, err))
+			if !cloudprovider.IsInsufficientCapacityError(err) {
+				otherFailure = true
+				continue
+			}
+			insufficientCapacity = true
+			if p.unavailableOfferings != nil {
+				p.unavailableOfferings.MarkUnavailable(ctx, "InsufficientCapacity", instanceType.Name, zone, capacityType)
+			}
+			continue
+		}
+
+		instance := instanceFromServer(server)
+		instance.Type = instanceType.Name
+		instance.FlavorID = createdOpts.FlavorRef
+		instance.ImageID = createdOpts.ImageRef
+		instance.UserData = createdOpts.UserData
+		instance.Zone = zone
+		instance.CapacityType = capacityType
+		if capacityType == karpv1.CapacityTypeReserved {
+			instance.ReservationID = offering.ReservationID()
+			instance.ReservationType = offering.Requirements.Get(v1openstack.LabelReservationType).Any()
+		}
+		if nodeClass.Spec.FloatingIP {
+			// Without its floating IP the node may never reach the control plane, so the server is
+			// not worth keeping. The failure comes from the floating IP network rather than from the
+			// flavor, so the next flavor is not tried: it would only launch another server to throw
+			// away.
+			instance.FloatingIP, err = p.floatingIPProvider.Allocate(ctx, nodeClass.Spec.FloatingIPNetwork, server.ID, lo.FirstOrEmpty(nodeClass.Spec.Networks))
+			if err != nil {
+				if releaseErr := p.floatingIPProvider.Release(ctx, server.ID); releaseErr != nil {
+					logger.Error(releaseErr, "failed to release floating IP", "instanceID", server.ID)
+				}
+				p.cleanupServer(ctx, client, server)
+				return nil, fmt.Errorf("allocating floating IP for instance %s: %w", server.ID, err)
+			}
+		}
+		logger.Info("Instance successfully created", "instanceName", instance.Name, "instanceID", instance.InstanceID, "status", instance.Status, "flavor", instance.Type)
 		return instance, nil
 	}
 
-	return nil, fmt.Errorf("failed to create instance after trying all instance types: %w", fmt.Errorf("%v", errs))
+	err := fmt.Errorf("failed to create instance after trying all instance types: %w", errors.Join(errs...))
+	if insufficientCapacity && !otherFailure {
+		return nil, cloudprovider.NewInsufficientCapacityError(err)
+	}
+	return nil, err
+}
+
+// createServer launches a server and blocks until Nova has finished building it. Servers that end
+// up in ERROR are deleted, and capacity related failures are surfaced as InsufficientCapacityErrors
+// so that the caller can fall back to the next flavor.
+func (p *DefaultProvider) createServer(ctx context.Context, client *gophercloud.ServiceClient, opts servers.CreateOptsBuilder) (*servers.Server, error) {
+	logger := log.FromContext(ctx)
+
+	server, err := servers.Create(client, opts).Extract()
+	if err != nil {
+		if isInsufficientCapacity(err.Error()) || isQuotaExceeded(err) {
+			return nil, cloudprovider.NewInsufficientCapacityError(err)
+		}
+		return nil, err
+	}
+
+	server, err = p.waitForBuild(ctx, client, server.ID)
+	if err != nil {
+		p.cleanupServer(ctx, client, server)
+		return nil, fmt.Errorf("waiting for server %s to leave %s: %w", server.ID, serverStatusBuild, err)
+	}
+	logger.Info("OpenStack server left BUILD", "instanceID", server.ID, "status", server.Status, "fault", server.Fault.Message)
+
+	if server.Status != serverStatusActive {
+		p.cleanupServer(ctx, client, server)
+		err := fmt.Errorf("server %s is in %s state: %s", server.ID, server.Status, server.Fault.Message)
+		if isInsufficientCapacity(server.Fault.Message) {
+			return nil, cloudprovider.NewInsufficientCapacityError(err)
+		}
+		return nil, err
+	}
+	return server, nil
 }
 
-func (p *DefaultProvider) buildInstanceOpts(ctx context.Context, nodeClaim *karpv1.NodeClaim, nodeClass *v1openstack.OpenStackNodeClass, instanceType *cloudprovider.InstanceType, zone, instanceName, capacityType string) (servers.CreateOpts, error) {
-	imageID := nodeClass.Spec.ImageSelectorTerms[0].ID
-	flavor := instanceType.Name
+// waitForBuild polls the server until it leaves the BUILD state. Polling only stops early when the
+// server is gone, other errors are retried until the build timeout as the server may be booting
+// normally. The last observed server is returned even when polling fails, so that the caller can
+// clean it up.
+func (p *DefaultProvider) waitForBuild(ctx context.Context, client *gophercloud.ServiceClient, serverID string) (*servers.Server, error) {
+	server := &servers.Server{ID: serverID, Status: serverStatusBuild}
+	err := wait.PollUntilContextTimeout(ctx, p.pollInterval, p.buildTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := servers.Get(client, serverID).Extract()
+		if err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); ok {
+				return false, err
+			}
+			log.FromContext(ctx).V(1).Info("failed to get server, retrying", "instanceID", serverID, "error", err.Error())
+			return false, nil
+		}
+		server = current
+		return current.Status != serverStatusBuild, nil
+	})
+	return server, err
+}
+
+func (p *DefaultProvider) cleanupServer(ctx context.Context, client *gophercloud.ServiceClient, server *servers.Server) {
+	if err := servers.Delete(client, server.ID).ExtractErr(); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			log.FromContext(ctx).Error(err, "failed to clean up server", "instanceID", server.ID, "status", server.Status)
+		}
+	}
+}
 
-	userData := []byte(nodeClass.Spec.UserData)
+// offeringZone returns the availability zone of the offering. Offerings without a zone leave the
+// choice to Nova.
+func offeringZone(offering *cloudprovider.Offering) string {
+	if !offering.Requirements.Has(corev1.LabelTopologyZone) {
+		return ""
+	}
+	return offering.Zone()
+}
+
+// isInsufficientCapacity reports whether a Nova error message means that the request could not be
+// placed, either because no hypervisor had room for the flavor or because the project quota ran out.
+func isInsufficientCapacity(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "no valid host") || strings.Contains(message, "quota exceeded")
+}
+
+// isQuotaExceeded reports whether Nova refused to create a server because the project is out of
+// quota, whatever the wording of the message. Nova answers 403, or 413 in older releases.
+func isQuotaExceeded(err error) bool {
+	var statusErr gophercloud.StatusCodeError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	code := statusErr.GetStatusCode()
+	return (code == http.StatusForbidden || code == http.StatusRequestEntityTooLarge) && strings.Contains(strings.ToLower(err.Error()), "quota")
+}
+
+func (p *DefaultProvider) buildInstanceOpts(ctx context.Context, nodeClaim *karpv1.NodeClaim, nodeClass *v1openstack.OpenStackNodeClass, instanceType *cloudprovider.InstanceType, offering *cloudprovider.Offering, requirements scheduling.Requirements, instanceName string) (servers.CreateOpts, error) {
+	image, err := resolveImage(nodeClass, instanceType, requirements)
+	if err != nil {
+		return servers.CreateOpts{}, err
+	}
+	flavor := flavorID(instanceType)
+	if flavor == "" {
+		return servers.CreateOpts{}, fmt.Errorf("instance type %s has no flavor ID", instanceType.Name)
+	}
+	metadata := p.ownershipMetadata(nodeClass, nodeClaim)
+	if offering.CapacityType() == karpv1.CapacityTypeSpot {
+		metadata[v1openstack.CapacityTypeMetadataKey] = karpv1.CapacityTypeSpot
+	}
+	if offering.CapacityType() == karpv1.CapacityTypeReserved {
+		metadata[v1openstack.ReservationMetadataKey] = offering.ReservationID()
+		metadata[v1openstack.ReservationTypeMetadataKey] = offering.Requirements.Get(v1openstack.LabelReservationType).Any()
+		// Blazar creates a flavor with the ID of the reservation for the servers of an instance
+		// reservation, and only schedules that flavor to the reserved hosts.
+		if offering.Requirements.Get(v1openstack.LabelReservationType).Any() == v1openstack.ReservationTypeInstance {
+			flavor = offering.ReservationID()
+		}
+	}
+
+	userData, err := p.userData(nodeClass, nodeClaim, instanceType)
+	if err != nil {
+		return servers.CreateOpts{}, fmt.Errorf("generating user data: %w", err)
+	}
 
 	return servers.CreateOpts{
 		Name:      instanceName,
 		FlavorRef: flavor,
-		ImageRef:  imageID,
-		UserData:  userData,
+		ImageRef:  image.ID,
+		UserData:  []byte(userData),
+		Networks: lo.Map(nodeClass.Spec.Networks, func(network string, _ int) servers.Network {
+			return servers.Network{UUID: network}
+		}),
+		SecurityGroups:   nodeClass.Spec.SecurityGroups,
+		AvailabilityZone: offeringZone(offering),
+		// Ownership metadata is applied last so that user supplied labels and metadata can't
+		// hide the server from the cluster.
+		Metadata: lo.Assign(nodeClass.Spec.Labels, nodeClass.Spec.Metadata, metadata),
 	}, nil
 }
 
-func parseOSProviderID(providerID string) (serverID string, err error) {
-	const prefix = "openstack:///"
-	if !strings.HasPrefix(providerID, prefix) {
-		return "", fmt.Errorf("unexpected providerID format: %s", providerID)
+// userData renders the kubeadm bootstrap of the node, with the NodeClass user data merged in.
+func (p *DefaultProvider) userData(nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim, instanceType *cloudprovider.InstanceType) (string, error) {
+	if p.clusterConfig == nil {
+		return nodeClass.Spec.UserData, nil
 	}
+	return bootstrap.UserData(bootstrap.Options{
+		ClusterConfig:        *p.clusterConfig,
+		KubeletConfiguration: nodeClass.Spec.KubeletConfiguration,
+		KubeReserved:         lo.FromPtr(instanceType.Overhead).KubeReserved,
+		Taints:               nodeClaim.Spec.Taints,
+		StartupTaints:        nodeClaim.Spec.StartupTaints,
+		Labels:               nodeClaim.Labels,
+		CustomUserData:       nodeClass.Spec.UserData,
+	})
+}
 
-	serverID = strings.TrimPrefix(providerID, prefix)
-	if serverID == "" {
-		return "", fmt.Errorf("invalid OpenStack providerID, expected format openstack:///serverID")
+// withExtensions adds the key pair and the block device mappings of the NodeClass to the base
+// create options. A boot disk turns the server into a boot-from-volume server: the image is copied
+// into a new Cinder volume and imageRef is sent empty.
+func withExtensions(nodeClass *v1openstack.OpenStackNodeClass, opts servers.CreateOpts) servers.CreateOptsBuilder {
+	blockDevices := blockDeviceMappings(nodeClass, opts.ImageRef)
+	if lo.ContainsBy(nodeClass.Spec.Disks, func(disk v1openstack.Disk) bool { return disk.Boot }) {
+		opts.ImageRef = ""
 	}
 
-	return serverID, nil
+	var builder servers.CreateOptsBuilder = opts
+	if nodeClass.Spec.KeyPair != "" {
+		builder = keypairs.CreateOptsExt{CreateOptsBuilder: builder, KeyName: nodeClass.Spec.KeyPair}
+	}
+	if len(blockDevices) > 0 {
+		builder = bootfromvolume.CreateOptsExt{CreateOptsBuilder: builder, BlockDevice: blockDevices}
+	}
+	return builder
 }
 
-func (p *DefaultProvider) Delete(ctx context.Context, providerID string) error {
-	instanceID, err := parseOSProviderID(providerID)
-	if err != nil {
-		return fmt.Errorf("parsing provider ID: %w", err)
+// withReservation adds the scheduler hint placing the server on the hosts of the Blazar reservation
+// of a reserved offering.
+func withReservation(builder servers.CreateOptsBuilder, offering *cloudprovider.Offering) servers.CreateOptsBuilder {
+	if offering.CapacityType() != karpv1.CapacityTypeReserved {
+		return builder
+	}
+	return schedulerhints.CreateOptsExt{
+		CreateOptsBuilder: builder,
+		SchedulerHints: schedulerhints.SchedulerHints{
+			AdditionalProperties: map[string]interface{}{reservation.SchedulerHint: offering.ReservationID()},
+		},
 	}
+}
+
+// blockDeviceMappings translates the NodeClass disks into Nova block device mappings. The root
+// device is always listed first: a Cinder volume created from the image for the boot disk, or the
+// image on local storage when only additional disks are requested.
+func blockDeviceMappings(nodeClass *v1openstack.OpenStackNodeClass, imageID string) []bootfromvolume.BlockDevice {
+	if len(nodeClass.Spec.Disks) == 0 {
+		return nil
+	}
+	root := bootfromvolume.BlockDevice{
+		SourceType:          bootfromvolume.SourceImage,
+		UUID:                imageID,
+		DestinationType:     bootfromvolume.DestinationLocal,
+		BootIndex:           0,
+		DeleteOnTermination: true,
+	}
+	if bootDisk, ok := lo.Find(nodeClass.Spec.Disks, func(disk v1openstack.Disk) bool { return disk.Boot }); ok {
+		root.DestinationType = bootfromvolume.DestinationVolume
+		root.VolumeSize = int(bootDisk.SizeGiB)
+		root.VolumeType = bootDisk.VolumeType
+	}
+
+	blockDevices := []bootfromvolume.BlockDevice{root}
+	for _, disk := range nodeClass.Spec.Disks {
+		if disk.Boot {
+			continue
+		}
+		blockDevices = append(blockDevices, bootfromvolume.BlockDevice{
+			SourceType:          bootfromvolume.SourceBlank,
+			DestinationType:     bootfromvolume.DestinationVolume,
+			VolumeSize:          int(disk.SizeGiB),
+			VolumeType:          disk.VolumeType,
+			BootIndex:           -1,
+			DeleteOnTermination: true,
+		})
+	}
+	return blockDevices
+}
 
+// flavorID returns the ID of the flavor of the instance type, the instance type being named after the
+// flavor.
+func flavorID(instanceType *cloudprovider.InstanceType) string {
+	requirement := instanceType.Requirements.Get(v1openstack.LabelFlavorID)
+	if requirement.Operator() != corev1.NodeSelectorOpIn {
+		return ""
+	}
+	return requirement.Any()
+}
+
+// resolveImage picks the newest image resolved on the NodeClass status whose architecture and OS
+// are compatible with both the instance type and the NodeClaim, as a flavor offered for several
+// architectures launches whichever the NodeClaim asks for.
+func resolveImage(nodeClass *v1openstack.OpenStackNodeClass, instanceType *cloudprovider.InstanceType, requirements scheduling.Requirements) (v1openstack.Image, error) {
+	compatible := scheduling.NewRequirements(instanceType.Requirements.Values()...)
+	compatible.Add(requirements.Values()...)
+	arch := compatible.Get(corev1.LabelArchStable)
+	os := compatible.Get(corev1.LabelOSStable)
+	image, ok := lo.Find(nodeClass.Status.Images, func(image v1openstack.Image) bool {
+		return arch.Has(image.Architecture) && os.Has(lo.Ternary(image.OS == "", string(corev1.Linux), image.OS))
+	})
+	if !ok {
+		return v1openstack.Image{}, fmt.Errorf("no resolved image matches architecture %s and OS %s", arch, os)
+	}
+	return image, nil
+}
+
+// ownershipMetadata returns the server metadata used to find the servers launched for this
+// cluster and to map them back to their NodeClaim, NodePool and NodeClass.
+func (p *DefaultProvider) ownershipMetadata(nodeClass *v1openstack.OpenStackNodeClass, nodeClaim *karpv1.NodeClaim) map[string]string {
+	metadata := map[string]string{
+		v1openstack.ClusterMetadataKey:   p.clusterName,
+		v1openstack.NodeClaimMetadataKey: nodeClaim.Name,
+		v1openstack.NodeClassMetadataKey: nodeClass.Name,
+	}
+	if nodePool, ok := nodeClaim.Labels[karpv1.NodePoolLabelKey]; ok {
+		metadata[v1openstack.NodePoolMetadataKey] = nodePool
+	}
+	return metadata
+}
+
+func (p *DefaultProvider) Delete(ctx context.Context, instanceID string) error {
+	var err error
 	logger := log.FromContext(ctx)
 	logger.Info("Deleting OpenStack instance", "instanceID", instanceID)
 
-	err = servers.Delete(p.computeClient, instanceID).ExtractErr()
+	// Floating IPs outlive their server in Neutron, release them first so that a failure is retried
+	// on the next Delete call instead of leaking the address.
+	if p.floatingIPProvider != nil {
+		if err := p.floatingIPProvider.Release(ctx, instanceID); err != nil {
+			return fmt.Errorf("releasing floating IPs of instance %s: %w", instanceID, err)
+		}
+	}
+
+	// The provider ID doesn't tell the project of the server, it is looked for in each of them.
+	for _, client := range p.computeClients() {
+		err = servers.Delete(client, instanceID).ExtractErr()
+		if err != nil {
+			if _, ok := err.(gophercloud.ErrDefault404); ok {
+				continue
+			}
+			return fmt.Errorf("deleting instance %s: %w", instanceID, err)
+		}
+		logger.Info("OpenStack instance delete initiated/completed", "instanceID", instanceID)
+		return nil
+	}
+	logger.Info("Instance already deleted or not found", "instanceID", instanceID)
+	return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance not found: %w", err))
+}
+
+// Get returns the server with the given ID. A NodeClaimNotFoundError is returned when the server
+// no longer exists or has been deleted.
+func (p *DefaultProvider) Get(ctx context.Context, instanceID string) (*Instance, error) {
+	var err error
+	var result servers.GetResult
+	var server *servers.Server
+	var client *gophercloud.ServiceClient
+	for _, client = range p.computeClients() {
+		result = servers.Get(client, instanceID)
+		server, err = result.Extract()
+		if _, ok := err.(gophercloud.ErrDefault404); !ok {
+			break
+		}
+	}
 	if err != nil {
 		if _, ok := err.(gophercloud.ErrDefault404); ok {
-			logger.Info("Instance already deleted or not found", "instanceID", instanceID)
-			return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance not found: %w", err))
+			return nil, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance %s not found: %w", instanceID, err))
 		}
-		return fmt.Errorf("deleting instance %s: %w", instanceID, err)
+		return nil, fmt.Errorf("getting instance %s: %w", instanceID, err)
+	}
+	if server.Status == serverStatusDeleted || server.Status == serverStatusSoftDeleted {
+		return nil, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance %s is in %s state", instanceID, server.Status))
 	}
 
-	logger.Info("OpenStack instance delete initiated/completed", "instanceID", instanceID)
-	return nil
+	instance := instanceFromServer(server)
+	var zone availabilityzones.ServerAvailabilityZoneExt
+	if err := result.ExtractInto(&zone); err != nil {
+		return nil, fmt.Errorf("extracting availability zone of instance %s: %w", instanceID, err)
+	}
+	instance.Zone = zone.AvailabilityZone
+	instance.Networks, err = getNetworkIDs(client, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("listing interfaces of instance %s: %w", instanceID, err)
+	}
+	return instance, nil
+}
+
+func getNetworkIDs(client *gophercloud.ServiceClient, instanceID string) ([]string, error) {
+	pages, err := attachinterfaces.List(client, instanceID).AllPages()
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := attachinterfaces.ExtractInterfaces(pages)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Uniq(lo.Map(interfaces, func(i attachinterfaces.Interface, _ int) string { return i.NetID })), nil
+}
+
+// List returns every server carrying this cluster's ownership metadata, in the project of the
+// controller and in the preemptible project.
+func (p *DefaultProvider) List(ctx context.Context) ([]*Instance, error) {
+	var instances []*Instance
+	for _, client := range p.computeClients() {
+		projectInstances, err := p.list(client)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, projectInstances...)
+	}
+	log.FromContext(ctx).V(1).Info("Listed cluster instances", "count", len(instances))
+	return instances, nil
+}
+
+func (p *DefaultProvider) list(client *gophercloud.ServiceClient) ([]*Instance, error) {
+	pages, err := servers.List(client, servers.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("listing servers: %w", err)
+	}
+	allServers, err := servers.ExtractServers(pages)
+	if err != nil {
+		return nil, fmt.Errorf("extracting servers: %w", err)
+	}
+	var zones []availabilityzones.ServerAvailabilityZoneExt
+	if err := servers.ExtractServersInto(pages, &zones); err != nil {
+		return nil, fmt.Errorf("extracting availability zones of servers: %w", err)
+	}
+
+	var instances []*Instance
+	for i := range allServers {
+		server := &allServers[i]
+		if server.Metadata[v1openstack.ClusterMetadataKey] != p.clusterName {
+			continue
+		}
+		if server.Status == serverStatusDeleted || server.Status == serverStatusSoftDeleted {
+			continue
+		}
+		instance := instanceFromServer(server)
+		instance.Zone = zones[i].AvailabilityZone
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+func instanceFromServer(server *servers.Server) *Instance {
+	instance := &Instance{
+		Name:         server.Name,
+		Metadata:     server.Metadata,
+		InstanceID:   server.ID,
+		Status:       server.Status,
+		CreationTime: server.Created,
+	}
+	// Nova reports the flavor ID until microversion 2.47, and its name under original_name since.
+	if flavorID, ok := server.Flavor["id"].(string); ok {
+		instance.FlavorID = flavorID
+		instance.Type = flavorID
+	}
+	if flavorName, ok := server.Flavor["original_name"].(string); ok {
+		instance.Type = flavorName
+	}
+	instance.CapacityType = karpv1.CapacityTypeOnDemand
+	if server.Metadata[v1openstack.CapacityTypeMetadataKey] == karpv1.CapacityTypeSpot {
+		instance.CapacityType = karpv1.CapacityTypeSpot
+	}
+	if reservationID, ok := server.Metadata[v1openstack.ReservationMetadataKey]; ok {
+		instance.CapacityType = karpv1.CapacityTypeReserved
+		instance.ReservationID = reservationID
+		instance.ReservationType = server.Metadata[v1openstack.ReservationTypeMetadataKey]
+		if instance.ReservationType == "" {
+			// Servers launched before the type was recorded run the flavor of the reservation when
+			// it is an instance reservation. Nova only reports the flavor ID before microversion 2.47.
+			instance.ReservationType = lo.Ternary(instance.FlavorID == reservationID, v1openstack.ReservationTypeInstance, v1openstack.ReservationTypeHost)
+		}
+	}
+	if imageID, ok := server.Image["id"].(string); ok {
+		instance.ImageID = imageID
+	}
+	for _, securityGroup := range server.SecurityGroups {
+		if name, ok := securityGroup["name"].(string); ok {
+			instance.SecurityGroups = append(instance.SecurityGroups, name)
+		}
+	}
+	instance.FloatingIP = floatingIPFromAddresses(server.Addresses)
+	return instance
+}
+
+// floatingIPFromAddresses returns the first floating address Nova reports for the server.
+func floatingIPFromAddresses(addresses map[string]interface{}) string {
+	for _, network := range addresses {
+		entries, _ := network.([]interface{})
+		for _, entry := range entries {
+			address, _ := entry.(map[string]interface{})
+			if address["OS-EXT-IPS:type"] == "floating" {
+				if addr, ok := address["addr"].(string); ok {
+					return addr
+				}
+			}
+		}
+	}
+	return ""
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
@@ -53,7 +54,7 @@ func createRealComputeClient(t *testing.T) *gophercloud.ServiceClient {
 
 func TestCreateInstance_Integration(t *testing.T) {
 	err := godotenv.Load("../../.env")
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		t.Fatalf("Erro ao carregar .env: %v", err)
 	}
 
@@ -75,23 +76,31 @@ func TestCreateInstance_Integration(t *testing.T) {
 				},
 			},
 		},
+		Status: v1openstack.OpenStackNodeClassStatus{
+			Images: []v1openstack.Image{{ID: "62dee28f-987d-40f5-a308-051d59991da8", Architecture: "amd64"}},
+		},
 	}
 	nodeClaim := &karpv1.NodeClaim{
 		ObjectMeta: metav1.ObjectMeta{Name: "karpenter-integration-test"},
 	}
 
 	instanceType := &cloudprovider.InstanceType{
-		Name: "7441c7d9-2648-4a33-907e-4d28c2270da3", //Real Flavor ID for general.small
+		Name: "general.small",
 		Requirements: scheduling.NewRequirements(
-			scheduling.NewRequirement("instance-type", "In", "general.small"),
+			scheduling.NewRequirement(corev1.LabelInstanceTypeStable, "In", "general.small"),
+			scheduling.NewRequirement(v1openstack.LabelFlavorID, "In", "7441c7d9-2648-4a33-907e-4d28c2270da3"), //Real Flavor ID for general.small
 		),
+		Offerings: cloudprovider.Offerings{{
+			Requirements: scheduling.NewRequirements(scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, "In", karpv1.CapacityTypeOnDemand)),
+			Available:    true,
+		}},
 	}
 
 	// 1. Cria o cliente real
 	realComputeClient := createRealComputeClient(t)
 
 	// 2. Cria o Provider e injeta o cliente real
-	testProvider := NewProvider(realComputeClient, "test-cluster")
+	testProvider := NewProvider(realComputeClient, nil, nil, "test-cluster", nil, nil)
 
 	// 3. Registra a função de limpeza
 	// Isso garante que a VM seja deletada DEPOIS que o teste rodar
@@ -0,0 +1,186 @@
+package instance
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/bootstrap"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func TestCreateInstanceRequestBody(t *testing.T) {
+	userData := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho 'hello world'"))
+	metadata := `{
+		"karpenter.k8s.openstack/cluster": "test-cluster",
+		"karpenter.k8s.openstack/nodeclaim": "test-node-claim",
+		"karpenter.k8s.openstack/openstacknodeclass": "default",
+		"karpenter.k8s.openstack/nodepool": "general"
+	}`
+
+	cases := []struct {
+		name      string
+		nodeClass func(*v1openstack.OpenStackNodeClass)
+		expected  string
+	}{
+		{
+			name: "networks, security groups and merged metadata",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Networks = []string{"net-uuid-1", "net-uuid-2"}
+				nc.Spec.SecurityGroups = []string{"default", "ssh"}
+				nc.Spec.Labels = map[string]string{"team": "platform", "env": "dev"}
+				nc.Spec.Metadata = map[string]string{"env": "prod", "karpenter.k8s.openstack/cluster": "other"}
+			},
+			expected: `{"server": {
+				"name": "karpenter-test-node-claim",
+				"flavorRef": "m1.large",
+				"imageRef": "mock-image-id-456",
+				"user_data": "` + userData + `",
+				"networks": [{"uuid": "net-uuid-1"}, {"uuid": "net-uuid-2"}],
+				"security_groups": [{"name": "default"}, {"name": "ssh"}],
+				"metadata": {
+					"karpenter.k8s.openstack/cluster": "test-cluster",
+					"karpenter.k8s.openstack/nodeclaim": "test-node-claim",
+					"karpenter.k8s.openstack/openstacknodeclass": "default",
+					"karpenter.k8s.openstack/nodepool": "general",
+					"team": "platform",
+					"env": "prod"
+				}
+			}}`,
+		},
+		{
+			name:      "key pair",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.KeyPair = "admin" },
+			expected: `{"server": {
+				"name": "karpenter-test-node-claim",
+				"flavorRef": "m1.large",
+				"imageRef": "mock-image-id-456",
+				"user_data": "` + userData + `",
+				"networks": [{"uuid": "net-uuid-1"}],
+				"metadata": ` + metadata + `,
+				"key_name": "admin"
+			}}`,
+		},
+		{
+			name: "boot from volume with an additional disk",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) {
+				nc.Spec.Disks = []v1openstack.Disk{
+					{SizeGiB: 100, VolumeType: "ssd"},
+					{SizeGiB: 50, VolumeType: "standard", Boot: true},
+				}
+			},
+			expected: `{"server": {
+				"name": "karpenter-test-node-claim",
+				"flavorRef": "m1.large",
+				"imageRef": "",
+				"user_data": "` + userData + `",
+				"networks": [{"uuid": "net-uuid-1"}],
+				"metadata": ` + metadata + `,
+				"block_device_mapping_v2": [
+					{"source_type": "image", "uuid": "mock-image-id-456", "destination_type": "volume", "volume_size": 50, "volume_type": "standard", "boot_index": 0, "delete_on_termination": true},
+					{"source_type": "blank", "destination_type": "volume", "volume_size": 100, "volume_type": "ssd", "boot_index": -1, "delete_on_termination": true}
+				]
+			}}`,
+		},
+		{
+			name:      "additional disk on a local root disk",
+			nodeClass: func(nc *v1openstack.OpenStackNodeClass) { nc.Spec.Disks = []v1openstack.Disk{{SizeGiB: 20}} },
+			expected: `{"server": {
+				"name": "karpenter-test-node-claim",
+				"flavorRef": "m1.large",
+				"imageRef": "mock-image-id-456",
+				"user_data": "` + userData + `",
+				"networks": [{"uuid": "net-uuid-1"}],
+				"metadata": ` + metadata + `,
+				"block_device_mapping_v2": [
+					{"source_type": "image", "uuid": "mock-image-id-456", "destination_type": "local", "boot_index": 0, "delete_on_termination": true},
+					{"source_type": "blank", "destination_type": "volume", "volume_size": 20, "boot_index": -1, "delete_on_termination": true}
+				]
+			}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nova := fake.NewNova()
+			defer nova.Close()
+
+			nodeClass := newTestNodeClass()
+			nodeClass.Name = "default"
+			tc.nodeClass(nodeClass)
+			nodeClaim := &karpv1.NodeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-node-claim",
+					Labels: map[string]string{karpv1.NodePoolLabelKey: "general"},
+				},
+			}
+
+			if _, err := newTestProvider(nova).Create(context.Background(), nodeClass, nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")}); err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+			requests := nova.CreateRequests()
+			if len(requests) != 1 {
+				t.Fatalf("expected a single create request, got %d", len(requests))
+			}
+
+			var expected, actual map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+				t.Fatalf("invalid expected body: %v", err)
+			}
+			if err := json.Unmarshal(requests[0], &actual); err != nil {
+				t.Fatalf("invalid request body: %v", err)
+			}
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("unexpected request body:\nexpected: %v\ngot:      %s", expected, requests[0])
+			}
+		})
+	}
+}
+
+func TestCreateInstanceBootstrapsNode(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	provider := newTestProvider(nova)
+	provider.clusterConfig = &bootstrap.ClusterConfig{APIServerEndpoint: "10.0.0.10:6443", Token: "abcdef.0123456789abcdef", CACertHashes: []string{"sha256:0123"}}
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+	instanceType := newTestInstanceType("m1.large")
+	instanceType.Overhead = &cloudprovider.InstanceTypeOverhead{KubeReserved: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("70m"),
+		corev1.ResourceMemory: resource.MustParse("1843Mi"),
+	}}
+
+	if _, err := provider.Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{instanceType}); err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	requests := nova.CreateRequests()
+	if len(requests) != 1 {
+		t.Fatalf("expected a single create request, got %d", len(requests))
+	}
+	var body struct {
+		Server struct {
+			UserData string `json:"user_data"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(requests[0], &body); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	userData, err := base64.StdEncoding.DecodeString(body.Server.UserData)
+	if err != nil {
+		t.Fatalf("invalid user data: %v", err)
+	}
+	for _, expected := range []string{"Content-Type: multipart/mixed", "#!/bin/bash\necho 'hello world'", "kubeadm", "apiServerEndpoint: 10.0.0.10:6443", "kube-reserved: cpu=70m,memory=1843Mi"} {
+		if !strings.Contains(string(userData), expected) {
+			t.Errorf("expected user data to contain %q, got:\n%s", expected, userData)
+		}
+	}
+}
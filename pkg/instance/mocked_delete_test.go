@@ -8,6 +8,8 @@ import (
 	"github.com/gophercloud/gophercloud/testhelper"
 	th "github.com/gophercloud/gophercloud/testhelper"
 	"github.com/gophercloud/gophercloud/testhelper/client"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/floatingip"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 )
 
@@ -20,11 +22,14 @@ func TestDeleteInstanceSuccess(t *testing.T) {
 		w.WriteHeader(204)
 	})
 
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+
 	providerClient := client.ServiceClient()
-	provider := NewProvider(providerClient, "test-cluster")
+	provider := NewProvider(providerClient, floatingip.NewProvider(neutron.ServiceClient(), "test-cluster"), nil, "test-cluster", nil, nil)
 
 	ctx := context.Background()
-	err := provider.Delete(ctx, "openstack:///mock-id")
+	err := provider.Delete(ctx, "mock-id")
 	if err != nil {
 		t.Fatalf("expected no error, got: %v", err)
 	}
@@ -40,10 +45,10 @@ func TestDeleteInstanceNotFound(t *testing.T) {
 	})
 
 	providerClient := client.ServiceClient()
-	provider := NewProvider(providerClient, "test-cluster")
+	provider := NewProvider(providerClient, nil, nil, "test-cluster", nil, nil)
 
 	ctx := context.Background()
-	err := provider.Delete(ctx, "openstack:///missing-id")
+	err := provider.Delete(ctx, "missing-id")
 	if err == nil {
 		t.Fatalf("expected error but got none")
 	}
@@ -53,14 +58,3 @@ func TestDeleteInstanceNotFound(t *testing.T) {
 		t.Fatalf("expected NodeClaimNotFoundError, got: %T (%v)", err, err)
 	}
 }
-
-func TestDeleteInvalidProviderID(t *testing.T) {
-	provider := NewProvider(nil, "test-cluster")
-
-	ctx := context.Background()
-	err := provider.Delete(ctx, "wrong-format")
-
-	if err == nil {
-		t.Fatalf("expected parsing error but got nil")
-	}
-}
@@ -0,0 +1,138 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/floatingip"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func newTestFloatingIPNodeClass() *v1openstack.OpenStackNodeClass {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.FloatingIP = true
+	nodeClass.Spec.FloatingIPNetwork = "public"
+	return nodeClass
+}
+
+func TestCreateInstanceAllocatesFloatingIP(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddNetwork(fake.Network{ID: "public"})
+	neutron.AddPort(fake.Port{ID: "port-other", NetworkID: "net-uuid-2", DeviceID: "server-1"})
+	neutron.AddPort(fake.Port{ID: "port-primary", NetworkID: "net-uuid-1", DeviceID: "server-1"})
+
+	provider := newTestProvider(nova)
+	provider.floatingIPProvider = floatingip.NewProvider(neutron.ServiceClient(), "test-cluster")
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+
+	instance, err := provider.Create(context.Background(), newTestFloatingIPNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	fips := neutron.FloatingIPs()
+	if len(fips) != 1 {
+		t.Fatalf("expected a single floating IP, got %d", len(fips))
+	}
+	if instance.FloatingIP != fips[0].Address {
+		t.Errorf("wrong FloatingIP: expected='%s', got='%s'", fips[0].Address, instance.FloatingIP)
+	}
+	if fips[0].PortID != "port-primary" || fips[0].FloatingNetworkID != "public" {
+		t.Errorf("floating IP associated with the wrong port or network: %+v", fips[0])
+	}
+	if fips[0].Description != "karpenter.k8s.openstack/cluster=test-cluster,server=server-1" {
+		t.Errorf("wrong description: %s", fips[0].Description)
+	}
+}
+
+func TestCreateInstanceFloatingIPFailureDeletesServer(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddPort(fake.Port{ID: "port-primary", NetworkID: "net-uuid-1", DeviceID: "server-1"})
+
+	provider := newTestProvider(nova)
+	provider.floatingIPProvider = floatingip.NewProvider(neutron.ServiceClient(), "test-cluster")
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+
+	if _, err := provider.Create(context.Background(), newTestFloatingIPNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")}); err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected the server to be deleted, got %d servers", len(nova.Servers()))
+	}
+}
+
+func TestDeleteInstanceReleasesFloatingIP(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddServer(fake.Server{ID: "server-1"})
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "fip-1", PortID: "port-1", Description: "karpenter.k8s.openstack/cluster=test-cluster,server=server-1"})
+	neutron.AddFloatingIP(fake.FloatingIP{ID: "fip-2", PortID: "port-2", Description: "karpenter.k8s.openstack/cluster=test-cluster,server=server-2"})
+
+	provider := newTestProvider(nova)
+	provider.floatingIPProvider = floatingip.NewProvider(neutron.ServiceClient(), "test-cluster")
+
+	if err := provider.Delete(context.Background(), "server-1"); err != nil {
+		t.Fatalf("failed to delete instance: %v", err)
+	}
+	fips := neutron.FloatingIPs()
+	if len(fips) != 1 || fips[0].ID != "fip-2" {
+		t.Errorf("expected only fip-2 to remain, got %+v", fips)
+	}
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected the server to be deleted")
+	}
+}
+
+func TestCreateInstanceFloatingIPWithoutProvider(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	provider := newTestProvider(nova)
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+
+	_, err := provider.Create(context.Background(), newTestFloatingIPNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if !cloudprovider.IsNodeClassNotReadyError(err) {
+		t.Fatalf("expected NodeClassNotReadyError, got: %v", err)
+	}
+	if len(nova.CreateRequests()) != 0 || len(nova.Servers()) != 0 {
+		t.Errorf("expected no server to be launched, got %d create requests", len(nova.CreateRequests()))
+	}
+}
+
+func TestCreateInstanceFloatingIPFailureDoesNotTryNextFlavor(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	neutron := fake.NewNeutron()
+	defer neutron.Close()
+
+	provider := newTestProvider(nova)
+	provider.floatingIPProvider = floatingip.NewProvider(neutron.ServiceClient(), "test-cluster")
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+	instanceTypes := []*cloudprovider.InstanceType{newTestInstanceType("m1.large"), newTestInstanceType("m1.xlarge")}
+
+	_, err := provider.Create(context.Background(), newTestFloatingIPNodeClass(), nodeClaim, instanceTypes)
+	if err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if cloudprovider.IsInsufficientCapacityError(err) {
+		t.Errorf("expected a floating IP failure not to be reported as insufficient capacity, got: %v", err)
+	}
+	if len(nova.CreateRequests()) != 1 {
+		t.Errorf("expected a single launch, got %d create requests", len(nova.CreateRequests()))
+	}
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected the server to be deleted, got %d servers", len(nova.Servers()))
+	}
+}
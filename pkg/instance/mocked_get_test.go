@@ -0,0 +1,68 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func TestGetInstance(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddServer(fake.Server{ID: "active", Name: "karpenter-active", FlavorID: "m1.large", ImageID: "image-1", Zone: "az-1"})
+
+	instance, err := newTestProvider(nova).Get(context.Background(), "active")
+	if err != nil {
+		t.Fatalf("failed to get instance: %v", err)
+	}
+	if instance.InstanceID != "active" || instance.Name != "karpenter-active" {
+		t.Errorf("unexpected instance: %+v", instance)
+	}
+	if instance.Type != "m1.large" {
+		t.Errorf("wrong type: expected='m1.large', got='%s'", instance.Type)
+	}
+	if instance.ImageID != "image-1" {
+		t.Errorf("wrong ImageID: expected='image-1', got='%s'", instance.ImageID)
+	}
+	if instance.Zone != "az-1" {
+		t.Errorf("wrong Zone: expected='az-1', got='%s'", instance.Zone)
+	}
+}
+
+func TestGetInstanceNotFound(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddServer(fake.Server{ID: "deleted", Status: "DELETED"})
+	nova.AddServer(fake.Server{ID: "soft-deleted", Status: "SOFT_DELETED"})
+
+	for _, instanceID := range []string{"missing", "deleted", "soft-deleted"} {
+		_, err := newTestProvider(nova).Get(context.Background(), instanceID)
+		if !cloudprovider.IsNodeClaimNotFoundError(err) {
+			t.Errorf("%s: expected NodeClaimNotFoundError, got: %T (%v)", instanceID, err, err)
+		}
+	}
+}
+
+func TestGetInstancePopulatesAttachments(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddServer(fake.Server{
+		ID:             "active",
+		FlavorID:       "m1.large",
+		Networks:       []string{"net-1", "net-2"},
+		SecurityGroups: []string{"default"},
+	})
+
+	instance, err := newTestProvider(nova).Get(context.Background(), "active")
+	if err != nil {
+		t.Fatalf("failed to get instance: %v", err)
+	}
+	if len(instance.Networks) != 2 || instance.Networks[0] != "net-1" || instance.Networks[1] != "net-2" {
+		t.Errorf("wrong networks: %v", instance.Networks)
+	}
+	if len(instance.SecurityGroups) != 1 || instance.SecurityGroups[0] != "default" {
+		t.Errorf("wrong security groups: %v", instance.SecurityGroups)
+	}
+}
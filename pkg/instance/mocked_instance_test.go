@@ -2,20 +2,32 @@ package instance
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 	"sigs.k8s.io/karpenter/pkg/scheduling"
 )
 
-func TestCreateInstance(t *testing.T) {
-	ctx := context.Background()
+func newTestProvider(nova *fake.Nova) *DefaultProvider {
+	provider := NewProvider(nova.ServiceClient(), nil, nil, "test-cluster", nil, nil).(*DefaultProvider)
+	provider.pollInterval = time.Millisecond
+	provider.buildTimeout = time.Second
+	return provider
+}
 
-	nodeClass := &v1openstack.OpenStackNodeClass{
+func newTestNodeClass() *v1openstack.OpenStackNodeClass {
+	return &v1openstack.OpenStackNodeClass{
 		Spec: v1openstack.OpenStackNodeClassSpec{
 			Networks: []string{"net-uuid-1"},
 			UserData: "#!/bin/bash\necho 'hello world'",
@@ -25,37 +37,575 @@ func TestCreateInstance(t *testing.T) {
 				},
 			},
 		},
+		Status: v1openstack.OpenStackNodeClassStatus{
+			Images: []v1openstack.Image{{ID: "mock-image-id-456", Architecture: "amd64"}},
+		},
+	}
+}
+
+// newTestInstanceType returns an instance type with an on-demand offering in each zone, or a single
+// zoneless offering when no zone is given. The fake Nova doesn't check flavors, the name doubles as
+// the flavor ID.
+func newTestInstanceType(name string, zones ...string) *cloudprovider.InstanceType {
+	offerings := cloudprovider.Offerings{}
+	for _, zone := range lo.Ternary(len(zones) == 0, []string{""}, zones) {
+		requirements := scheduling.NewRequirements(scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand))
+		if zone != "" {
+			requirements.Add(scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone))
+		}
+		offerings = append(offerings, &cloudprovider.Offering{Requirements: requirements, Available: true})
+	}
+	return &cloudprovider.InstanceType{
+		Name: name,
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(corev1.LabelInstanceTypeStable, corev1.NodeSelectorOpIn, name),
+			scheduling.NewRequirement(v1openstack.LabelFlavorID, corev1.NodeSelectorOpIn, name),
+		),
+		Offerings: offerings,
+	}
+}
+
+func TestCreateInstance(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.BuildPolls = 2
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+
+	instance, err := newTestProvider(nova).Create(ctx, newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	if instance.InstanceID != "server-1" {
+		t.Errorf("wrong ID: expected='server-1', got='%s'", instance.InstanceID)
+	}
+	if instance.Name != "karpenter-test-node-claim" {
+		t.Errorf("wrong name: expected='karpenter-test-node-claim', got='%s'", instance.Name)
+	}
+	if instance.Type != "m1.large" {
+		t.Errorf("wrong type: expected='m1.large', got='%s'", instance.Type)
+	}
+	if instance.ImageID != "mock-image-id-456" {
+		t.Errorf("wrong ImageID: expected='mock-image-id-456', got='%s'", instance.ImageID)
+	}
+	if instance.Status != "ACTIVE" {
+		t.Errorf("wrong status: expected='ACTIVE', got='%s'", instance.Status)
+	}
+	if len(nova.CreateRequests()) != 1 {
+		t.Errorf("expected a single create request, got %d", len(nova.CreateRequests()))
+	}
+}
+
+func TestCreateInstanceRetriesFailedPolls(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.BuildPolls = 1
+	nova.GetFailures = 2
+
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+	instance, err := newTestProvider(nova).Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.Status != "ACTIVE" {
+		t.Errorf("wrong status: expected='ACTIVE', got='%s'", instance.Status)
+	}
+	if _, ok := nova.GetServer(instance.InstanceID); !ok {
+		t.Errorf("expected server %s to be kept", instance.InstanceID)
+	}
+}
+
+func TestCreateInstanceWithoutCompatibleOffering(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+		Spec: karpv1.NodeClaimSpec{Requirements: []karpv1.NodeSelectorRequirementWithMinValues{
+			{NodeSelectorRequirement: corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"az-2"}}},
+		}},
+	}
+	_, err := newTestProvider(nova).Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large", "az-1")})
+	if err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if cloudprovider.IsInsufficientCapacityError(err) {
+		t.Errorf("expected a regular error, got InsufficientCapacityError: %v", err)
+	}
+	if len(nova.CreateRequests()) != 0 {
+		t.Errorf("expected no create request, got %d", len(nova.CreateRequests()))
+	}
+}
+
+func TestCreateInstanceFallsBackOnNoValidHost(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.Faults["m1.large"] = fake.Fault{Code: 500, Message: "No valid host was found. There are not enough hosts available."}
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+
+	instance, err := newTestProvider(nova).Create(ctx, newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{
+		newTestInstanceType("m1.large"),
+		newTestInstanceType("m1.medium"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	if instance.Type != "m1.medium" {
+		t.Errorf("wrong type: expected='m1.medium', got='%s'", instance.Type)
+	}
+	if _, ok := nova.GetServer("server-1"); ok {
+		t.Errorf("expected the errored server to be deleted")
+	}
+	if _, ok := nova.GetServer(instance.InstanceID); !ok {
+		t.Errorf("expected server %s to exist", instance.InstanceID)
+	}
+}
+
+func TestCreateInstanceMarksOfferingUnavailable(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.Faults["m1.large"] = fake.Fault{Code: 500, Message: "No valid host was found."}
+	nova.Rejections["m1.xlarge"] = fake.Rejection{StatusCode: http.StatusBadRequest, Message: "Invalid flavorRef"}
+	provider := newTestProvider(nova)
+	provider.unavailableOfferings = cache.NewUnavailableOfferings()
+
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+	instance, err := provider.Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{
+		newTestInstanceType("m1.large", "az-1"),
+		newTestInstanceType("m1.xlarge", "az-1"),
+		newTestInstanceType("m1.medium", "az-1"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.Type != "m1.medium" {
+		t.Errorf("wrong type: expected='m1.medium', got='%s'", instance.Type)
+	}
+	// Only capacity failures are remembered.
+	for flavor, expected := range map[string]bool{"m1.large": true, "m1.xlarge": false, "m1.medium": false} {
+		if actual := provider.unavailableOfferings.IsUnavailable(flavor, "az-1", karpv1.CapacityTypeOnDemand); actual != expected {
+			t.Errorf("expected %s in az-1 to be unavailable=%v, got %v", flavor, expected, actual)
+		}
+	}
+	if provider.unavailableOfferings.IsUnavailable("m1.large", "az-2", karpv1.CapacityTypeOnDemand) {
+		t.Errorf("expected m1.large to stay available in az-2")
+	}
+}
+
+func TestCreateInstanceInsufficientCapacity(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.Faults["m1.large"] = fake.Fault{Code: 500, Message: "No valid host was found."}
+	nova.Rejections["m1.medium"] = fake.Rejection{
+		StatusCode: http.StatusForbidden,
+		Message:    "Quota exceeded for cores: Requested 4, but already used 20 of 20 cores",
+	}
+	// Older Nova releases answer 413 to quota failures.
+	nova.Rejections["m1.small"] = fake.Rejection{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Message:    "Maximum number of instances allowed by the project quota reached",
+	}
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+
+	_, err := newTestProvider(nova).Create(ctx, newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{
+		newTestInstanceType("m1.large"),
+		newTestInstanceType("m1.medium"),
+		newTestInstanceType("m1.small"),
+	})
+	if err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if !cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("expected InsufficientCapacityError, got: %T (%v)", err, err)
+	}
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected no servers to be left behind, got %d", len(nova.Servers()))
+	}
+}
+
+func TestCreateInstanceErrorState(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.Faults["m1.large"] = fake.Fault{Code: 500, Message: "Build of instance aborted: Failure prepping block device."}
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+
+	_, err := newTestProvider(nova).Create(ctx, newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("expected a regular error, got InsufficientCapacityError: %v", err)
+	}
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected the errored server to be deleted")
+	}
+}
+
+func TestCreateInstanceSelectsImageByArchitecture(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+	nodeClass := newTestNodeClass()
+	nodeClass.Status.Images = []v1openstack.Image{
+		{ID: "image-amd64", Architecture: "amd64"},
+		{ID: "image-arm64", Architecture: "arm64"},
+	}
+	instanceType := newTestInstanceType("a1.large")
+	instanceType.Requirements.Add(scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, "arm64"))
+
+	instance, err := newTestProvider(nova).Create(ctx, nodeClass, nodeClaim, []*cloudprovider.InstanceType{instanceType})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.ImageID != "image-arm64" {
+		t.Errorf("wrong ImageID: expected='image-arm64', got='%s'", instance.ImageID)
+	}
+}
+
+func TestCreateInstanceSelectsImageByNodeClaimArchitecture(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+		Spec: karpv1.NodeClaimSpec{Requirements: []karpv1.NodeSelectorRequirementWithMinValues{
+			{NodeSelectorRequirement: corev1.NodeSelectorRequirement{Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: []string{"arm64"}}},
+		}},
+	}
+	nodeClass := newTestNodeClass()
+	nodeClass.Status.Images = []v1openstack.Image{
+		{ID: "image-amd64", Architecture: "amd64"},
+		{ID: "image-arm64", Architecture: "arm64"},
+	}
+	instanceType := newTestInstanceType("m1.large")
+	instanceType.Requirements.Add(scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, "amd64", "arm64"))
+
+	instance, err := newTestProvider(nova).Create(ctx, nodeClass, nodeClaim, []*cloudprovider.InstanceType{instanceType})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.ImageID != "image-arm64" {
+		t.Errorf("wrong ImageID: expected='image-arm64', got='%s'", instance.ImageID)
+	}
+}
+
+func TestCreateInstanceWithoutResolvedImages(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	ctx := context.Background()
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+	}
+	nodeClass := newTestNodeClass()
+	nodeClass.Status.Images = nil
+
+	if _, err := newTestProvider(nova).Create(ctx, nodeClass, nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")}); err == nil {
+		t.Fatalf("expected error but got none")
+	}
+	if len(nova.CreateRequests()) != 0 {
+		t.Errorf("expected no create request, got %d", len(nova.CreateRequests()))
+	}
+}
+
+func TestCreateInstanceHonorsZoneRequirement(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("az-2", true)
+
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+		Spec: karpv1.NodeClaimSpec{
+			Requirements: []karpv1.NodeSelectorRequirementWithMinValues{
+				{NodeSelectorRequirement: corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"az-2"}}},
+			},
+		},
+	}
+	instanceTypes := []*cloudprovider.InstanceType{
+		// Only offered in a zone the NodeClaim does not allow.
+		newTestInstanceType("m1.xlarge", "az-1"),
+		newTestInstanceType("m1.large", "az-1", "az-2"),
 	}
+
+	instance, err := newTestProvider(nova).Create(context.Background(), newTestNodeClass(), nodeClaim, instanceTypes)
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.Type != "m1.large" || instance.Zone != "az-2" {
+		t.Errorf("expected m1.large in az-2, got %s in %s", instance.Type, instance.Zone)
+	}
+	server, ok := nova.GetServer(instance.InstanceID)
+	if !ok || server.Zone != "az-2" {
+		t.Errorf("expected the server to be launched in az-2, got %+v", server)
+	}
+	if len(nova.CreateRequests()) != 1 {
+		t.Errorf("expected a single create request, got %d", len(nova.CreateRequests()))
+	}
+}
+
+func TestCreateInstanceTriesCheapestFlavorFirst(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
 	nodeClaim := &karpv1.NodeClaim{
 		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
 	}
+	expensive := newTestInstanceType("m1.xlarge")
+	expensive.Offerings[0].Price = 0.4
+	cheap := newTestInstanceType("m1.large")
+	cheap.Offerings[0].Price = 0.2
 
-	instanceType := &cloudprovider.InstanceType{
-		Name: "m1.large",
+	instance, err := newTestProvider(nova).Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{expensive, cheap})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+	if instance.Type != "m1.large" {
+		t.Errorf("expected the cheapest flavor m1.large, got %s", instance.Type)
+	}
+}
+
+// init registers the reservation labels, as the operator does.
+func init() {
+	cloudprovider.ReservationIDLabel = v1openstack.LabelReservationID
+	cloudprovider.ReservedCapacityLabels.Insert(v1openstack.LabelReservationType)
+	karpv1.WellKnownLabels = karpv1.WellKnownLabels.Insert(v1openstack.LabelReservationID, v1openstack.LabelReservationType)
+}
+
+// withReservedOffering adds a reserved offering of the Blazar reservation to the instance type.
+func withReservedOffering(instanceType *cloudprovider.InstanceType, reservationID, reservationType string) *cloudprovider.InstanceType {
+	instanceType.Offerings = append(cloudprovider.Offerings{{
 		Requirements: scheduling.NewRequirements(
-			scheduling.NewRequirement("instance-type", "In", "m1.large"),
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeReserved),
+			scheduling.NewRequirement(v1openstack.LabelReservationID, corev1.NodeSelectorOpIn, reservationID),
+			scheduling.NewRequirement(v1openstack.LabelReservationType, corev1.NodeSelectorOpIn, reservationType),
 		),
+		Available:           true,
+		ReservationCapacity: 1,
+	}}, instanceType.Offerings...)
+	return instanceType
+}
+
+func TestCreateInstanceIntoReservation(t *testing.T) {
+	cases := []struct {
+		name            string
+		reservationType string
+		flavorRef       string
+	}{
+		{name: "host reservation", reservationType: v1openstack.ReservationTypeHost, flavorRef: "m1.large"},
+		{name: "instance reservation", reservationType: v1openstack.ReservationTypeInstance, flavorRef: "reservation-1"},
 	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nova := fake.NewNova()
+			defer nova.Close()
+			onDemand := newTestInstanceType("m1.medium")
+			onDemand.Offerings[0].Price = 0.1
+			reserved := withReservedOffering(newTestInstanceType("m1.large"), "reservation-1", tc.reservationType)
+			reserved.Offerings[1].Price = 0.2
+
+			nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+			instance, err := newTestProvider(nova).Create(context.Background(), newTestNodeClass(), nodeClaim, []*cloudprovider.InstanceType{onDemand, reserved})
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+			if instance.Type != "m1.large" || instance.CapacityType != karpv1.CapacityTypeReserved || instance.ReservationID != "reservation-1" || instance.ReservationType != tc.reservationType {
+				t.Errorf("expected a reserved m1.large in reservation-1, got %s %s %s %s", instance.Type, instance.CapacityType, instance.ReservationID, instance.ReservationType)
+			}
+
+			var body struct {
+				Server struct {
+					FlavorRef string            `json:"flavorRef"`
+					Metadata  map[string]string `json:"metadata"`
+				} `json:"server"`
+				SchedulerHints map[string]interface{} `json:"os:scheduler_hints"`
+			}
+			if err := json.Unmarshal(nova.CreateRequests()[0], &body); err != nil {
+				t.Fatalf("invalid request body: %v", err)
+			}
+			if body.Server.FlavorRef != tc.flavorRef {
+				t.Errorf("expected flavorRef %s, got %s", tc.flavorRef, body.Server.FlavorRef)
+			}
+			if hint := body.SchedulerHints["reservation"]; hint != "reservation-1" {
+				t.Errorf("expected the reservation scheduler hint, got %v", body.SchedulerHints)
+			}
+			if reservationID := body.Server.Metadata[v1openstack.ReservationMetadataKey]; reservationID != "reservation-1" {
+				t.Errorf("expected the reservation in the server metadata, got %v", body.Server.Metadata)
+			}
+			if reservationType := body.Server.Metadata[v1openstack.ReservationTypeMetadataKey]; reservationType != tc.reservationType {
+				t.Errorf("expected the reservation type in the server metadata, got %v", body.Server.Metadata)
+			}
 
-	test := NewProvider(nil, "test-cluster")
-	visu := test.(*DefaultProvider)
-	fmt.Println("aquiiii:", visu.clusterName)
+			// The server is recognised as reserved when read back from Nova.
+			server, err := newTestProvider(nova).Get(context.Background(), instance.InstanceID)
+			if err != nil {
+				t.Fatalf("failed to get instance: %v", err)
+			}
+			if server.CapacityType != karpv1.CapacityTypeReserved || server.ReservationID != "reservation-1" || server.ReservationType != tc.reservationType {
+				t.Errorf("expected a reserved server in reservation-1, got %s %s %s", server.CapacityType, server.ReservationID, server.ReservationType)
+			}
+		})
+	}
+}
 
-	instance, err := test.Create(ctx, nodeClass, nodeClaim, []*cloudprovider.InstanceType{instanceType})
+func TestInstanceFromServerReservationType(t *testing.T) {
+	// Since microversion 2.47 Nova reports the flavor of a server by name only.
+	server := &servers.Server{
+		ID:     "server-1",
+		Flavor: map[string]interface{}{"original_name": "reservation:reservation-1"},
+		Metadata: map[string]string{
+			v1openstack.ReservationMetadataKey:     "reservation-1",
+			v1openstack.ReservationTypeMetadataKey: v1openstack.ReservationTypeInstance,
+		},
+	}
+	if instance := instanceFromServer(server); instance.ReservationType != v1openstack.ReservationTypeInstance {
+		t.Errorf("expected an instance reservation, got %s", instance.ReservationType)
+	}
+}
+
+func TestCreateInstanceFallsBackToOnDemand(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.Faults["reservation-1"] = fake.Fault{Code: 500, Message: "No valid host was found. There are not enough hosts available."}
+	provider := newTestProvider(nova)
+	provider.unavailableOfferings = cache.NewUnavailableOfferings()
+	instanceTypes := []*cloudprovider.InstanceType{withReservedOffering(newTestInstanceType("m1.large"), "reservation-1", v1openstack.ReservationTypeInstance)}
+
+	// The NodeClaim is pinned to the reservation by the scheduler, its launch fails.
+	reservedClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"},
+		Spec: karpv1.NodeClaimSpec{Requirements: []karpv1.NodeSelectorRequirementWithMinValues{
+			{NodeSelectorRequirement: corev1.NodeSelectorRequirement{Key: karpv1.CapacityTypeLabelKey, Operator: corev1.NodeSelectorOpIn, Values: []string{karpv1.CapacityTypeReserved}}},
+		}},
+	}
+	if _, err := provider.Create(context.Background(), newTestNodeClass(), reservedClaim, instanceTypes); !cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("expected an insufficient capacity error, got %v", err)
+	}
+	if !provider.unavailableOfferings.IsUnavailable("m1.large", "", karpv1.CapacityTypeReserved) {
+		t.Errorf("expected the reserved offering to be marked unavailable")
+	}
+
+	// Once the reserved offering is left out, the next NodeClaim launches on demand.
+	instanceTypes[0].Offerings[0].Available = false
+	instance, err := provider.Create(context.Background(), newTestNodeClass(), &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim-2"}}, instanceTypes)
 	if err != nil {
-		t.Fatalf("Falha ao criar instância: %v", err)
+		t.Fatalf("failed to create instance: %v", err)
 	}
+	if instance.CapacityType != karpv1.CapacityTypeOnDemand || instance.ReservationID != "" {
+		t.Errorf("expected an on-demand instance, got %s %s", instance.CapacityType, instance.ReservationID)
+	}
+}
+
+func withSpotOffering(instanceType *cloudprovider.InstanceType, price float64) *cloudprovider.InstanceType {
+	instanceType.Offerings = append(instanceType.Offerings, &cloudprovider.Offering{
+		Requirements: scheduling.NewRequirements(scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot)),
+		Price:        price,
+		Available:    true,
+	})
+	return instanceType
+}
 
-	if instance.InstanceID != "mock-server-id-123" {
-		t.Errorf("ID incorreto: esperado='mock-server-id-123', obtido='%s'", instance.InstanceID)
+func TestCreateSpotInstance(t *testing.T) {
+	cases := []struct {
+		name        string
+		preemptible v1openstack.Preemptible
+	}{
+		{name: "preemptible flavor", preemptible: v1openstack.Preemptible{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "m1.*"}}}},
+		{name: "preemptible project", preemptible: v1openstack.Preemptible{Project: true}},
 	}
-	if instance.Type != "m1.large" {
-		t.Errorf("Tipo incorreto: esperado='m1.large', obtido='%s'", instance.Type)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			nova := fake.NewNova()
+			defer nova.Close()
+			preemptibleNova := fake.NewNova()
+			defer preemptibleNova.Close()
+			provider := newTestProvider(nova)
+			provider.preemptibleComputeClient = preemptibleNova.ServiceClient()
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.Preemptible = &tc.preemptible
+			instanceType := newTestInstanceType("m1.large")
+			instanceType.Offerings[0].Price = 0.2
+			withSpotOffering(instanceType, 0.1)
+
+			nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+			instance, err := provider.Create(context.Background(), nodeClass, nodeClaim, []*cloudprovider.InstanceType{instanceType})
+			if err != nil {
+				t.Fatalf("failed to create instance: %v", err)
+			}
+			if instance.CapacityType != karpv1.CapacityTypeSpot {
+				t.Errorf("expected a spot instance, got %s", instance.CapacityType)
+			}
+			launchedIn, other := nova, preemptibleNova
+			if tc.preemptible.Project {
+				launchedIn, other = preemptibleNova, nova
+			}
+			if len(other.Servers()) != 0 || len(launchedIn.Servers()) != 1 {
+				t.Fatalf("expected the server to be launched in the %s project", lo.Ternary(tc.preemptible.Project, "preemptible", "controller"))
+			}
+			if capacityType := launchedIn.Servers()[0].Metadata[v1openstack.CapacityTypeMetadataKey]; capacityType != karpv1.CapacityTypeSpot {
+				t.Errorf("expected the capacity type in the server metadata, got %q", capacityType)
+			}
+
+			// The server is found in its project and recognised as spot when read back from Nova.
+			server, err := provider.Get(context.Background(), instance.InstanceID)
+			if err != nil {
+				t.Fatalf("failed to get instance: %v", err)
+			}
+			if server.CapacityType != karpv1.CapacityTypeSpot {
+				t.Errorf("expected a spot server, got %s", server.CapacityType)
+			}
+			instances, err := provider.List(context.Background())
+			if err != nil {
+				t.Fatalf("failed to list instances: %v", err)
+			}
+			if len(instances) != 1 || instances[0].InstanceID != instance.InstanceID {
+				t.Errorf("expected the spot server to be listed, got %+v", instances)
+			}
+			if err := provider.Delete(context.Background(), instance.InstanceID); err != nil {
+				t.Fatalf("failed to delete instance: %v", err)
+			}
+			if len(launchedIn.Servers()) != 0 {
+				t.Errorf("expected the server to be deleted")
+			}
+		})
 	}
-	if instance.ImageID != "mock-image-id-456" {
-		t.Errorf("ImageID incorreto: esperado='mock-image-id-456', obtido='%s'", instance.ImageID)
+}
+
+func TestCreateSpotInstanceWithoutPreemptibleProject(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.Preemptible = &v1openstack.Preemptible{Project: true}
+	instanceTypes := []*cloudprovider.InstanceType{withSpotOffering(newTestInstanceType("m1.large"), 0.1)}
+	instanceTypes[0].Offerings[0].Available = false
+
+	nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: "test-node-claim"}}
+	if _, err := newTestProvider(nova).Create(context.Background(), nodeClass, nodeClaim, instanceTypes); err == nil || cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("expected a launch error, got %v", err)
 	}
-	if instance.Status != "BUILD" {
-		t.Errorf("Status incorreto: esperado='BUILD', obtido='%s'", instance.Status)
+	if len(nova.Servers()) != 0 {
+		t.Errorf("expected no server to be launched in the controller project")
 	}
 }
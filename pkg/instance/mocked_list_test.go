@@ -0,0 +1,89 @@
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+func TestCreateInstanceSetsOwnershipMetadata(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	nodeClass := newTestNodeClass()
+	nodeClass.Name = "default"
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-node-claim",
+			Labels: map[string]string{karpv1.NodePoolLabelKey: "general"},
+		},
+	}
+
+	instance, err := newTestProvider(nova).Create(context.Background(), nodeClass, nodeClaim, []*cloudprovider.InstanceType{newTestInstanceType("m1.large")})
+	if err != nil {
+		t.Fatalf("failed to create instance: %v", err)
+	}
+
+	expected := map[string]string{
+		v1openstack.ClusterMetadataKey:   "test-cluster",
+		v1openstack.NodeClaimMetadataKey: "test-node-claim",
+		v1openstack.NodeClassMetadataKey: "default",
+		v1openstack.NodePoolMetadataKey:  "general",
+	}
+	for key, value := range expected {
+		if instance.Metadata[key] != value {
+			t.Errorf("wrong metadata %s: expected='%s', got='%s'", key, value, instance.Metadata[key])
+		}
+	}
+}
+
+func TestListInstances(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+
+	nova.AddServer(fake.Server{
+		ID:       "owned",
+		Name:     "karpenter-owned",
+		FlavorID: "m1.large",
+		ImageID:  "image-1",
+		Metadata: map[string]string{v1openstack.ClusterMetadataKey: "test-cluster"},
+	})
+	nova.AddServer(fake.Server{
+		ID:       "other-cluster",
+		FlavorID: "m1.large",
+		Metadata: map[string]string{v1openstack.ClusterMetadataKey: "other-cluster"},
+	})
+	nova.AddServer(fake.Server{ID: "unmanaged", FlavorID: "m1.large"})
+	nova.AddServer(fake.Server{
+		ID:       "soft-deleted",
+		Status:   "SOFT_DELETED",
+		FlavorID: "m1.large",
+		Metadata: map[string]string{v1openstack.ClusterMetadataKey: "test-cluster"},
+	})
+
+	instances, err := newTestProvider(nova).List(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list instances: %v", err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("expected 1 instance, got %d", len(instances))
+	}
+	instance := instances[0]
+	if instance.InstanceID != "owned" {
+		t.Errorf("wrong ID: expected='owned', got='%s'", instance.InstanceID)
+	}
+	if instance.Type != "m1.large" {
+		t.Errorf("wrong type: expected='m1.large', got='%s'", instance.Type)
+	}
+	if instance.ImageID != "image-1" {
+		t.Errorf("wrong ImageID: expected='image-1', got='%s'", instance.ImageID)
+	}
+	if instance.CreationTime.IsZero() {
+		t.Errorf("expected creation time to be set")
+	}
+}
@@ -1,12 +1,33 @@
 package instance
 
+import "time"
 
 type Instance struct {
-	Name       string
-	Type       string
-	ImageID    string
-	Metadata   map[string]string
-	UserData   []byte
-	InstanceID string
-	Status     string
-}
\ No newline at end of file
+	Name         string
+	Type         string
+	ImageID      string
+	Metadata     map[string]string
+	UserData     []byte
+	InstanceID   string
+	Status       string
+	CreationTime time.Time
+	// Zone is the availability zone the server was scheduled to.
+	Zone string
+	// FlavorID is the ID of the flavor of the server. Type is the flavor name, or the ID as well when
+	// Nova doesn't report the name.
+	FlavorID string
+	// CapacityType is "reserved" for the servers launched into a Blazar reservation, "spot" for the
+	// servers of the preemptible tier, and "on-demand" otherwise. ReservationID and ReservationType
+	// identify the reservation.
+	CapacityType    string
+	ReservationID   string
+	ReservationType string
+
+	// Networks holds the IDs of the networks the server is attached to. It is only
+	// populated by Get, as it requires an additional request per server.
+	Networks []string
+	// SecurityGroups holds the names of the security groups applied to the server.
+	SecurityGroups []string
+	// FloatingIP is the floating IP address associated with the server, if any.
+	FloatingIP string
+}
@@ -0,0 +1,101 @@
+package instancetype
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/image"
+)
+
+const defaultCPUPolicy = "shared"
+
+// extraSpecLabels are the extra specs mapped one to one to a well-known label.
+var extraSpecLabels = map[string]string{
+	"hw:cpu_policy":    v1openstack.LabelCPUPolicy,
+	"hw:mem_page_size": v1openstack.LabelMemPageSize,
+	"hw:cpu_sockets":   v1openstack.LabelCPUSockets,
+}
+
+// extraSpecPrefixes map the open-ended extra specs to the prefix of their label.
+var extraSpecPrefixes = map[string]string{
+	"trait:":                          v1openstack.LabelTraitPrefix,
+	"aggregate_instance_extra_specs:": v1openstack.LabelAggregatePrefix,
+	"resources:CUSTOM_":               v1openstack.LabelResourcePrefix + "CUSTOM_",
+}
+
+// labelsFromExtraSpecs returns the labels a flavor gets from its extra specs. Extra specs that
+// don't make a valid label key or value are ignored.
+func labelsFromExtraSpecs(extraSpecs map[string]string) map[string]string {
+	labels := map[string]string{}
+	for key, value := range extraSpecs {
+		label, ok := extraSpecLabels[key]
+		if !ok {
+			for specPrefix, labelPrefix := range extraSpecPrefixes {
+				if name, found := strings.CutPrefix(key, specPrefix); found && name != "" {
+					label, ok = labelPrefix+name, true
+				}
+			}
+		}
+		if !ok || len(validation.IsQualifiedName(label)) > 0 || len(validation.IsValidLabelValue(value)) > 0 {
+			continue
+		}
+		labels[label] = value
+	}
+	return labels
+}
+
+// extraSpecRequirements turns the labels of a flavor into requirements. Labels the flavor lacks
+// become DoesNotExist requirements, so that pods selecting them never land on the flavor: the keys
+// are those of the well-known labels plus the given keys found on other flavors of the catalog.
+func extraSpecRequirements(labels map[string]string, catalogKeys sets.Set[string]) []*scheduling.Requirement {
+	var requirements []*scheduling.Requirement
+	for _, key := range sets.List(catalogKeys.Union(sets.KeySet(labels)).Insert(v1openstack.LabelCPUPolicy, v1openstack.LabelMemPageSize, v1openstack.LabelCPUSockets)) {
+		value, ok := labels[key]
+		switch {
+		case ok:
+			requirements = append(requirements, scheduling.NewRequirement(key, corev1.NodeSelectorOpIn, value))
+		case key == v1openstack.LabelCPUPolicy:
+			requirements = append(requirements, scheduling.NewRequirement(key, corev1.NodeSelectorOpIn, defaultCPUPolicy))
+		default:
+			requirements = append(requirements, scheduling.NewRequirement(key, corev1.NodeSelectorOpDoesNotExist))
+		}
+	}
+	return requirements
+}
+
+// architectureExtraSpecs pin a flavor to the hypervisors of a CPU architecture, directly or through
+// the metadata of their host aggregates.
+var architectureExtraSpecs = []string{
+	"hw:cpu_arch",
+	"capabilities:cpu_arch",
+	"capabilities:cpu_info:arch",
+	"aggregate_instance_extra_specs:cpu_arch",
+	"aggregate_instance_extra_specs:architecture",
+}
+
+// osExtraSpecs pin a flavor to the hosts licensed for an operating system.
+var osExtraSpecs = []string{
+	"os_type",
+	"aggregate_instance_extra_specs:os_type",
+}
+
+// flavorPlatforms returns the kubernetes.io/arch and kubernetes.io/os values the flavor is
+// restricted to by its extra specs. Either is empty when the flavor runs anything.
+func flavorPlatforms(extraSpecs map[string]string) (sets.Set[string], sets.Set[string]) {
+	values := func(keys []string, normalize func(string) string) sets.Set[string] {
+		result := sets.New[string]()
+		for _, key := range keys {
+			// Capabilities may list several values with the <in> operator.
+			for _, value := range strings.Fields(strings.TrimPrefix(extraSpecs[key], "<in>")) {
+				result.Insert(normalize(value))
+			}
+		}
+		return result
+	}
+	return values(architectureExtraSpecs, image.NormalizeArchitecture), values(osExtraSpecs, strings.ToLower)
+}
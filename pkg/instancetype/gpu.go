@@ -0,0 +1,128 @@
+package instancetype
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+const (
+	// pciPassthroughAlias requests PCI devices by the aliases configured in Nova, as
+	// <alias>:<count>[,<alias>:<count>].
+	pciPassthroughAlias = "pci_passthrough:alias"
+	// VGPUAlias stands for the vGPUs requested by a resources:VGPU extra spec in the GPU resources.
+	VGPUAlias = "VGPU"
+)
+
+// GPUResource is the extended resource the GPUs of a PCI alias, or the vGPUs, are exposed as.
+type GPUResource struct {
+	// Name is the extended resource, e.g. nvidia.com/gpu.
+	Name corev1.ResourceName
+	// Model is the value of the GPU name label of the flavors. The label is not set when it is empty.
+	Model string
+}
+
+// DefaultGPUResources exposes the vGPUs as nvidia.com/gpu. The PCI aliases are named by the operators
+// of the cloud, the devices behind them are only exposed once configured.
+var DefaultGPUResources = map[string]GPUResource{VGPUAlias: {Name: "nvidia.com/gpu"}}
+
+// ParseGPUResources parses comma separated <alias>=<resource>[:<model>] mappings of the PCI aliases,
+// or VGPU, to their extended resource and model, e.g. "a100=nvidia.com/gpu:a100".
+func ParseGPUResources(value string) (map[string]GPUResource, error) {
+	gpuResources := map[string]GPUResource{}
+	for _, mapping := range strings.Split(value, ",") {
+		mapping = strings.TrimSpace(mapping)
+		if mapping == "" {
+			continue
+		}
+		alias, target, ok := strings.Cut(mapping, "=")
+		if !ok || alias == "" {
+			return nil, fmt.Errorf("expected <alias>=<resource>[:<model>], got %q", mapping)
+		}
+		name, model, _ := strings.Cut(target, ":")
+		if errs := validation.IsQualifiedName(name); len(errs) > 0 || !strings.Contains(name, "/") {
+			return nil, fmt.Errorf("resource of alias %s must be a domain-prefixed resource name, got %q", alias, name)
+		}
+		if errs := validation.IsValidLabelValue(model); len(errs) > 0 {
+			return nil, fmt.Errorf("model of alias %s is not a valid label value: %s", alias, strings.Join(errs, ", "))
+		}
+		gpuResources[alias] = GPUResource{Name: corev1.ResourceName(name), Model: model}
+	}
+	return gpuResources, nil
+}
+
+// flavorGPUs returns the GPUs the flavor requests through its extra specs: the amount of each extended
+// resource, their models and their total. Aliases without a configured resource are left out.
+func flavorGPUs(extraSpecs map[string]string, gpuResources map[string]GPUResource) (corev1.ResourceList, []string, int64) {
+	counts := map[string]int64{}
+	for _, request := range strings.Split(extraSpecs[pciPassthroughAlias], ",") {
+		alias, count, found := strings.Cut(strings.TrimSpace(request), ":")
+		amount := int64(1)
+		if found {
+			parsed, err := strconv.ParseInt(strings.TrimSpace(count), 10, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			amount = parsed
+		}
+		if alias != "" {
+			counts[alias] += amount
+		}
+	}
+	// vGPUs are requested as resources:VGPU, or resources<group>:VGPU in granular requests.
+	for key, value := range extraSpecs {
+		if !strings.HasPrefix(key, "resources") || !strings.HasSuffix(key, ":"+VGPUAlias) {
+			continue
+		}
+		if amount, err := strconv.ParseInt(value, 10, 64); err == nil && amount > 0 {
+			counts[VGPUAlias] += amount
+		}
+	}
+
+	gpus := corev1.ResourceList{}
+	models := map[string]bool{}
+	var total int64
+	for alias, count := range counts {
+		gpuResource, ok := gpuResources[alias]
+		if !ok {
+			continue
+		}
+		quantity := gpus[gpuResource.Name]
+		quantity.Add(*resource.NewQuantity(count, resource.DecimalSI))
+		gpus[gpuResource.Name] = quantity
+		if gpuResource.Model != "" {
+			models[gpuResource.Model] = true
+		}
+		total += count
+	}
+	modelList := make([]string, 0, len(models))
+	for model := range models {
+		modelList = append(modelList, model)
+	}
+	sort.Strings(modelList)
+	return gpus, modelList, total
+}
+
+// gpuRequirements returns the requirements on the GPU labels. Flavors without GPUs don't have them, so
+// that pods selecting a GPU model never land on them.
+func gpuRequirements(models []string, count int64) []*scheduling.Requirement {
+	requirements := []*scheduling.Requirement{
+		scheduling.NewRequirement(v1openstack.LabelGPUName, corev1.NodeSelectorOpDoesNotExist),
+		scheduling.NewRequirement(v1openstack.LabelGPUCount, corev1.NodeSelectorOpDoesNotExist),
+	}
+	if len(models) > 0 {
+		requirements[0] = scheduling.NewRequirement(v1openstack.LabelGPUName, corev1.NodeSelectorOpIn, models...)
+	}
+	if count > 0 {
+		requirements[1] = scheduling.NewRequirement(v1openstack.LabelGPUCount, corev1.NodeSelectorOpIn, strconv.FormatInt(count, 10))
+	}
+	return requirements
+}
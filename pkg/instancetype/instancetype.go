@@ -3,12 +3,27 @@ package instancetype
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/availabilityzones"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/placement"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/quota"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/reservation"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
@@ -17,89 +32,477 @@ import (
 
 type Provider interface {
 	List(context.Context, *v1openstack.OpenStackNodeClass) ([]*cloudprovider.InstanceType, error)
+	// SelectFlavors returns the flavors selected by the FlavorSelectorTerms of the NodeClass.
+	SelectFlavors(context.Context, *v1openstack.OpenStackNodeClass) ([]flavors.Flavor, error)
+	// SelectPreemptibleFlavors returns the flavors selected by the FlavorSelectorTerms of the
+	// preemptible tier of the NodeClass, which are only offered as spot capacity.
+	SelectPreemptibleFlavors(context.Context, *v1openstack.OpenStackNodeClass) ([]flavors.Flavor, error)
+	// RemainingQuota returns the last known Nova quota left to the project, or nil when it hasn't
+	// been read yet.
+	RemainingQuota() *quota.Remaining
 }
 
 type DefaultProvider struct {
+	// InstanceTypesInfo is the last flavor catalog listed from Nova. UpdateInstanceTypes replaces it
+	// while List is serving requests, so it is guarded by mu.
 	InstanceTypesInfo []flavors.Flavor
+	// ExtraSpecs holds the extra specs of the flavors, by flavor ID.
+	ExtraSpecs map[string]map[string]string
+	// Zones holds the available Nova availability zones. Every flavor is offered in each of them.
+	Zones []string
+	// Quota is the Nova quota left to the project. Offerings of the flavors that would exceed it are
+	// unavailable. Every offering is available until it has been read.
+	Quota *quota.Remaining
+	// PricingProvider prices the offerings. Offerings have no price when it is nil.
+	PricingProvider pricing.Provider
+	// Capacity is the free capacity of the compute nodes read from Placement. Offerings of the
+	// flavors that no longer fit in a zone are unavailable. It is nil unless PlacementClient is set.
+	Capacity *placement.Capacity
+	// PlacementClient reads the capacity of the compute nodes. It requires the admin or reader role,
+	// offerings are not restricted by capacity without it.
+	PlacementClient *gophercloud.ServiceClient
+	// Reservations are the active Blazar reservations of the project. The flavors they can take get
+	// reserved offerings. It is nil unless ReservationClient is set.
+	Reservations []reservation.Reservation
+	// ReservationClient reads the Blazar leases of the project. Only on-demand offerings are
+	// created without it.
+	ReservationClient *gophercloud.ServiceClient
+	// PreemptibleQuota is the Nova quota left to the preemptible project. It is nil unless
+	// PreemptibleComputeClient is set.
+	PreemptibleQuota *quota.Remaining
+	// PreemptibleComputeClient reads the quota of the preemptible project. The NodeClasses launching
+	// spot servers in the preemptible project only get spot offerings when it is set.
+	PreemptibleComputeClient *gophercloud.ServiceClient
+	// UnavailableOfferings holds the offerings Nova recently had no capacity for. They are offered
+	// again once they expire.
+	UnavailableOfferings *cache.UnavailableOfferings
+	// FlavorNamePattern parses the family and size labels from the flavor names. It defaults to
+	// DefaultFlavorNamePattern.
+	FlavorNamePattern *regexp.Regexp
+	// GPUResources maps the PCI aliases of the flavors, and VGPU, to the extended resources their GPUs
+	// are exposed as. It defaults to DefaultGPUResources.
+	GPUResources map[string]GPUResource
+
+	computeClient *gophercloud.ServiceClient
+	mu            sync.RWMutex
 }
 
-func NewProvider(ctx context.Context, computeClient *gophercloud.ServiceClient) (Provider, error) {
-	logger := log.FromContext(ctx)
+// extraSpecsConcurrency bounds the extra specs requests in flight while the catalog is refreshed.
+const extraSpecsConcurrency = 10
+
+// DefaultFlavorNamePattern parses the <family>.<size> flavor names of the OpenStack defaults, e.g.
+// m1.large.
+var DefaultFlavorNamePattern = regexp.MustCompile(`^(?P<family>[^.]+)\.(?P<size>[^.]+)$`)
+
+func NewProvider(ctx context.Context, computeClient *gophercloud.ServiceClient, pricingProvider pricing.Provider, unavailableOfferings *cache.UnavailableOfferings, flavorNamePattern *regexp.Regexp) (*DefaultProvider, error) {
+	p := &DefaultProvider{
+		computeClient:        computeClient,
+		PricingProvider:      pricingProvider,
+		UnavailableOfferings: unavailableOfferings,
+		FlavorNamePattern:    flavorNamePattern,
+	}
+	if _, err := p.UpdateInstanceTypes(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
-	flavorPages, err := flavors.ListDetail(computeClient, nil).AllPages()
+// UpdateInstanceTypes lists the flavors and availability zones from Nova and replaces the catalog,
+// reporting whether it changed. The previous catalog is kept when Nova can't be reached.
+func (p *DefaultProvider) UpdateInstanceTypes(ctx context.Context) (bool, error) {
+	flavorPages, err := flavors.ListDetail(p.computeClient, nil).AllPages()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list flavors: %w", err)
+		return false, fmt.Errorf("failed to list flavors: %w", err)
 	}
 	flavorsList, err := flavors.ExtractFlavors(flavorPages)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract flavors: %w", err)
+		return false, fmt.Errorf("failed to extract flavors: %w", err)
+	}
+	sort.Slice(flavorsList, func(i, j int) bool { return flavorsList[i].ID < flavorsList[j].ID })
+	extraSpecs, err := p.listExtraSpecs(ctx, flavorsList)
+	if err != nil {
+		return false, err
+	}
+	zones, err := p.listZones()
+	if err != nil {
+		return false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if reflect.DeepEqual(p.InstanceTypesInfo, flavorsList) && reflect.DeepEqual(p.ExtraSpecs, extraSpecs) && reflect.DeepEqual(p.Zones, zones) {
+		return false, nil
+	}
+	p.InstanceTypesInfo = flavorsList
+	p.ExtraSpecs = extraSpecs
+	p.Zones = zones
+	log.FromContext(ctx).Info(fmt.Sprintf("Discovered %d instance types (flavors)", len(flavorsList)), "zones", zones)
+	return true, nil
+}
+
+// listExtraSpecs reads the extra specs of the flavors, a few flavors at a time. A flavor whose extra
+// specs can't be read keeps the ones of the previous catalog, the refresh only fails for the flavors
+// that weren't known yet.
+func (p *DefaultProvider) listExtraSpecs(ctx context.Context, flavorList []flavors.Flavor) (map[string]map[string]string, error) {
+	p.mu.RLock()
+	previous := p.ExtraSpecs
+	p.mu.RUnlock()
+
+	var mu sync.Mutex
+	extraSpecs := make(map[string]map[string]string, len(flavorList))
+	group := errgroup.Group{}
+	group.SetLimit(extraSpecsConcurrency)
+	for _, flavor := range flavorList {
+		group.Go(func() error {
+			specs, err := flavors.ListExtraSpecs(p.computeClient, flavor.ID).Extract()
+			if err != nil {
+				known, ok := previous[flavor.ID]
+				if !ok {
+					return fmt.Errorf("failed to list extra specs of flavor %s: %w", flavor.Name, err)
+				}
+				log.FromContext(ctx).Error(err, "failed to list extra specs, keeping the last known ones", "flavor", flavor.Name)
+				specs = known
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			extraSpecs[flavor.ID] = specs
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return extraSpecs, nil
+}
+
+// UpdateQuota reads the quota left to the project, and to the preemptible project when there is
+// one, from Nova. The previous quota is kept when Nova can't be reached.
+func (p *DefaultProvider) UpdateQuota(ctx context.Context) error {
+	remaining, err := quota.Get(p.computeClient)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	if p.Quota == nil || *p.Quota != remaining {
+		log.FromContext(ctx).V(1).Info("updated remaining quota", "cores", remaining.Cores, "ramMiB", remaining.RAMMiB, "instances", remaining.Instances)
+	}
+	p.Quota = &remaining
+	p.mu.Unlock()
+
+	if p.PreemptibleComputeClient == nil {
+		return nil
+	}
+	preemptibleRemaining, err := quota.Get(p.PreemptibleComputeClient)
+	if err != nil {
+		return fmt.Errorf("preemptible project: %w", err)
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.PreemptibleQuota == nil || *p.PreemptibleQuota != preemptibleRemaining {
+		log.FromContext(ctx).V(1).Info("updated remaining quota of the preemptible project", "cores", preemptibleRemaining.Cores, "ramMiB", preemptibleRemaining.RAMMiB, "instances", preemptibleRemaining.Instances)
+	}
+	p.PreemptibleQuota = &preemptibleRemaining
+	return nil
+}
+
+// UpdateCapacity reads the free capacity of the compute nodes from Placement. Offerings are not
+// restricted by capacity while it can't be read, rather than by a stale estimate.
+func (p *DefaultProvider) UpdateCapacity(ctx context.Context) error {
+	if p.PlacementClient == nil {
+		return nil
+	}
+	capacity, err := placement.Get(p.PlacementClient, p.computeClient)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Capacity = capacity
+	return err
+}
+
+// UpdateReservations reads the active Blazar reservations of the project and the servers running in
+// them. Reserved offerings are dropped while Blazar can't be read, rather than launching into leases
+// that may have ended.
+func (p *DefaultProvider) UpdateReservations(ctx context.Context) error {
+	if p.ReservationClient == nil {
+		return nil
+	}
+	reservations, err := reservation.Get(p.ReservationClient, p.computeClient, p.Flavors())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(reservations) != len(p.Reservations) {
+		log.FromContext(ctx).Info(fmt.Sprintf("Discovered %d Blazar reservations", len(reservations)))
+	}
+	if hidden := hiddenHostReservations(reservations); len(hidden) > 0 && len(hiddenHostReservations(p.Reservations)) != len(hidden) {
+		log.FromContext(ctx).Info("Blazar doesn't let the project list the reserved hosts, offering no capacity of host reservations", "reservations", hidden)
+	}
+	p.Reservations = reservations
+	return err
+}
+
+// hiddenHostReservations returns the IDs of the host reservations whose hosts Blazar didn't list.
+func hiddenHostReservations(reservations []reservation.Reservation) []string {
+	return lo.FilterMap(reservations, func(r reservation.Reservation, _ int) (string, bool) { return r.ID, r.HostsHidden })
+}
+
+func (p *DefaultProvider) reservations() []reservation.Reservation {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Reservations
+}
+
+func (p *DefaultProvider) capacity() *placement.Capacity {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Capacity
+}
+
+func (p *DefaultProvider) RemainingQuota() *quota.Remaining {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Quota
+}
+
+func (p *DefaultProvider) preemptibleQuota() *quota.Remaining {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.PreemptibleQuota
+}
+
+// listZones returns the names of the availability zones that can take new servers.
+func (p *DefaultProvider) listZones() ([]string, error) {
+	zonePages, err := availabilityzones.List(p.computeClient).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list availability zones: %w", err)
+	}
+	zoneList, err := availabilityzones.ExtractAvailabilityZones(zonePages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract availability zones: %w", err)
+	}
+	zones := lo.FilterMap(zoneList, func(zone availabilityzones.AvailabilityZone, _ int) (string, bool) {
+		return zone.ZoneName, zone.ZoneState.Available
+	})
+	sort.Strings(zones)
+	return zones, nil
+}
+
+// Flavors returns the current flavor catalog.
+func (p *DefaultProvider) Flavors() []flavors.Flavor {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.InstanceTypesInfo
+}
 
-	logger.Info(fmt.Sprintf("Discovered %d instance types (flavors)", len(flavorsList)))
+func (p *DefaultProvider) catalog() ([]flavors.Flavor, map[string]map[string]string, []string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.InstanceTypesInfo, p.ExtraSpecs, p.Zones
+}
 
-	return &DefaultProvider{
-		InstanceTypesInfo: flavorsList,
-	}, nil
+// createOfferings returns one offering of the flavor with the capacity type per availability zone.
+// Without availability zones a single offering is returned and Nova picks the zone.
+func (p *DefaultProvider) createOfferings(flavor flavors.Flavor, zones []string, capacityType string, available func(zone string) bool) cloudprovider.Offerings {
+	if len(zones) == 0 {
+		return cloudprovider.Offerings{p.createOffering(flavor, "", capacityType, available(""))}
+	}
+	return lo.Map(zones, func(zone string, _ int) *cloudprovider.Offering {
+		offering := p.createOffering(flavor, zone, capacityType, available(zone))
+		offering.Requirements.Add(scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone))
+		return offering
+	})
 }
 
-func (p *DefaultProvider) createOffering() cloudprovider.Offering {
-	return cloudprovider.Offering{
+func (p *DefaultProvider) createOffering(flavor flavors.Flavor, zone, capacityType string, available bool) *cloudprovider.Offering {
+	offering := &cloudprovider.Offering{
 		Requirements: scheduling.NewRequirements(
-			scheduling.NewRequirement(
-				karpv1.CapacityTypeLabelKey,
-				corev1.NodeSelectorOpIn,
-				string(karpv1.CapacityTypeOnDemand),
-			),
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, capacityType),
+			scheduling.NewRequirement(v1openstack.LabelReservationID, corev1.NodeSelectorOpDoesNotExist),
+			scheduling.NewRequirement(v1openstack.LabelReservationType, corev1.NodeSelectorOpDoesNotExist),
 		),
-		Available: true,
+		Available: available,
+	}
+	if p.PricingProvider != nil {
+		offering.Price = p.PricingProvider.Price(flavor, zone)
+	}
+	return offering
+}
+
+// createSpotOfferings returns the spot offerings of the flavor, priced at the discount of the
+// NodeClass off the on-demand price.
+func (p *DefaultProvider) createSpotOfferings(flavor flavors.Flavor, zones []string, preemptible *v1openstack.Preemptible, available func(zone string) bool) cloudprovider.Offerings {
+	offerings := p.createOfferings(flavor, zones, karpv1.CapacityTypeSpot, available)
+	for _, offering := range offerings {
+		offering.Price *= 1 - float64(preemptible.PriceDiscount)/100
+	}
+	return offerings
+}
+
+// createReservedOfferings returns the reserved offerings of the flavor for every reservation that
+// can take it: in the zone of the reserved hosts when they share one, or else in every zone, Karpenter
+// sharing the capacity of a reservation among its offerings. Reserved offerings are free, the lease
+// being paid for whether it is used or not, so that they are launched before on-demand ones.
+func (p *DefaultProvider) createReservedOfferings(flavor flavors.Flavor, name string, zones []string, reservations []reservation.Reservation, fitsQuota bool) cloudprovider.Offerings {
+	var offerings cloudprovider.Offerings
+	for _, r := range reservations {
+		if !r.Offers(flavor) {
+			continue
+		}
+		capacity := r.Capacity(flavor)
+		reservationZones := zones
+		if zone := r.Zone(); zone != "" {
+			reservationZones = []string{zone}
+		}
+		for _, zone := range lo.Ternary(len(reservationZones) > 0, reservationZones, []string{""}) {
+			offering := &cloudprovider.Offering{
+				Requirements: scheduling.NewRequirements(
+					scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeReserved),
+					scheduling.NewRequirement(v1openstack.LabelReservationID, corev1.NodeSelectorOpIn, r.ID),
+					scheduling.NewRequirement(v1openstack.LabelReservationType, corev1.NodeSelectorOpIn, r.Type()),
+				),
+				Available:           fitsQuota && capacity > 0 && (p.UnavailableOfferings == nil || !p.UnavailableOfferings.IsUnavailable(name, zone, karpv1.CapacityTypeReserved)),
+				ReservationCapacity: capacity,
+			}
+			if zone != "" {
+				offering.Requirements.Add(scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone))
+			}
+			offerings = append(offerings, offering)
+		}
 	}
+	return offerings
+}
+
+// offeringAvailability returns whether the offering of the flavor, named name, with the capacity type
+// in a zone is available: the flavor fits in the quota left to the project it is launched in, which applies to
+// every zone, Placement estimates that one more server would fit in the zone, and Nova didn't
+// recently fail to place it there.
+func (p *DefaultProvider) offeringAvailability(flavor flavors.Flavor, name string, resources map[string]int, capacityType string, remaining *quota.Remaining, capacity *placement.Capacity) func(zone string) bool {
+	fitsQuota := remaining == nil || remaining.Fits(flavor)
+	return func(zone string) bool {
+		if !fitsQuota {
+			return false
+		}
+		if capacity != nil {
+			if instances, known := capacity.Instances(resources, zone); known && instances == 0 {
+				return false
+			}
+		}
+		return p.UnavailableOfferings == nil || !p.UnavailableOfferings.IsUnavailable(name, zone, capacityType)
+	}
+}
+
+func (p *DefaultProvider) SelectFlavors(_ context.Context, nodeClass *v1openstack.OpenStackNodeClass) ([]flavors.Flavor, error) {
+	flavorList, extraSpecs, _ := p.catalog()
+	return selectFlavors(nodeClass.Spec.FlavorSelectorTerms, flavorList, extraSpecs)
+}
+
+func (p *DefaultProvider) SelectPreemptibleFlavors(_ context.Context, nodeClass *v1openstack.OpenStackNodeClass) ([]flavors.Flavor, error) {
+	if nodeClass.Spec.Preemptible == nil || len(nodeClass.Spec.Preemptible.FlavorSelectorTerms) == 0 {
+		return nil, nil
+	}
+	flavorList, extraSpecs, _ := p.catalog()
+	return selectFlavors(nodeClass.Spec.Preemptible.FlavorSelectorTerms, flavorList, extraSpecs)
 }
 
 func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1openstack.OpenStackNodeClass) ([]*cloudprovider.InstanceType, error) {
 	logger := log.FromContext(ctx)
 	instanceTypes := []*cloudprovider.InstanceType{}
 
-	for _, flavor := range p.InstanceTypesInfo {
+	flavorList, extraSpecs, zones := p.catalog()
+	remaining := p.RemainingQuota()
+	placementCapacity := p.capacity()
+	reservations := p.reservations()
+	bootFromVolume := lo.ContainsBy(nodeClass.Spec.Disks, func(disk v1openstack.Disk) bool { return disk.Boot })
+	flavorLabels := map[string]map[string]string{}
+	catalogKeys := sets.New[string]()
+	sharedNames := sharedFlavorNames(flavorList)
+	for _, flavor := range flavorList {
+		flavorLabels[flavor.ID] = labelsFromExtraSpecs(extraSpecs[flavor.ID])
+		catalogKeys.Insert(lo.Keys(flavorLabels[flavor.ID])...)
+	}
 
-		maxPods := int64(110)
-		if nodeClass.Spec.KubeletConfiguration != nil && nodeClass.Spec.KubeletConfiguration.MaxPods != nil {
-			maxPods = int64(*nodeClass.Spec.KubeletConfiguration.MaxPods)
+	selected, err := selectFlavors(nodeClass.Spec.FlavorSelectorTerms, flavorList, extraSpecs)
+	if err != nil {
+		return nil, err
+	}
+	preemptible := nodeClass.Spec.Preemptible
+	preemptibleFlavors := sets.New[string]()
+	projectSpot := false
+	if preemptible != nil {
+		if len(preemptible.FlavorSelectorTerms) > 0 {
+			spotFlavors, err := selectFlavors(preemptible.FlavorSelectorTerms, flavorList, extraSpecs)
+			if err != nil {
+				return nil, err
+			}
+			preemptibleFlavors.Insert(lo.Map(spotFlavors, func(flavor flavors.Flavor, _ int) string { return flavor.ID })...)
 		}
+		projectSpot = preemptible.Project && p.PreemptibleComputeClient != nil
+		if preemptible.Project && !projectSpot {
+			logger.V(1).Info("no preemptible project is configured, offering no spot capacity", "nodeClass", nodeClass.Name)
+		}
+	}
 
+	for _, flavor := range selected {
+		name := lo.Ternary(sharedNames.Has(flavor.Name), flavor.ID, flavor.Name)
+		if errs := append(validation.IsValidLabelValue(name), validation.IsValidLabelValue(flavor.ID)...); len(errs) > 0 {
+			logger.V(1).Info("skipping flavor, its name or ID is not a valid label value", "flavor", flavor.Name, "errors", errs)
+			continue
+		}
+		images := compatibleImages(nodeClass, extraSpecs[flavor.ID])
+		if len(images) == 0 {
+			logger.V(1).Info("skipping flavor, no resolved image matches its architecture and OS", "flavor", flavor.Name)
+			continue
+		}
 		capacity := corev1.ResourceList{
 			corev1.ResourceCPU:    *resource.NewQuantity(int64(flavor.VCPUs), resource.DecimalSI),
 			corev1.ResourceMemory: *resource.NewQuantity(int64(flavor.RAM)*1024*1024, resource.BinarySI),
-			corev1.ResourcePods:   *resource.NewQuantity(maxPods, resource.DecimalSI),
+			corev1.ResourcePods:   *resource.NewQuantity(pods(int64(flavor.VCPUs), nodeClass.Spec.KubeletConfiguration), resource.DecimalSI),
+		}
+		if storageGiB := ephemeralStorageGiB(flavor, nodeClass); storageGiB > 0 {
+			capacity[corev1.ResourceEphemeralStorage] = *resource.NewQuantity(storageGiB*(1<<30), resource.BinarySI)
+		}
+		gpus, gpuModels, gpuCount := flavorGPUs(extraSpecs[flavor.ID], lo.Ternary(p.GPUResources != nil, p.GPUResources, DefaultGPUResources))
+		for name, quantity := range gpus {
+			capacity[name] = quantity
 		}
 
-		systemReserved := corev1.ResourceList{
-			corev1.ResourceCPU:    resource.MustParse("100m"),
-			corev1.ResourceMemory: resource.MustParse("0.2Gi"),
+		resources := placement.FlavorResources(flavor, extraSpecs[flavor.ID], bootFromVolume)
+		var offerings cloudprovider.Offerings
+		if r, ok := lo.Find(reservations, func(r reservation.Reservation) bool { return r.ID == flavor.ID }); ok {
+			// Blazar creates a private flavor with the ID of an instance reservation, which only
+			// launches with the scheduler hint of the reservation.
+			offerings = p.createReservedOfferings(flavor, name, zones, []reservation.Reservation{r}, remaining == nil || remaining.Fits(flavor))
+		} else if preemptibleFlavors.Has(flavor.ID) {
+			// Preemptible flavors are launched in the project like the others, but can be reclaimed.
+			offerings = p.createSpotOfferings(flavor, zones, preemptible, p.offeringAvailability(flavor, name, resources, karpv1.CapacityTypeSpot, remaining, placementCapacity))
+		} else {
+			offerings = append(
+				p.createReservedOfferings(flavor, name, zones, reservations, remaining == nil || remaining.Fits(flavor)),
+				p.createOfferings(flavor, zones, karpv1.CapacityTypeOnDemand, p.offeringAvailability(flavor, name, resources, karpv1.CapacityTypeOnDemand, remaining, placementCapacity))...,
+			)
+			if projectSpot {
+				offerings = append(offerings, p.createSpotOfferings(flavor, zones, preemptible, p.offeringAvailability(flavor, name, resources, karpv1.CapacityTypeSpot, p.preemptibleQuota(), placementCapacity))...)
+			}
 		}
 
-		offering := p.createOffering()
+		requirements := scheduling.NewRequirements(
+			scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, lo.Uniq(lo.Map(images, func(image v1openstack.Image, _ int) string { return image.Architecture }))...),
+			scheduling.NewRequirement(corev1.LabelOSStable, corev1.NodeSelectorOpIn, lo.Uniq(lo.Map(images, func(image v1openstack.Image, _ int) string { return image.OS }))...),
+			offeringRequirement(offerings, karpv1.CapacityTypeLabelKey),
+			offeringRequirement(offerings, v1openstack.LabelReservationID),
+			offeringRequirement(offerings, v1openstack.LabelReservationType),
+		)
+		requirements.Add(p.flavorRequirements(flavor, name)...)
+		requirements.Add(gpuRequirements(gpuModels, gpuCount)...)
+		requirements.Add(extraSpecRequirements(flavorLabels[flavor.ID], catalogKeys)...)
+		if len(zones) > 0 {
+			requirements.Add(scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones...))
+		}
 
 		instanceType := &cloudprovider.InstanceType{
-			Name: flavor.ID,
-			Offerings: cloudprovider.Offerings{
-				&offering,
-			},
-			Capacity: capacity,
-
-			Overhead: &cloudprovider.InstanceTypeOverhead{
-				KubeReserved: systemReserved,
-			},
-
-			Requirements: scheduling.NewRequirements(
-
-				scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, "amd64"),
-				scheduling.NewRequirement(corev1.LabelOSStable, corev1.NodeSelectorOpIn, "linux"),
-				scheduling.NewRequirement(
-					karpv1.CapacityTypeLabelKey,
-					corev1.NodeSelectorOpIn,
-					string(karpv1.CapacityTypeOnDemand),
-				),
-			),
+			Name:      name,
+			Offerings: offerings,
+			Capacity:  capacity,
+			Overhead:  overhead(capacity, nodeClass.Spec.KubeletConfiguration),
+
+			Requirements: requirements,
 		}
 		instanceTypes = append(instanceTypes, instanceType)
 	}
@@ -107,3 +510,79 @@ func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1openstack.OpenS
 
 	return instanceTypes, nil
 }
+
+// offeringRequirement returns the requirement of the instance type on a label its offerings set: In
+// the values of the offerings defining it, or DoesNotExist when none does.
+func offeringRequirement(offerings cloudprovider.Offerings, key string) *scheduling.Requirement {
+	values := lo.Uniq(lo.FilterMap(offerings, func(offering *cloudprovider.Offering, _ int) (string, bool) {
+		requirement := offering.Requirements.Get(key)
+		return requirement.Any(), requirement.Operator() == corev1.NodeSelectorOpIn
+	}))
+	if len(values) == 0 {
+		return scheduling.NewRequirement(key, corev1.NodeSelectorOpDoesNotExist)
+	}
+	return scheduling.NewRequirement(key, corev1.NodeSelectorOpIn, values...)
+}
+
+// sharedFlavorNames returns the names of more than one flavor of the catalog. The instance types of
+// these flavors are named after their ID instead, the instance type name being the key of the
+// instance-type label and of the unavailable offerings.
+func sharedFlavorNames(flavorList []flavors.Flavor) sets.Set[string] {
+	counts := lo.CountValuesBy(flavorList, func(flavor flavors.Flavor) string { return flavor.Name })
+	return sets.New(lo.Keys(lo.PickBy(counts, func(_ string, count int) bool { return count > 1 }))...)
+}
+
+// flavorRequirements returns the requirements on the labels describing the flavor, whose instance
+// type is named name. The family and size labels only exist when the flavor name follows the naming
+// convention.
+func (p *DefaultProvider) flavorRequirements(flavor flavors.Flavor, name string) []*scheduling.Requirement {
+	labels := map[string]string{
+		corev1.LabelInstanceTypeStable:  name,
+		v1openstack.LabelFlavorID:       flavor.ID,
+		v1openstack.LabelInstanceCPU:    strconv.Itoa(flavor.VCPUs),
+		v1openstack.LabelInstanceMemory: strconv.Itoa(flavor.RAM),
+	}
+	pattern := lo.Ternary(p.FlavorNamePattern != nil, p.FlavorNamePattern, DefaultFlavorNamePattern)
+	if match := pattern.FindStringSubmatch(flavor.Name); match != nil {
+		for group, label := range map[string]string{"family": v1openstack.LabelInstanceFamily, "size": v1openstack.LabelInstanceSize} {
+			if i := pattern.SubexpIndex(group); i >= 0 && match[i] != "" {
+				labels[label] = match[i]
+			}
+		}
+	}
+	var requirements []*scheduling.Requirement
+	for _, key := range []string{corev1.LabelInstanceTypeStable, v1openstack.LabelFlavorID, v1openstack.LabelInstanceCPU, v1openstack.LabelInstanceMemory, v1openstack.LabelInstanceFamily, v1openstack.LabelInstanceSize} {
+		if value, ok := labels[key]; ok && len(validation.IsValidLabelValue(value)) == 0 {
+			requirements = append(requirements, scheduling.NewRequirement(key, corev1.NodeSelectorOpIn, value))
+		} else {
+			requirements = append(requirements, scheduling.NewRequirement(key, corev1.NodeSelectorOpDoesNotExist))
+		}
+	}
+	return requirements
+}
+
+// ephemeralStorageGiB returns the disk space of the node: the boot volume of the NodeClass, or else
+// the root and ephemeral disks of the flavor.
+func ephemeralStorageGiB(flavor flavors.Flavor, nodeClass *v1openstack.OpenStackNodeClass) int64 {
+	if bootDisk, ok := lo.Find(nodeClass.Spec.Disks, func(disk v1openstack.Disk) bool { return disk.Boot }); ok {
+		return int64(bootDisk.SizeGiB)
+	}
+	return int64(flavor.Disk + flavor.Ephemeral)
+}
+
+// compatibleImages returns the images resolved for the NodeClass that the flavor can boot, given the
+// architecture and OS its extra specs restrict it to. A NodeClass whose images have not been
+// resolved yet is assumed to boot amd64 Linux images.
+func compatibleImages(nodeClass *v1openstack.OpenStackNodeClass, extraSpecs map[string]string) []v1openstack.Image {
+	images := lo.Map(nodeClass.Status.Images, func(image v1openstack.Image, _ int) v1openstack.Image {
+		image.OS = lo.Ternary(image.OS == "", string(corev1.Linux), image.OS)
+		return image
+	})
+	if len(images) == 0 {
+		images = []v1openstack.Image{{Architecture: karpv1.ArchitectureAmd64, OS: string(corev1.Linux)}}
+	}
+	architectures, oses := flavorPlatforms(extraSpecs)
+	return lo.Filter(images, func(image v1openstack.Image, _ int) bool {
+		return (architectures.Len() == 0 || architectures.Has(image.Architecture)) && (oses.Len() == 0 || oses.Has(image.OS))
+	})
+}
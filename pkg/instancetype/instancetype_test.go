@@ -3,10 +3,24 @@ package instancetype
 import (
 	"context"
 	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/quota"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/reservation"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 )
 
 func TestListInstanceTypes(t *testing.T) {
@@ -48,3 +62,854 @@ func TestListInstanceTypes(t *testing.T) {
 		fmt.Printf("  Offerings: %d\n", len(it.Offerings))
 	}
 }
+
+func TestUpdateInstanceTypes(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if len(provider.Flavors()) != 1 {
+		t.Fatalf("expected 1 flavor, got %d", len(provider.Flavors()))
+	}
+
+	if changed, err := provider.UpdateInstanceTypes(ctx); err != nil || changed {
+		t.Errorf("expected an unchanged catalog, got changed=%t err=%v", changed, err)
+	}
+
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384})
+	if changed, err := provider.UpdateInstanceTypes(ctx); err != nil || !changed {
+		t.Errorf("expected a changed catalog, got changed=%t err=%v", changed, err)
+	}
+	if len(provider.Flavors()) != 2 {
+		t.Errorf("expected 2 flavors, got %d", len(provider.Flavors()))
+	}
+
+	nova.SetFlavorsUnavailable(true)
+	nova.RemoveFlavor("1")
+	if _, err := provider.UpdateInstanceTypes(ctx); err == nil {
+		t.Errorf("expected an error while Nova is unavailable")
+	}
+	instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(instanceTypes) != 2 {
+		t.Errorf("expected the last known catalog of 2 instance types to be served, got %d", len(instanceTypes))
+	}
+
+	nova.SetFlavorsUnavailable(false)
+	if changed, err := provider.UpdateInstanceTypes(ctx); err != nil || !changed {
+		t.Errorf("expected a changed catalog, got changed=%t err=%v", changed, err)
+	}
+	if flavors := provider.Flavors(); len(flavors) != 1 || flavors[0].ID != "2" {
+		t.Errorf("expected only flavor 2 to remain, got %+v", flavors)
+	}
+}
+
+func TestUpdateInstanceTypesExtraSpecsFailure(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096, ExtraSpecs: map[string]string{"hw:cpu_policy": "dedicated"}})
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	// A known flavor keeps its extra specs, the new flavor is still listed.
+	nova.ExtraSpecsFailures["1"] = true
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384})
+	if changed, err := provider.UpdateInstanceTypes(ctx); err != nil || !changed {
+		t.Fatalf("expected a changed catalog, got changed=%t err=%v", changed, err)
+	}
+	if len(provider.Flavors()) != 2 || provider.ExtraSpecs["1"]["hw:cpu_policy"] != "dedicated" {
+		t.Errorf("expected both flavors with the last known extra specs, got %+v %+v", provider.Flavors(), provider.ExtraSpecs)
+	}
+
+	// A new flavor whose extra specs can't be read fails the refresh.
+	nova.ExtraSpecsFailures["3"] = true
+	nova.AddFlavor(fake.Flavor{ID: "3", Name: "general.xlarge", VCPUs: 16, RAM: 32768})
+	if _, err := provider.UpdateInstanceTypes(ctx); err == nil {
+		t.Errorf("expected an error for the extra specs of the new flavor")
+	}
+	if len(provider.Flavors()) != 2 {
+		t.Errorf("expected the last known catalog to be kept, got %+v", provider.Flavors())
+	}
+}
+
+func TestListInstanceTypesOffersEveryZone(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	nova.AddAvailabilityZone("az-2", true)
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("maintenance", false)
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(instanceTypes) != 1 {
+		t.Fatalf("expected 1 instance type, got %d", len(instanceTypes))
+	}
+	instanceType := instanceTypes[0]
+	zones := lo.Map(instanceType.Offerings, func(offering *cloudprovider.Offering, _ int) string { return offering.Zone() })
+	if !reflect.DeepEqual(zones, []string{"az-1", "az-2"}) {
+		t.Errorf("expected one offering per available zone, got %v", zones)
+	}
+	if values := sortedValues(instanceType, corev1.LabelTopologyZone); !reflect.DeepEqual(values, []string{"az-1", "az-2"}) {
+		t.Errorf("expected the zone requirement to list every zone, got %v", values)
+	}
+}
+
+func TestListInstanceTypesPricesOfferings(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384})
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("az-2", true)
+	ctx := context.Background()
+
+	pricingProvider := pricing.NewProvider()
+	pricingProvider.UpdateConfig(pricing.Config{
+		Flavors:         map[string]float64{"general.large": 0.5},
+		VCPU:            0.05,
+		MemoryGiB:       0.01,
+		ZoneMultipliers: map[string]float64{"az-2": 2},
+	})
+	provider, err := NewProvider(ctx, nova.ServiceClient(), pricingProvider, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	prices := map[string][]float64{}
+	for _, instanceType := range instanceTypes {
+		prices[instanceType.Name] = lo.Map(instanceType.Offerings, func(offering *cloudprovider.Offering, _ int) float64 { return offering.Price })
+	}
+	expected := map[string][]float64{
+		"general.small": {0.14, 0.28},
+		"general.large": {0.5, 1},
+	}
+	for name, expectedPrices := range expected {
+		if len(prices[name]) != len(expectedPrices) {
+			t.Fatalf("expected %d offerings of %s, got %v", len(expectedPrices), name, prices[name])
+		}
+		for i := range expectedPrices {
+			if math.Abs(prices[name][i]-expectedPrices[i]) > 1e-9 {
+				t.Errorf("expected %s to be priced %v, got %v", name, expectedPrices, prices[name])
+			}
+		}
+	}
+}
+
+func TestListInstanceTypesQuota(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384})
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("az-2", true)
+	nova.AddServer(fake.Server{ID: "server-1", FlavorID: "2"})
+	nova.SetLimits(fake.Limits{MaxTotalCores: 12, MaxTotalRAMSize: -1, MaxTotalInstances: 10})
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	available := func() map[string][]bool {
+		instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, []bool) {
+			return it.Name, lo.Map(it.Offerings, func(offering *cloudprovider.Offering, _ int) bool { return offering.Available })
+		})
+	}
+
+	// Every offering is available until the quota has been read.
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {true, true}}, available(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if err := provider.UpdateQuota(ctx); err != nil {
+		t.Fatalf("UpdateQuota failed: %v", err)
+	}
+	if expected, actual := (&quota.Remaining{Cores: 4, RAMMiB: quota.Unlimited, Instances: 9}), provider.RemainingQuota(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v remaining, got %+v", expected, actual)
+	}
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {false, false}}, available(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestListInstanceTypesUnavailableOfferings(t *testing.T) {
+	unavailableOfferings := cache.NewUnavailableOfferings()
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096},
+			{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384},
+		},
+		Zones:                []string{"az-1", "az-2"},
+		UnavailableOfferings: unavailableOfferings,
+	}
+	unavailableOfferings.MarkUnavailable(context.Background(), "InsufficientCapacity", "general.large", "az-1", karpv1.CapacityTypeOnDemand)
+
+	instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	available := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, []string) {
+		return it.Name, lo.Map(it.Offerings.Available(), func(offering *cloudprovider.Offering, _ int) string { return offering.Zone() })
+	})
+	expected := map[string][]string{"general.small": {"az-1", "az-2"}, "general.large": {"az-2"}}
+	if !reflect.DeepEqual(available, expected) {
+		t.Errorf("expected available offerings %v, got %v", expected, available)
+	}
+
+	unavailableOfferings.Flush()
+	instanceTypes, err = provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for _, instanceType := range instanceTypes {
+		if len(instanceType.Offerings.Available()) != 2 {
+			t.Errorf("expected %s to be offered in both zones once the cache is flushed", instanceType.Name)
+		}
+	}
+}
+
+func TestListInstanceTypesPlacementCapacity(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096, Disk: 20})
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384, Disk: 80})
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("az-2", true)
+	nova.AddAggregate(fake.Aggregate{Name: "az-1", AvailabilityZone: "az-1", Hosts: []string{"compute-1"}})
+	nova.AddAggregate(fake.Aggregate{Name: "az-2", AvailabilityZone: "az-2", Hosts: []string{"compute-2"}})
+	placement := fake.NewPlacement()
+	defer placement.Close()
+	for _, host := range []string{"compute-1", "compute-2"} {
+		placement.AddResourceProvider(fake.ResourceProvider{UUID: host, Name: host, Inventories: map[string]fake.Inventory{
+			"VCPU":      {Total: 16},
+			"MEMORY_MB": {Total: 32768},
+			"DISK_GB":   {Total: 100},
+		}})
+	}
+	placement.SetUsage("compute-1", "VCPU", 12)
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	provider.PlacementClient = placement.ServiceClient()
+	available := func(nodeClass *v1openstack.OpenStackNodeClass) map[string][]bool {
+		instanceTypes, err := provider.List(ctx, nodeClass)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, []bool) {
+			return it.Name, lo.Map(it.Offerings, func(offering *cloudprovider.Offering, _ int) bool { return offering.Available })
+		})
+	}
+
+	// Every offering is available until the capacity has been read.
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {true, true}}, available(&v1openstack.OpenStackNodeClass{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if err := provider.UpdateCapacity(ctx); err != nil {
+		t.Fatalf("UpdateCapacity failed: %v", err)
+	}
+	// compute-1 has 4 vCPUs left, too few for general.large.
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {false, true}}, available(&v1openstack.OpenStackNodeClass{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	// compute-2 has 60 GB of disk left, too little for the root disk of general.large.
+	placement.SetUsage("compute-2", "DISK_GB", 40)
+	if err := provider.UpdateCapacity(ctx); err != nil {
+		t.Fatalf("UpdateCapacity failed: %v", err)
+	}
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {false, false}}, available(&v1openstack.OpenStackNodeClass{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	// Servers booting from a volume don't request the root disk from the compute node.
+	bootFromVolume := &v1openstack.OpenStackNodeClass{Spec: v1openstack.OpenStackNodeClassSpec{Disks: []v1openstack.Disk{{Boot: true, SizeGiB: 50}}}}
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {false, true}}, available(bootFromVolume); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	// Offerings are not restricted by a stale estimate while Placement can't be read.
+	placement.SetUnavailable(true)
+	if err := provider.UpdateCapacity(ctx); err == nil {
+		t.Errorf("expected UpdateCapacity to fail while Placement is unavailable")
+	}
+	if expected, actual := map[string][]bool{"general.small": {true, true}, "general.large": {true, true}}, available(&v1openstack.OpenStackNodeClass{}); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestListInstanceTypesReservations(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096, Disk: 20},
+			{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384, Disk: 80},
+		},
+		Zones: []string{"az-1", "az-2"},
+		Reservations: []reservation.Reservation{
+			{
+				ID:           "host-reservation",
+				ResourceType: reservation.HostReservation,
+				Hosts:        []reservation.Host{{Name: "compute-1", Zone: "az-1", Resources: reservation.Resources{VCPUs: 8, MemoryMB: 32768, DiskGB: 500}}},
+			},
+			{
+				ID:           "instance-reservation",
+				ResourceType: reservation.InstanceReservation,
+				Amount:       2,
+				Resources:    reservation.Resources{VCPUs: 2, MemoryMB: 4096, DiskGB: 20},
+				Instances:    2,
+			},
+		},
+		UnavailableOfferings: cache.NewUnavailableOfferings(),
+	}
+
+	instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	type offering struct {
+		CapacityType  string
+		Zone          string
+		ReservationID string
+		Capacity      int
+		Available     bool
+	}
+	offerings := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, []offering) {
+		return it.Name, lo.Map(it.Offerings, func(o *cloudprovider.Offering, _ int) offering {
+			return offering{o.CapacityType(), o.Zone(), o.Requirements.Get(v1openstack.LabelReservationID).Any(), o.ReservationCapacity, o.Available}
+		})
+	})
+	expected := map[string][]offering{
+		"general.small": {
+			{karpv1.CapacityTypeReserved, "az-1", "host-reservation", 4, true},
+			// The zone of the instance reservation is unknown, and it is used up.
+			{karpv1.CapacityTypeReserved, "az-1", "instance-reservation", 0, false},
+			{karpv1.CapacityTypeReserved, "az-2", "instance-reservation", 0, false},
+			{karpv1.CapacityTypeOnDemand, "az-1", "", 0, true},
+			{karpv1.CapacityTypeOnDemand, "az-2", "", 0, true},
+		},
+		"general.large": {
+			{karpv1.CapacityTypeReserved, "az-1", "host-reservation", 1, true},
+			{karpv1.CapacityTypeOnDemand, "az-1", "", 0, true},
+			{karpv1.CapacityTypeOnDemand, "az-2", "", 0, true},
+		},
+	}
+	if !reflect.DeepEqual(offerings, expected) {
+		t.Errorf("expected offerings %+v, got %+v", expected, offerings)
+	}
+	for _, instanceType := range instanceTypes {
+		if !instanceType.Requirements.Get(karpv1.CapacityTypeLabelKey).Has(karpv1.CapacityTypeReserved) {
+			t.Errorf("expected %s to offer reserved capacity", instanceType.Name)
+		}
+		for _, o := range instanceType.Offerings {
+			if o.Price != 0 && o.CapacityType() == karpv1.CapacityTypeReserved {
+				t.Errorf("expected reserved offerings of %s to be free, got %v", instanceType.Name, o.Price)
+			}
+		}
+	}
+	ids := instanceTypes[0].Requirements.Get(v1openstack.LabelReservationID).Values()
+	sort.Strings(ids)
+	if !reflect.DeepEqual(ids, []string{"host-reservation", "instance-reservation"}) {
+		t.Errorf("expected %s to require one of its reservations, got %v", instanceTypes[0].Name, ids)
+	}
+
+	// A failed reserved launch leaves the offering out, on-demand capacity remains.
+	provider.UnavailableOfferings.MarkUnavailable(context.Background(), "InsufficientCapacity", "general.large", "az-1", karpv1.CapacityTypeReserved)
+	instanceTypes, err = provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	large, _ := lo.Find(instanceTypes, func(it *cloudprovider.InstanceType) bool { return it.Name == "general.large" })
+	available := lo.Map(large.Offerings.Available(), func(o *cloudprovider.Offering, _ int) string { return o.CapacityType() + "/" + o.Zone() })
+	if expected := []string{"on-demand/az-1", "on-demand/az-2"}; !reflect.DeepEqual(available, expected) {
+		t.Errorf("expected available offerings %v, got %v", expected, available)
+	}
+}
+
+func TestListInstanceTypesReservationFlavor(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096, Disk: 20},
+			// The private flavor Blazar created for the instance reservation.
+			{ID: "instance-reservation", Name: "reserved.small", VCPUs: 2, RAM: 4096, Disk: 20},
+		},
+		Zones: []string{"az-1", "az-2"},
+		Reservations: []reservation.Reservation{
+			{
+				ID:           "host-reservation",
+				ResourceType: reservation.HostReservation,
+				Hosts:        []reservation.Host{{Name: "compute-1", Zone: "az-1", Resources: reservation.Resources{VCPUs: 8, MemoryMB: 32768, DiskGB: 500}}},
+			},
+			{
+				ID:           "instance-reservation",
+				ResourceType: reservation.InstanceReservation,
+				Amount:       2,
+				Resources:    reservation.Resources{VCPUs: 2, MemoryMB: 4096, DiskGB: 20},
+			},
+		},
+	}
+
+	instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	reserved, ok := lo.Find(instanceTypes, func(it *cloudprovider.InstanceType) bool { return it.Name == "reserved.small" })
+	if !ok {
+		t.Fatalf("expected the reservation flavor to be offered, got %v", lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name }))
+	}
+	offerings := lo.Map(reserved.Offerings, func(o *cloudprovider.Offering, _ int) string {
+		return o.CapacityType() + "/" + o.Requirements.Get(v1openstack.LabelReservationID).Any() + "/" + o.Zone()
+	})
+	if expected := []string{"reserved/instance-reservation/az-1", "reserved/instance-reservation/az-2"}; !reflect.DeepEqual(offerings, expected) {
+		t.Errorf("expected the reservation flavor to only be offered in its reservation, got %v", offerings)
+	}
+	if capacityTypes := reserved.Requirements.Get(karpv1.CapacityTypeLabelKey).Values(); !reflect.DeepEqual(capacityTypes, []string{karpv1.CapacityTypeReserved}) {
+		t.Errorf("expected the reservation flavor to require reserved capacity, got %v", capacityTypes)
+	}
+}
+
+func TestListInstanceTypesPreemptible(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "general.large", VCPUs: 8, RAM: 16384})
+	nova.AddFlavor(fake.Flavor{ID: "3", Name: "preemptible.small", VCPUs: 2, RAM: 4096})
+	nova.AddAvailabilityZone("az-1", true)
+	nova.AddAvailabilityZone("az-2", true)
+	preemptibleNova := fake.NewNova()
+	defer preemptibleNova.Close()
+	preemptibleNova.SetLimits(fake.Limits{MaxTotalCores: 4, MaxTotalRAMSize: -1, MaxTotalInstances: -1})
+	ctx := context.Background()
+
+	pricingProvider := pricing.NewProvider()
+	pricingProvider.UpdateConfig(pricing.Config{Flavors: map[string]float64{"general.small": 0.1, "general.large": 0.4, "preemptible.small": 0.1}})
+	provider, err := NewProvider(ctx, nova.ServiceClient(), pricingProvider, cache.NewUnavailableOfferings(), nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	type offering struct {
+		CapacityType string
+		Zone         string
+		Price        float64
+		Available    bool
+	}
+	list := func(preemptible *v1openstack.Preemptible) map[string][]offering {
+		instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{Spec: v1openstack.OpenStackNodeClassSpec{Preemptible: preemptible}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, []offering) {
+			return it.Name, lo.Map(it.Offerings, func(o *cloudprovider.Offering, _ int) offering {
+				return offering{o.CapacityType(), o.Zone(), math.Round(o.Price*1000) / 1000, o.Available}
+			})
+		})
+	}
+
+	// Preemptible flavors of the project are only offered as spot capacity.
+	expected := map[string][]offering{
+		"general.small":     {{karpv1.CapacityTypeOnDemand, "az-1", 0.1, true}, {karpv1.CapacityTypeOnDemand, "az-2", 0.1, true}},
+		"general.large":     {{karpv1.CapacityTypeOnDemand, "az-1", 0.4, true}, {karpv1.CapacityTypeOnDemand, "az-2", 0.4, true}},
+		"preemptible.small": {{karpv1.CapacityTypeSpot, "az-1", 0.04, true}, {karpv1.CapacityTypeSpot, "az-2", 0.04, true}},
+	}
+	preemptibleFlavors := &v1openstack.Preemptible{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "preemptible.*"}}, PriceDiscount: 60}
+	if actual := list(preemptibleFlavors); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected offerings %+v, got %+v", expected, actual)
+	}
+
+	// Without a preemptible project configured, no spot capacity is offered.
+	preemptibleProject := &v1openstack.Preemptible{Project: true, PriceDiscount: 50}
+	for name, offerings := range list(preemptibleProject) {
+		if lo.ContainsBy(offerings, func(o offering) bool { return o.CapacityType == karpv1.CapacityTypeSpot }) {
+			t.Errorf("expected no spot offering of %s, got %+v", name, offerings)
+		}
+	}
+
+	// Every flavor is offered as spot capacity in the preemptible project as well, within its quota.
+	provider.PreemptibleComputeClient = preemptibleNova.ServiceClient()
+	if err := provider.UpdateQuota(ctx); err != nil {
+		t.Fatalf("UpdateQuota failed: %v", err)
+	}
+	provider.UnavailableOfferings.MarkUnavailable(ctx, "SpotInterruption", "general.small", "az-2", karpv1.CapacityTypeSpot)
+	expected = map[string][]offering{
+		"general.small": {
+			{karpv1.CapacityTypeOnDemand, "az-1", 0.1, true}, {karpv1.CapacityTypeOnDemand, "az-2", 0.1, true},
+			{karpv1.CapacityTypeSpot, "az-1", 0.05, true}, {karpv1.CapacityTypeSpot, "az-2", 0.05, false},
+		},
+		"general.large": {
+			{karpv1.CapacityTypeOnDemand, "az-1", 0.4, true}, {karpv1.CapacityTypeOnDemand, "az-2", 0.4, true},
+			{karpv1.CapacityTypeSpot, "az-1", 0.2, false}, {karpv1.CapacityTypeSpot, "az-2", 0.2, false},
+		},
+		"preemptible.small": {
+			{karpv1.CapacityTypeOnDemand, "az-1", 0.1, true}, {karpv1.CapacityTypeOnDemand, "az-2", 0.1, true},
+			{karpv1.CapacityTypeSpot, "az-1", 0.05, true}, {karpv1.CapacityTypeSpot, "az-2", 0.05, true},
+		},
+	}
+	if actual := list(preemptibleProject); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected offerings %+v, got %+v", expected, actual)
+	}
+}
+
+func TestListInstanceTypesGPUs(t *testing.T) {
+	gpuResources, err := ParseGPUResources("a100=nvidia.com/gpu:a100, mi210=amd.com/gpu:mi210, VGPU=nvidia.com/gpu:grid-t4-4q")
+	if err != nil {
+		t.Fatalf("ParseGPUResources failed: %v", err)
+	}
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192},
+			{ID: "2", Name: "g1.a100x2", VCPUs: 16, RAM: 65536},
+			{ID: "3", Name: "g1.mixed", VCPUs: 16, RAM: 65536},
+			{ID: "4", Name: "v1.t4", VCPUs: 4, RAM: 16384},
+			{ID: "5", Name: "g1.unknown", VCPUs: 8, RAM: 16384},
+		},
+		ExtraSpecs: map[string]map[string]string{
+			"2": {"pci_passthrough:alias": "a100:2"},
+			"3": {"pci_passthrough:alias": "a100:1,mi210:1"},
+			"4": {"resources:VGPU": "1"},
+			"5": {"pci_passthrough:alias": "h100:1"},
+		},
+		GPUResources: gpuResources,
+	}
+
+	instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	type gpus struct {
+		NVIDIA string
+		AMD    string
+		Name   string
+		Count  string
+	}
+	actual := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, gpus) {
+		nvidia, amd := it.Capacity["nvidia.com/gpu"], it.Capacity["amd.com/gpu"]
+		requirement := func(key string) string { return strings.TrimPrefix(it.Requirements.Get(key).String(), key+" ") }
+		return it.Name, gpus{nvidia.String(), amd.String(), requirement(v1openstack.LabelGPUName), requirement(v1openstack.LabelGPUCount)}
+	})
+	expected := map[string]gpus{
+		"m1.large":  {"0", "0", "DoesNotExist", "DoesNotExist"},
+		"g1.a100x2": {"2", "0", "In [a100]", "In [2]"},
+		"g1.mixed":  {"1", "1", "In [a100 mi210]", "In [2]"},
+		"v1.t4":     {"1", "0", "In [grid-t4-4q]", "In [1]"},
+		// Aliases without a configured resource are left out.
+		"g1.unknown": {"0", "0", "DoesNotExist", "DoesNotExist"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected GPUs %+v, got %+v", expected, actual)
+	}
+}
+
+func TestParseGPUResources(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected map[string]GPUResource
+		invalid  bool
+	}{
+		{value: "", expected: map[string]GPUResource{}},
+		{value: "a100=nvidia.com/gpu", expected: map[string]GPUResource{"a100": {Name: "nvidia.com/gpu"}}},
+		{value: "a100=nvidia.com/gpu:a100,VGPU=nvidia.com/gpu", expected: map[string]GPUResource{"a100": {Name: "nvidia.com/gpu", Model: "a100"}, VGPUAlias: {Name: "nvidia.com/gpu"}}},
+		{value: "a100", invalid: true},
+		{value: "a100=gpu", invalid: true},
+		{value: "a100=nvidia.com/gpu:NVIDIA A100", invalid: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := ParseGPUResources(tc.value)
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGPUResources failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestListInstanceTypesExtraSpecRequirements(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "general.small", VCPUs: 2, RAM: 4096})
+	nova.AddFlavor(fake.Flavor{ID: "2", Name: "pinned.large", VCPUs: 8, RAM: 16384, ExtraSpecs: map[string]string{
+		"hw:cpu_policy":                       "dedicated",
+		"hw:mem_page_size":                    "1GB",
+		"hw:cpu_sockets":                      "2",
+		"trait:HW_CPU_X86_AVX2":               "required",
+		"aggregate_instance_extra_specs:ssd":  "true",
+		"resources:CUSTOM_BAREMETAL_GOLD":     "1",
+		"resources:VCPU":                      "0",
+		"quota:disk_read_bytes_sec":           "10240000",
+		"aggregate_instance_extra_specs:zone": "not a valid label value",
+	}})
+	ctx := context.Background()
+
+	provider, err := NewProvider(ctx, nova.ServiceClient(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	instanceTypes, err := provider.List(ctx, &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	byName := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, *cloudprovider.InstanceType) { return it.Name, it })
+
+	expected := map[string]map[string]string{
+		"general.small": {
+			v1openstack.LabelCPUPolicy:                                "In [shared]",
+			v1openstack.LabelMemPageSize:                              "DoesNotExist",
+			v1openstack.LabelCPUSockets:                               "DoesNotExist",
+			v1openstack.LabelTraitPrefix + "HW_CPU_X86_AVX2":          "DoesNotExist",
+			v1openstack.LabelAggregatePrefix + "ssd":                  "DoesNotExist",
+			v1openstack.LabelResourcePrefix + "CUSTOM_BAREMETAL_GOLD": "DoesNotExist",
+		},
+		"pinned.large": {
+			v1openstack.LabelCPUPolicy:                                "In [dedicated]",
+			v1openstack.LabelMemPageSize:                              "In [1GB]",
+			v1openstack.LabelCPUSockets:                               "In [2]",
+			v1openstack.LabelTraitPrefix + "HW_CPU_X86_AVX2":          "In [required]",
+			v1openstack.LabelAggregatePrefix + "ssd":                  "In [true]",
+			v1openstack.LabelResourcePrefix + "CUSTOM_BAREMETAL_GOLD": "In [1]",
+		},
+	}
+	for name, requirements := range expected {
+		instanceType, ok := byName[name]
+		if !ok {
+			t.Fatalf("instance type %s not found", name)
+		}
+		for key, value := range requirements {
+			if actual := instanceType.Requirements.Get(key).String(); actual != key+" "+value {
+				t.Errorf("%s: expected %s %s, got %s", name, key, value, actual)
+			}
+		}
+		if instanceType.Requirements.Has(v1openstack.LabelAggregatePrefix + "zone") {
+			t.Errorf("%s: expected the invalid label value to be ignored", name)
+		}
+	}
+}
+
+func TestListInstanceTypesPlatforms(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192},
+			{ID: "2", Name: "a1.large", VCPUs: 4, RAM: 8192},
+			{ID: "3", Name: "w1.large", VCPUs: 4, RAM: 8192},
+			{ID: "4", Name: "p1.large", VCPUs: 4, RAM: 8192},
+		},
+		ExtraSpecs: map[string]map[string]string{
+			"2": {"hw:cpu_arch": "aarch64"},
+			"3": {"aggregate_instance_extra_specs:os_type": "windows", "capabilities:cpu_arch": "<in> x86_64 i686"},
+			"4": {"hw:cpu_arch": "ppc64le"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		images   []v1openstack.Image
+		expected map[string][2][]string
+	}{
+		{
+			name: "images not resolved",
+			expected: map[string][2][]string{
+				"m1.large": {{"amd64"}, {"linux"}},
+			},
+		},
+		{
+			name: "amd64 and arm64 images",
+			images: []v1openstack.Image{
+				{ID: "ubuntu", Architecture: "amd64", OS: "linux"},
+				{ID: "ubuntu-arm", Architecture: "arm64"},
+			},
+			expected: map[string][2][]string{
+				"m1.large": {{"amd64", "arm64"}, {"linux"}},
+				"a1.large": {{"arm64"}, {"linux"}},
+			},
+		},
+		{
+			name:   "windows image",
+			images: []v1openstack.Image{{ID: "windows", Architecture: "amd64", OS: "windows"}},
+			expected: map[string][2][]string{
+				"m1.large": {{"amd64"}, {"windows"}},
+				"w1.large": {{"amd64"}, {"windows"}},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1openstack.OpenStackNodeClass{Status: v1openstack.OpenStackNodeClassStatus{Images: tc.images}}
+			instanceTypes, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(instanceTypes) != len(tc.expected) {
+				t.Errorf("expected %d instance types, got %v", len(tc.expected), lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name }))
+			}
+			for _, instanceType := range instanceTypes {
+				expected, ok := tc.expected[instanceType.Name]
+				if !ok {
+					t.Errorf("unexpected instance type %s", instanceType.Name)
+					continue
+				}
+				arch := sortedValues(instanceType, corev1.LabelArchStable)
+				os := sortedValues(instanceType, corev1.LabelOSStable)
+				if !reflect.DeepEqual(arch, expected[0]) || !reflect.DeepEqual(os, expected[1]) {
+					t.Errorf("expected %s to run %v/%v, got %v/%v", instanceType.Name, expected[0], expected[1], arch, os)
+				}
+			}
+		})
+	}
+}
+
+func TestListInstanceTypesFlavorLabels(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "6a8c3b2e-1f4d-4c5a-9b7e-2d3f4a5b6c7d", Name: "m1.large", VCPUs: 4, RAM: 8192},
+			{ID: "2", Name: "gpu-a100-xlarge", VCPUs: 16, RAM: 65536},
+			{ID: "3", Name: "Large flavor (legacy)", VCPUs: 8, RAM: 16384},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		pattern  *regexp.Regexp
+		expected map[string]map[string]string
+	}{
+		{
+			name: "default naming convention",
+			expected: map[string]map[string]string{
+				"m1.large": {
+					corev1.LabelInstanceTypeStable:  "In [m1.large]",
+					v1openstack.LabelFlavorID:       "In [6a8c3b2e-1f4d-4c5a-9b7e-2d3f4a5b6c7d]",
+					v1openstack.LabelInstanceCPU:    "In [4]",
+					v1openstack.LabelInstanceMemory: "In [8192]",
+					v1openstack.LabelInstanceFamily: "In [m1]",
+					v1openstack.LabelInstanceSize:   "In [large]",
+				},
+				"gpu-a100-xlarge": {
+					corev1.LabelInstanceTypeStable:  "In [gpu-a100-xlarge]",
+					v1openstack.LabelFlavorID:       "In [2]",
+					v1openstack.LabelInstanceFamily: "DoesNotExist",
+					v1openstack.LabelInstanceSize:   "DoesNotExist",
+				},
+			},
+		},
+		{
+			name:    "custom naming convention",
+			pattern: regexp.MustCompile(`^(?P<family>.+)-(?P<size>[^-]+)$`),
+			expected: map[string]map[string]string{
+				"m1.large": {
+					v1openstack.LabelInstanceFamily: "DoesNotExist",
+					v1openstack.LabelInstanceSize:   "DoesNotExist",
+				},
+				"gpu-a100-xlarge": {
+					v1openstack.LabelInstanceFamily: "In [gpu-a100]",
+					v1openstack.LabelInstanceSize:   "In [xlarge]",
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			provider.FlavorNamePattern = tc.pattern
+			instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			// The name of the third flavor is not a valid label value.
+			if len(instanceTypes) != 2 {
+				t.Errorf("expected 2 instance types, got %v", lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name }))
+			}
+			byName := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, *cloudprovider.InstanceType) { return it.Name, it })
+			for name, requirements := range tc.expected {
+				instanceType, ok := byName[name]
+				if !ok {
+					t.Fatalf("instance type %s not found", name)
+				}
+				for key, value := range requirements {
+					if actual := instanceType.Requirements.Get(key).String(); actual != key+" "+value {
+						t.Errorf("%s: expected %s %s, got %s", name, key, value, actual)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestListInstanceTypesSharedFlavorNames(t *testing.T) {
+	unavailableOfferings := cache.NewUnavailableOfferings()
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192},
+			{ID: "2", Name: "m1.large", VCPUs: 8, RAM: 16384},
+			{ID: "3", Name: "m1.small", VCPUs: 2, RAM: 4096},
+		},
+		Zones:                []string{"az-1"},
+		UnavailableOfferings: unavailableOfferings,
+	}
+	unavailableOfferings.MarkUnavailable(context.Background(), "InsufficientCapacity", "2", "az-1", karpv1.CapacityTypeOnDemand)
+
+	instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	byName := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (string, *cloudprovider.InstanceType) { return it.Name, it })
+	if len(byName) != 3 {
+		t.Fatalf("expected 3 distinct instance types, got %v", lo.Keys(byName))
+	}
+	for name, flavorID := range map[string]string{"1": "1", "2": "2", "m1.small": "3"} {
+		instanceType, ok := byName[name]
+		if !ok {
+			t.Fatalf("instance type %s not found", name)
+		}
+		if actual := instanceType.Requirements.Get(corev1.LabelInstanceTypeStable).Any(); actual != name {
+			t.Errorf("%s: expected instance type label %s, got %s", name, name, actual)
+		}
+		if actual := instanceType.Requirements.Get(v1openstack.LabelFlavorID).Any(); actual != flavorID {
+			t.Errorf("%s: expected flavor ID %s, got %s", name, flavorID, actual)
+		}
+	}
+	// Only the flavor marked unavailable loses its offering, not the other flavor sharing its name.
+	if len(byName["1"].Offerings.Available()) != 1 || len(byName["2"].Offerings.Available()) != 0 {
+		t.Errorf("expected only the offering of flavor 2 to be unavailable")
+	}
+	if actual := byName["1"].Requirements.Get(v1openstack.LabelInstanceFamily).Any(); actual != "m1" {
+		t.Errorf("expected the family to still be read from the flavor name, got %s", actual)
+	}
+}
+
+// sortedValues returns the values of the requirement of the instance type, which are unordered.
+func sortedValues(instanceType *cloudprovider.InstanceType, key string) []string {
+	values := instanceType.Requirements.Get(key).Values()
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,144 @@
+package instancetype
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+const (
+	// defaultMaxPods is the kubelet default of --max-pods.
+	defaultMaxPods = 110
+	// memoryAvailable and nodefsAvailable are the eviction signals that reduce allocatable.
+	memoryAvailable = "memory.available"
+	nodefsAvailable = "nodefs.available"
+)
+
+// defaultEvictionHard mirrors the kubelet defaults of --eviction-hard that reduce allocatable.
+var defaultEvictionHard = map[string]string{
+	memoryAvailable: "100Mi",
+	nodefsAvailable: "10%",
+}
+
+// pods returns the pods capacity, min(maxPods, podsPerCore × vCPU).
+func pods(vcpus int64, kc *v1openstack.KubeletConfiguration) int64 {
+	maxPods := int64(defaultMaxPods)
+	if kc == nil {
+		return maxPods
+	}
+	if kc.MaxPods != nil {
+		maxPods = int64(*kc.MaxPods)
+	}
+	if kc.PodsPerCore != nil && *kc.PodsPerCore > 0 {
+		maxPods = lo.Min([]int64{maxPods, int64(*kc.PodsPerCore) * vcpus})
+	}
+	return maxPods
+}
+
+// overhead returns the resources the kubelet withholds from pods on a node of the given capacity.
+func overhead(capacity corev1.ResourceList, kc *v1openstack.KubeletConfiguration) *cloudprovider.InstanceTypeOverhead {
+	if kc == nil {
+		kc = &v1openstack.KubeletConfiguration{}
+	}
+	return &cloudprovider.InstanceTypeOverhead{
+		KubeReserved:      lo.Assign(defaultKubeReserved(capacity), parseResources(kc.KubeReserved)),
+		SystemReserved:    parseResources(kc.SystemReserved),
+		EvictionThreshold: evictionThreshold(capacity, kc),
+	}
+}
+
+// defaultKubeReserved reserves CPU and memory in tiers of the node capacity, like the managed
+// Kubernetes offerings of public clouds: a larger share of small nodes is kept for the kubelet and
+// the container runtime.
+func defaultKubeReserved(capacity corev1.ResourceList) corev1.ResourceList {
+	// 6% of the first core, 1% of the second, 0.5% of the next two and 0.25% of the others.
+	cpuMillis := float64(capacity.Cpu().MilliValue())
+	cpu := tiered(cpuMillis, []tier{{1000, 0.06}, {1000, 0.01}, {2000, 0.005}, {math.Inf(1), 0.0025}})
+	// 25% of the first 4GiB, 20% of the next 4GiB, 10% of the next 8GiB, 6% of the next 112GiB and
+	// 2% of the rest.
+	memoryMiB := float64(capacity.Memory().Value()) / (1 << 20)
+	memory := tiered(memoryMiB, []tier{{4096, 0.25}, {4096, 0.2}, {8192, 0.1}, {114688, 0.06}, {math.Inf(1), 0.02}})
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(cpu), resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(int64(memory)*(1<<20), resource.BinarySI),
+	}
+}
+
+type tier struct {
+	size     float64
+	fraction float64
+}
+
+// tiered returns the sum of the fraction of every tier the amount falls in.
+func tiered(amount float64, tiers []tier) float64 {
+	var reserved float64
+	for _, t := range tiers {
+		if amount <= 0 {
+			break
+		}
+		reserved += math.Min(amount, t.size) * t.fraction
+		amount -= t.size
+	}
+	return reserved
+}
+
+// parseResources parses the reservations of the kubelet configuration. Invalid quantities are
+// skipped, the kubelet refuses them anyway.
+func parseResources(reservations map[string]string) corev1.ResourceList {
+	resources := corev1.ResourceList{}
+	for name, value := range reservations {
+		if quantity, err := resource.ParseQuantity(value); err == nil {
+			resources[corev1.ResourceName(name)] = quantity
+		}
+	}
+	return resources
+}
+
+// evictionThreshold returns the memory and ephemeral storage the kubelet keeps free by evicting
+// pods. Soft thresholds are taken into account when they are larger than the hard ones, pods are
+// evicted once they are crossed for the grace period.
+func evictionThreshold(capacity corev1.ResourceList, kc *v1openstack.KubeletConfiguration) corev1.ResourceList {
+	evictionHard := lo.Assign(defaultEvictionHard, kc.EvictionHard)
+	threshold := corev1.ResourceList{}
+	for signal, resourceName := range map[string]corev1.ResourceName{
+		memoryAvailable: corev1.ResourceMemory,
+		nodefsAvailable: corev1.ResourceEphemeralStorage,
+	} {
+		total, ok := capacity[resourceName]
+		if !ok {
+			continue
+		}
+		quantities := lo.FilterMap([]string{evictionHard[signal], kc.EvictionSoft[signal]}, func(value string, _ int) (resource.Quantity, bool) {
+			return parseEvictionSignal(total, value)
+		})
+		if len(quantities) == 0 {
+			continue
+		}
+		threshold[resourceName] = lo.MaxBy(quantities, func(a, b resource.Quantity) bool { return a.Cmp(b) > 0 })
+	}
+	return threshold
+}
+
+// parseEvictionSignal parses an eviction threshold, either a quantity or a percentage of the
+// capacity of the resource.
+func parseEvictionSignal(capacity resource.Quantity, value string) (resource.Quantity, bool) {
+	if value == "" {
+		return resource.Quantity{}, false
+	}
+	if percentage, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(percentage, 64)
+		if err != nil || p < 0 || p > 100 {
+			return resource.Quantity{}, false
+		}
+		return *resource.NewQuantity(int64(math.Ceil(float64(capacity.Value())*p/100)), resource.BinarySI), true
+	}
+	quantity, err := resource.ParseQuantity(value)
+	return quantity, err == nil
+}
@@ -0,0 +1,160 @@
+package instancetype
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+func TestListInstanceTypesOverhead(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192}},
+	}
+
+	tests := []struct {
+		name              string
+		kubelet           *v1openstack.KubeletConfiguration
+		pods              int64
+		kubeReserved      corev1.ResourceList
+		systemReserved    corev1.ResourceList
+		evictionThreshold corev1.ResourceList
+	}{
+		{
+			name: "defaults",
+			pods: 110,
+			kubeReserved: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("80m"),
+				corev1.ResourceMemory: resource.MustParse("1843Mi"),
+			},
+			systemReserved:    corev1.ResourceList{},
+			evictionThreshold: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("100Mi")},
+		},
+		{
+			name: "kubelet configuration",
+			kubelet: &v1openstack.KubeletConfiguration{
+				MaxPods:        lo.ToPtr(int32(110)),
+				PodsPerCore:    lo.ToPtr(int32(10)),
+				KubeReserved:   map[string]string{"cpu": "200m"},
+				SystemReserved: map[string]string{"memory": "500Mi", "cpu": "invalid"},
+				EvictionHard:   map[string]string{"memory.available": "5%"},
+				EvictionSoft:   map[string]string{"memory.available": "1Gi"},
+			},
+			pods: 40,
+			kubeReserved: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("200m"),
+				corev1.ResourceMemory: resource.MustParse("1843Mi"),
+			},
+			systemReserved:    corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("500Mi")},
+			evictionThreshold: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+		{
+			name: "percentage eviction threshold",
+			kubelet: &v1openstack.KubeletConfiguration{
+				MaxPods:      lo.ToPtr(int32(20)),
+				PodsPerCore:  lo.ToPtr(int32(10)),
+				EvictionHard: map[string]string{"memory.available": "5%"},
+			},
+			pods: 20,
+			kubeReserved: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("80m"),
+				corev1.ResourceMemory: resource.MustParse("1843Mi"),
+			},
+			systemReserved:    corev1.ResourceList{},
+			evictionThreshold: corev1.ResourceList{corev1.ResourceMemory: *resource.NewQuantity(429496730, resource.BinarySI)},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1openstack.OpenStackNodeClass{Spec: v1openstack.OpenStackNodeClassSpec{KubeletConfiguration: tc.kubelet}}
+			instanceTypes, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			instanceType := instanceTypes[0]
+			if pods := instanceType.Capacity.Pods().Value(); pods != tc.pods {
+				t.Errorf("expected %d pods, got %d", tc.pods, pods)
+			}
+			for name, expected := range map[string][2]corev1.ResourceList{
+				"kube reserved":      {tc.kubeReserved, instanceType.Overhead.KubeReserved},
+				"system reserved":    {tc.systemReserved, instanceType.Overhead.SystemReserved},
+				"eviction threshold": {tc.evictionThreshold, instanceType.Overhead.EvictionThreshold},
+			} {
+				if !equalResources(expected[0], expected[1]) {
+					t.Errorf("unexpected %s: expected %v, got %v", name, expected[0], expected[1])
+				}
+			}
+		})
+	}
+}
+
+func equalResources(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		if other, ok := b[name]; !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListInstanceTypesEphemeralStorage(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192, Disk: 20, Ephemeral: 10},
+			{ID: "2", Name: "m1.volume", VCPUs: 4, RAM: 8192},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		nodeClass v1openstack.OpenStackNodeClassSpec
+		expected  map[string][2]string
+	}{
+		{
+			name: "flavor disks",
+			// The second flavor only boots from volumes and has no disk.
+			expected: map[string][2]string{"m1.large": {"30Gi", "3Gi"}, "m1.volume": {"", ""}},
+		},
+		{
+			name: "boot volume",
+			nodeClass: v1openstack.OpenStackNodeClassSpec{
+				Disks:                []v1openstack.Disk{{SizeGiB: 100}, {SizeGiB: 50, Boot: true}},
+				KubeletConfiguration: &v1openstack.KubeletConfiguration{EvictionHard: map[string]string{"nodefs.available": "2Gi"}},
+			},
+			expected: map[string][2]string{"m1.large": {"50Gi", "2Gi"}, "m1.volume": {"50Gi", "2Gi"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			instanceTypes, err := provider.List(context.Background(), &v1openstack.OpenStackNodeClass{Spec: tc.nodeClass})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			for _, instanceType := range instanceTypes {
+				expected := tc.expected[instanceType.Name]
+				capacity, ok := instanceType.Capacity[corev1.ResourceEphemeralStorage]
+				if expected[0] == "" {
+					if ok {
+						t.Errorf("expected no ephemeral-storage for %s, got %v", instanceType.Name, capacity.String())
+					}
+					continue
+				}
+				if !ok || capacity.Cmp(resource.MustParse(expected[0])) != 0 {
+					t.Errorf("expected %s ephemeral-storage for %s, got %v", expected[0], instanceType.Name, capacity.String())
+				}
+				threshold := instanceType.Overhead.EvictionThreshold[corev1.ResourceEphemeralStorage]
+				if threshold.Cmp(resource.MustParse(expected[1])) != 0 {
+					t.Errorf("expected a %s ephemeral-storage eviction threshold for %s, got %v", expected[1], instanceType.Name, threshold.String())
+				}
+			}
+		})
+	}
+}
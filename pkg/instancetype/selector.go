@@ -0,0 +1,75 @@
+package instancetype
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/samber/lo"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+// selectFlavors returns the flavors matched by any of the terms, or every flavor without terms.
+func selectFlavors(terms []v1openstack.OpenStackFlavorSelectorTerm, flavorList []flavors.Flavor, extraSpecs map[string]map[string]string) ([]flavors.Flavor, error) {
+	if len(terms) == 0 {
+		return flavorList, nil
+	}
+	var matchers []func(flavors.Flavor, map[string]string) bool
+	for _, term := range terms {
+		matches, err := newFlavorMatcher(term)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matches)
+	}
+	return lo.Filter(flavorList, func(flavor flavors.Flavor, _ int) bool {
+		return lo.SomeBy(matchers, func(matches func(flavors.Flavor, map[string]string) bool) bool {
+			return matches(flavor, extraSpecs[flavor.ID])
+		})
+	}), nil
+}
+
+// newFlavorMatcher compiles the term into a function reporting whether a flavor, given its extra
+// specs, matches every field of the term.
+func newFlavorMatcher(term v1openstack.OpenStackFlavorSelectorTerm) (func(flavors.Flavor, map[string]string) bool, error) {
+	for _, pattern := range append([]string{term.Name}, term.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid flavor name pattern %q: %w", pattern, err)
+		}
+	}
+	var nameRegex *regexp.Regexp
+	if term.NameRegex != "" {
+		var err error
+		if nameRegex, err = regexp.Compile("^(?:" + term.NameRegex + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid flavor name regex %q: %w", term.NameRegex, err)
+		}
+	}
+	return func(flavor flavors.Flavor, extraSpecs map[string]string) bool {
+		switch {
+		case term.ID != "" && flavor.ID != term.ID:
+			return false
+		case term.Name != "" && !lo.Must(path.Match(term.Name, flavor.Name)):
+			return false
+		case nameRegex != nil && !nameRegex.MatchString(flavor.Name):
+			return false
+		case term.MinVCPUs != nil && flavor.VCPUs < int(*term.MinVCPUs):
+			return false
+		case term.MaxVCPUs != nil && flavor.VCPUs > int(*term.MaxVCPUs):
+			return false
+		case term.MinMemoryMiB != nil && flavor.RAM < int(*term.MinMemoryMiB):
+			return false
+		case term.MaxMemoryMiB != nil && flavor.RAM > int(*term.MaxMemoryMiB):
+			return false
+		}
+		for key, value := range term.ExtraSpecs {
+			if actual, ok := extraSpecs[key]; !ok || actual != value {
+				return false
+			}
+		}
+		return !lo.SomeBy(term.Exclude, func(excluded string) bool {
+			return excluded == flavor.ID || lo.Must(path.Match(excluded, flavor.Name))
+		})
+	}, nil
+}
@@ -0,0 +1,82 @@
+package instancetype
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/samber/lo"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+func TestListInstanceTypesFlavorSelectorTerms(t *testing.T) {
+	provider := DefaultProvider{
+		InstanceTypesInfo: []flavors.Flavor{
+			{ID: "1", Name: "m1.tiny", VCPUs: 1, RAM: 512},
+			{ID: "2", Name: "m1.large", VCPUs: 4, RAM: 8192},
+			{ID: "3", Name: "m1.xlarge", VCPUs: 8, RAM: 16384},
+			{ID: "4", Name: "g1.large", VCPUs: 8, RAM: 32768},
+			{ID: "5", Name: "team-b.large", VCPUs: 4, RAM: 8192},
+		},
+		ExtraSpecs: map[string]map[string]string{
+			"4": {"pci_passthrough:alias": "a100:1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		terms    []v1openstack.OpenStackFlavorSelectorTerm
+		expected []string
+	}{
+		{name: "no terms", expected: []string{"m1.tiny", "m1.large", "m1.xlarge", "g1.large", "team-b.large"}},
+		{name: "id", terms: []v1openstack.OpenStackFlavorSelectorTerm{{ID: "3"}}, expected: []string{"m1.xlarge"}},
+		{name: "name glob", terms: []v1openstack.OpenStackFlavorSelectorTerm{{Name: "m1.*"}}, expected: []string{"m1.tiny", "m1.large", "m1.xlarge"}},
+		{name: "name regex", terms: []v1openstack.OpenStackFlavorSelectorTerm{{NameRegex: `.*\.large`}}, expected: []string{"m1.large", "g1.large", "team-b.large"}},
+		{
+			name:     "vCPU and memory range",
+			terms:    []v1openstack.OpenStackFlavorSelectorTerm{{MinVCPUs: lo.ToPtr(int32(2)), MaxVCPUs: lo.ToPtr(int32(8)), MaxMemoryMiB: lo.ToPtr(int32(16384))}},
+			expected: []string{"m1.large", "m1.xlarge", "team-b.large"},
+		},
+		{
+			name:     "extra specs",
+			terms:    []v1openstack.OpenStackFlavorSelectorTerm{{ExtraSpecs: map[string]string{"pci_passthrough:alias": "a100:1"}}},
+			expected: []string{"g1.large"},
+		},
+		{
+			name:     "exclusions",
+			terms:    []v1openstack.OpenStackFlavorSelectorTerm{{MinMemoryMiB: lo.ToPtr(int32(1024)), Exclude: []string{"team-b.*", "4"}}},
+			expected: []string{"m1.large", "m1.xlarge"},
+		},
+		{
+			name:     "terms are ORed",
+			terms:    []v1openstack.OpenStackFlavorSelectorTerm{{ID: "1"}, {Name: "g1.*"}},
+			expected: []string{"m1.tiny", "g1.large"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1openstack.OpenStackNodeClass{Spec: v1openstack.OpenStackNodeClassSpec{FlavorSelectorTerms: tc.terms}}
+			instanceTypes, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			names := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestSelectFlavorsInvalidPattern(t *testing.T) {
+	provider := DefaultProvider{InstanceTypesInfo: []flavors.Flavor{{ID: "1", Name: "m1.tiny"}}}
+	for _, term := range []v1openstack.OpenStackFlavorSelectorTerm{{Name: "m1.["}, {NameRegex: "m1.("}, {Exclude: []string{"["}}} {
+		nodeClass := &v1openstack.OpenStackNodeClass{Spec: v1openstack.OpenStackNodeClassSpec{FlavorSelectorTerms: []v1openstack.OpenStackFlavorSelectorTerm{term}}}
+		if _, err := provider.SelectFlavors(context.Background(), nodeClass); err == nil {
+			t.Errorf("expected %+v to be rejected", term)
+		}
+	}
+}
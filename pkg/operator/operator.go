@@ -4,23 +4,55 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 	"sigs.k8s.io/karpenter/pkg/operator"
 
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/bootstrap"
+	openstackcache "github.com/ianzx15/karpenter-provider-openstack/pkg/cache"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/controller"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/floatingip"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/image"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/instance"
 	"github.com/ianzx15/karpenter-provider-openstack/pkg/instancetype"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/placement"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/pricing"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/reservation"
 )
 
 func init() {
 
 	karpv1.NormalizedLabels = lo.Assign(karpv1.NormalizedLabels, map[string]string{})
+	karpv1.WellKnownLabels = karpv1.WellKnownLabels.Insert(
+		v1openstack.LabelCPUPolicy,
+		v1openstack.LabelMemPageSize,
+		v1openstack.LabelCPUSockets,
+		v1openstack.LabelFlavorID,
+		v1openstack.LabelInstanceCPU,
+		v1openstack.LabelInstanceMemory,
+		v1openstack.LabelInstanceFamily,
+		v1openstack.LabelInstanceSize,
+		v1openstack.LabelGPUName,
+		v1openstack.LabelGPUCount,
+		v1openstack.LabelReservationID,
+		v1openstack.LabelReservationType,
+	)
+	// Karpenter only lets reserved offerings set the reservation labels the NodeClaims leave
+	// undefined once they are registered as such.
+	cloudprovider.ReservationIDLabel = v1openstack.LabelReservationID
+	cloudprovider.ReservedCapacityLabels.Insert(v1openstack.LabelReservationType)
 }
 
 type Operator struct {
@@ -28,6 +60,7 @@ type Operator struct {
 
 	InstanceTypeProvider instancetype.Provider
 	InstanceProvider     instance.Provider
+	NetworkClient        *gophercloud.ServiceClient
 }
 
 func NewOperator(ctx context.Context, op *operator.Operator) (context.Context, *Operator) {
@@ -68,20 +101,83 @@ func NewOperator(ctx context.Context, op *operator.Operator) (context.Context, *
 		logger.Error(err, "failed to create OpenStack Compute v2 client")
 		os.Exit(1)
 	}
+	imageClient, err := openstack.NewImageServiceV2(provider, gophercloud.EndpointOpts{
+		Region: region,
+	})
+	if err != nil {
+		logger.Error(err, "failed to create OpenStack Image v2 client")
+		os.Exit(1)
+	}
+	networkClient, err := openstack.NewNetworkV2(provider, gophercloud.EndpointOpts{
+		Region: region,
+	})
+	if err != nil {
+		logger.Error(err, "failed to create OpenStack Network v2 client")
+		os.Exit(1)
+	}
 	logger.Info("OpenStack client created successfully", "region", region)
 
+	preemptibleAuthOpts, err := preemptibleAuthOptionsFromEnv(authOpts)
+	if err != nil {
+		logger.Error(err, "invalid preemptible project credentials")
+		os.Exit(1)
+	}
+	var preemptibleComputeClient *gophercloud.ServiceClient
+	if preemptibleAuthOpts != nil {
+		preemptibleProvider, err := openstack.AuthenticatedClient(*preemptibleAuthOpts)
+		if err != nil {
+			logger.Error(err, "failed to authenticate OpenStack client for the preemptible project")
+			os.Exit(1)
+		}
+		preemptibleComputeClient, err = openstack.NewComputeV2(preemptibleProvider, gophercloud.EndpointOpts{
+			Region: region,
+		})
+		if err != nil {
+			logger.Error(err, "failed to create OpenStack Compute v2 client for the preemptible project")
+			os.Exit(1)
+		}
+		logger.Info("OpenStack client created for the preemptible project")
+	}
+
 	// 3. Inicializar Provedores Específicos
-	instanceTypeProvider, err := instancetype.NewProvider(ctx, computeClient)
+	pricingProvider := pricing.NewProvider()
+	unavailableOfferings := openstackcache.NewUnavailableOfferings()
+	flavorNamePattern, err := flavorNamePatternFromEnv()
+	if err != nil {
+		logger.Error(err, "invalid flavor naming convention")
+		os.Exit(1)
+	}
+	instanceTypeProvider, err := instancetype.NewProvider(ctx, computeClient, pricingProvider, unavailableOfferings, flavorNamePattern)
 	if err != nil {
 		logger.Error(err, "failed to create instance type provider")
 		os.Exit(1)
 	}
+	instanceTypeProvider.PreemptibleComputeClient = preemptibleComputeClient
+	instanceTypeProvider.GPUResources, err = gpuResourcesFromEnv()
+	if err != nil {
+		logger.Error(err, "invalid GPU resources")
+		os.Exit(1)
+	}
 
-	instanceProvider := instance.NewProvider(computeClient, clusterName)
+	floatingIPProvider := floatingip.NewProvider(networkClient, clusterName)
+	clusterConfig, err := clusterConfigFromEnv()
+	if err != nil {
+		logger.Error(err, "invalid cluster bootstrap configuration")
+		os.Exit(1)
+	}
+	if clusterConfig == nil {
+		logger.Info("CLUSTER_ENDPOINT not set, passing NodeClass user data to instances unchanged")
+	}
+	instanceProvider := instance.NewProvider(computeClient, floatingIPProvider, clusterConfig, clusterName, unavailableOfferings, preemptibleComputeClient)
+	imageProvider := image.NewProvider(imageClient, cache.New(openstackcache.ImageTTL, openstackcache.DefaultCleanupInterval))
 	lo.Must0(v1openstack.AddToScheme(op.Manager.GetScheme()))
 
 	reconciler := &controller.OpenStackNodeClassReconciler{
-		Client: op.Manager.GetClient(),
+		Client:               op.Manager.GetClient(),
+		ImageProvider:        imageProvider,
+		InstanceTypeProvider: instanceTypeProvider,
+		ComputeClient:        computeClient,
+		NetworkClient:        networkClient,
 	}
 
 	// Inicia o controlador e o registra no Manager
@@ -91,10 +187,268 @@ func NewOperator(ctx context.Context, op *operator.Operator) (context.Context, *
 	}
 	logger.Info("OpenStackNodeClass controller registered successfully")
 
+	pricingConfigMap, err := pricingConfigMapFromEnv()
+	if err != nil {
+		logger.Error(err, "invalid pricing ConfigMap")
+		os.Exit(1)
+	}
+	if pricingConfigMap == nil {
+		logger.Info("PRICING_CONFIGMAP not set, pricing flavors from their vCPUs and memory")
+	} else {
+		pricingReconciler := &controller.PricingReconciler{
+			PricingProvider: pricingProvider,
+			ConfigMap:       *pricingConfigMap,
+		}
+		if err := pricingReconciler.SetupWithManager(op.Manager); err != nil {
+			logger.Error(err, "failed to setup pricing controller")
+			os.Exit(1)
+		}
+	}
+
+	if cloudKitty, _ := strconv.ParseBool(os.Getenv("CLOUDKITTY_PRICING")); cloudKitty {
+		ratingClient, err := pricing.NewRatingClient(provider, gophercloud.EndpointOpts{
+			Region: region,
+		})
+		if err != nil {
+			logger.Error(err, "failed to create CloudKitty rating client")
+			os.Exit(1)
+		}
+		pricingRefreshInterval, err := durationFromEnv("PRICING_REFRESH_INTERVAL", controller.DefaultPricingRefreshInterval)
+		if err != nil {
+			logger.Error(err, "invalid pricing refresh interval")
+			os.Exit(1)
+		}
+		pricingMaxStaleness, err := durationFromEnv("PRICING_MAX_STALENESS", controller.DefaultPricingMaxStaleness)
+		if err != nil {
+			logger.Error(err, "invalid pricing max staleness")
+			os.Exit(1)
+		}
+		if err := op.Manager.Add(&controller.CloudKittyPricingRefresher{
+			PricingProvider: pricingProvider,
+			RatingClient:    ratingClient,
+			Interval:        pricingRefreshInterval,
+			MaxStaleness:    pricingMaxStaleness,
+		}); err != nil {
+			logger.Error(err, "failed to register CloudKitty pricing refresher")
+			os.Exit(1)
+		}
+	}
+
+	refreshInterval, err := durationFromEnv("INSTANCE_TYPE_REFRESH_INTERVAL", controller.DefaultInstanceTypeRefreshInterval)
+	if err != nil {
+		logger.Error(err, "invalid instance type refresh interval")
+		os.Exit(1)
+	}
+	if err := op.Manager.Add(&controller.InstanceTypeRefresher{
+		InstanceTypeProvider: instanceTypeProvider,
+		Interval:             refreshInterval,
+	}); err != nil {
+		logger.Error(err, "failed to register instance type refresher")
+		os.Exit(1)
+	}
+
+	quotaRefreshInterval, err := durationFromEnv("QUOTA_REFRESH_INTERVAL", controller.DefaultQuotaRefreshInterval)
+	if err != nil {
+		logger.Error(err, "invalid quota refresh interval")
+		os.Exit(1)
+	}
+	if err := op.Manager.Add(&controller.QuotaRefresher{
+		InstanceTypeProvider: instanceTypeProvider,
+		Interval:             quotaRefreshInterval,
+	}); err != nil {
+		logger.Error(err, "failed to register quota refresher")
+		os.Exit(1)
+	}
+
+	if placementCapacity, _ := strconv.ParseBool(os.Getenv("PLACEMENT_CAPACITY")); placementCapacity {
+		placementClient, err := placement.NewClient(provider, gophercloud.EndpointOpts{
+			Region: region,
+		})
+		if err != nil {
+			logger.Error(err, "failed to create OpenStack Placement client")
+			os.Exit(1)
+		}
+		instanceTypeProvider.PlacementClient = placementClient
+		capacityRefreshInterval, err := durationFromEnv("PLACEMENT_REFRESH_INTERVAL", controller.DefaultCapacityRefreshInterval)
+		if err != nil {
+			logger.Error(err, "invalid Placement capacity refresh interval")
+			os.Exit(1)
+		}
+		if err := op.Manager.Add(&controller.CapacityRefresher{
+			InstanceTypeProvider: instanceTypeProvider,
+			Interval:             capacityRefreshInterval,
+		}); err != nil {
+			logger.Error(err, "failed to register Placement capacity refresher")
+			os.Exit(1)
+		}
+	}
+
+	if blazar, _ := strconv.ParseBool(os.Getenv("BLAZAR_RESERVATIONS")); blazar {
+		reservationClient, err := reservation.NewClient(provider, gophercloud.EndpointOpts{
+			Region: region,
+		})
+		if err != nil {
+			logger.Error(err, "failed to create Blazar reservation client")
+			os.Exit(1)
+		}
+		instanceTypeProvider.ReservationClient = reservationClient
+		reservationRefreshInterval, err := durationFromEnv("RESERVATION_REFRESH_INTERVAL", controller.DefaultReservationRefreshInterval)
+		if err != nil {
+			logger.Error(err, "invalid reservation refresh interval")
+			os.Exit(1)
+		}
+		if err := op.Manager.Add(&controller.ReservationRefresher{
+			InstanceTypeProvider: instanceTypeProvider,
+			Interval:             reservationRefreshInterval,
+		}); err != nil {
+			logger.Error(err, "failed to register Blazar reservation refresher")
+			os.Exit(1)
+		}
+	}
+
+	interruptionInterval, err := durationFromEnv("INTERRUPTION_CHECK_INTERVAL", controller.DefaultInterruptionCheckInterval)
+	if err != nil {
+		logger.Error(err, "invalid interruption check interval")
+		os.Exit(1)
+	}
+	if err := op.Manager.Add(&controller.InterruptionController{
+		Client:               op.Manager.GetClient(),
+		InstanceProvider:     instanceProvider,
+		UnavailableOfferings: unavailableOfferings,
+		Interval:             interruptionInterval,
+	}); err != nil {
+		logger.Error(err, "failed to register interruption controller")
+		os.Exit(1)
+	}
+
+	if err := op.Manager.Add(&controller.FloatingIPGarbageCollector{
+		FloatingIPProvider: floatingIPProvider,
+		Interval:           controller.DefaultFloatingIPGCInterval,
+	}); err != nil {
+		logger.Error(err, "failed to register floating IP garbage collector")
+		os.Exit(1)
+	}
+
 	// 4. Retornar o Operador estendido
 	return ctx, &Operator{
 		Operator:             op,
 		InstanceTypeProvider: instanceTypeProvider,
 		InstanceProvider:     instanceProvider,
+		NetworkClient:        networkClient,
+	}
+}
+
+// clusterConfigFromEnv reads the settings nodes use to join the cluster with kubeadm. Nodes are
+// only bootstrapped by Karpenter when CLUSTER_ENDPOINT is set.
+func clusterConfigFromEnv() (*bootstrap.ClusterConfig, error) {
+	endpoint := os.Getenv("CLUSTER_ENDPOINT")
+	if endpoint == "" {
+		return nil, nil
+	}
+	config := &bootstrap.ClusterConfig{
+		APIServerEndpoint: endpoint,
+		Token:             os.Getenv("CLUSTER_JOIN_TOKEN"),
+		CACertHashes: lo.Compact(lo.Map(strings.Split(os.Getenv("CLUSTER_CA_CERT_HASHES"), ","), func(hash string, _ int) string {
+			return strings.TrimSpace(hash)
+		})),
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("CLUSTER_JOIN_TOKEN must be set when CLUSTER_ENDPOINT is set")
+	}
+	if len(config.CACertHashes) == 0 {
+		return nil, fmt.Errorf("CLUSTER_CA_CERT_HASHES must be set when CLUSTER_ENDPOINT is set")
+	}
+	return config, nil
+}
+
+// preemptibleAuthOptionsFromEnv reads the credentials of the preemptible project: application
+// credentials of their own, or else the credentials of the controller scoped to another project.
+// nil is returned when no preemptible project is configured.
+func preemptibleAuthOptionsFromEnv(authOpts gophercloud.AuthOptions) (*gophercloud.AuthOptions, error) {
+	credentialID := os.Getenv("PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_ID")
+	credentialSecret := os.Getenv("PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_SECRET")
+	projectID := os.Getenv("PREEMPTIBLE_OS_PROJECT_ID")
+	projectName := os.Getenv("PREEMPTIBLE_OS_PROJECT_NAME")
+	switch {
+	case credentialID != "" || credentialSecret != "":
+		if credentialID == "" || credentialSecret == "" {
+			return nil, fmt.Errorf("PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_ID and PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_SECRET must be set together")
+		}
+		return &gophercloud.AuthOptions{
+			IdentityEndpoint:            authOpts.IdentityEndpoint,
+			ApplicationCredentialID:     credentialID,
+			ApplicationCredentialSecret: credentialSecret,
+			AllowReauth:                 authOpts.AllowReauth,
+		}, nil
+	case projectID != "" || projectName != "":
+		if authOpts.ApplicationCredentialID != "" || authOpts.ApplicationCredentialName != "" {
+			return nil, fmt.Errorf("application credentials are bound to their project, set PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_ID and PREEMPTIBLE_OS_APPLICATION_CREDENTIAL_SECRET instead of the preemptible project")
+		}
+		authOpts.TenantID = projectID
+		authOpts.TenantName = projectName
+		authOpts.Scope = nil
+		return &authOpts, nil
+	}
+	return nil, nil
+}
+
+// pricingConfigMapFromEnv reads the namespace/name of the ConfigMap holding the flavor prices.
+func pricingConfigMapFromEnv() (*types.NamespacedName, error) {
+	value := os.Getenv("PRICING_CONFIGMAP")
+	if value == "" {
+		return nil, nil
+	}
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("PRICING_CONFIGMAP must be in the namespace/name format, got %q", value)
+	}
+	return &types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// flavorNamePatternFromEnv reads the regular expression parsing the family and size of a flavor
+// from its name, with "family" and "size" named groups.
+func flavorNamePatternFromEnv() (*regexp.Regexp, error) {
+	value := os.Getenv("FLAVOR_NAME_PATTERN")
+	if value == "" {
+		return instancetype.DefaultFlavorNamePattern, nil
+	}
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing FLAVOR_NAME_PATTERN: %w", err)
+	}
+	if pattern.SubexpIndex("family") < 0 && pattern.SubexpIndex("size") < 0 {
+		return nil, fmt.Errorf("FLAVOR_NAME_PATTERN must have a family or size named group, got %q", value)
+	}
+	return pattern, nil
+}
+
+// gpuResourcesFromEnv reads the extended resources the GPUs of the PCI aliases and the vGPUs are
+// exposed as.
+func gpuResourcesFromEnv() (map[string]instancetype.GPUResource, error) {
+	value := os.Getenv("GPU_RESOURCES")
+	if value == "" {
+		return instancetype.DefaultGPUResources, nil
+	}
+	gpuResources, err := instancetype.ParseGPUResources(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing GPU_RESOURCES: %w", err)
+	}
+	return gpuResources, nil
+}
+
+// durationFromEnv parses the duration in the environment variable, or returns the default when the
+// variable is not set.
+func durationFromEnv(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", key, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", key, value)
 	}
+	return duration, nil
 }
@@ -0,0 +1,242 @@
+package placement
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/aggregates"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/placement/v1/resourceproviders"
+)
+
+const (
+	// Standard resource classes requested by every flavor.
+	VCPU     = "VCPU"
+	MemoryMB = "MEMORY_MB"
+	DiskGB   = "DISK_GB"
+
+	// DefaultZone is the availability zone of the hosts that are in no zone aggregate, the default
+	// of the default_availability_zone option of Nova.
+	DefaultZone = "nova"
+
+	// microversion returns the root provider of the resource providers, so that nested providers
+	// such as GPUs are counted with their compute node, and every resource class of the trees of
+	// the allocation candidates in their provider summaries.
+	microversion = "1.29"
+	// resourcesPrefix is the prefix of the extra specs overriding the resources requested by a flavor.
+	resourcesPrefix = "resources:"
+)
+
+// NewClient returns a client of the Placement API. Reading inventories and usages requires the
+// admin or reader role.
+func NewClient(provider *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error) {
+	client, err := openstack.NewPlacementV1(provider, eo)
+	if err != nil {
+		return nil, err
+	}
+	client.Microversion = microversion
+	return client, nil
+}
+
+// Capacity is the free capacity of the compute nodes, by availability zone.
+type Capacity struct {
+	// zones holds the free amount of each resource class of the compute nodes of every zone. A
+	// compute node and its nested providers are counted as one host.
+	zones map[string][]map[string]int
+}
+
+// providerSummary is the capacity, already overcommitted and less the reserved amount, and the
+// usage of each resource class of a provider of the allocation candidates.
+type providerSummary struct {
+	Resources map[string]struct {
+		Capacity int `json:"capacity"`
+		Used     int `json:"used"`
+	} `json:"resources"`
+	RootProviderUUID string `json:"root_provider_uuid"`
+}
+
+// Get reads the free resources of the compute nodes from Placement, and their zone from the Nova
+// host aggregates. The resource providers are listed for their names, and their inventories and
+// usages read at once from the allocation candidates of a server of one vCPU and one MB of memory,
+// whatever the number of compute nodes.
+func Get(placementClient, computeClient *gophercloud.ServiceClient) (*Capacity, error) {
+	pages, err := resourceproviders.List(placementClient, nil).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource providers: %w", err)
+	}
+	providers, err := resourceproviders.ExtractResourceProviders(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract resource providers: %w", err)
+	}
+	free, err := freeResources(placementClient)
+	if err != nil {
+		return nil, err
+	}
+	hostZones, err := listHostZones(computeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := &Capacity{zones: map[string][]map[string]int{}}
+	for _, provider := range providers {
+		// Nested providers, such as GPUs, are counted with their compute node.
+		if provider.RootProviderUUID != "" && provider.RootProviderUUID != provider.UUID {
+			continue
+		}
+		zone, inZone := hostZones[shortName(provider.Name)]
+		resources, isCandidate := free[provider.UUID]
+		switch {
+		case isCandidate && !isComputeNode(resources):
+			continue
+		case !isCandidate && !inZone:
+			// Sharing providers, such as shared storage, and full compute nodes of the default zone
+			// can't be told apart, neither is a candidate.
+			continue
+		case !isCandidate:
+			// A compute node of a zone aggregate without a vCPU or MB of memory left.
+			resources = map[string]int{}
+		case !inZone:
+			zone = DefaultZone
+		}
+		capacity.zones[zone] = append(capacity.zones[zone], resources)
+	}
+	return capacity, nil
+}
+
+// freeResources returns what is left of each resource class of the compute nodes that could host
+// one more server, by root provider: its capacity less the usage, summed over its nested providers.
+func freeResources(client *gophercloud.ServiceClient) (map[string]map[string]int, error) {
+	var candidates struct {
+		ProviderSummaries map[string]providerSummary `json:"provider_summaries"`
+	}
+	url := client.ServiceURL("allocation_candidates") + "?resources=" + VCPU + ":1," + MemoryMB + ":1"
+	if _, err := client.Get(url, &candidates, nil); err != nil {
+		return nil, fmt.Errorf("failed to get allocation candidates: %w", err)
+	}
+	free := map[string]map[string]int{}
+	for uuid, summary := range candidates.ProviderSummaries {
+		root := summary.RootProviderUUID
+		if root == "" {
+			root = uuid
+		}
+		if _, ok := free[root]; !ok {
+			free[root] = map[string]int{}
+		}
+		for resourceClass, resource := range summary.Resources {
+			free[root][resourceClass] += max(resource.Capacity-resource.Used, 0)
+		}
+	}
+	return free, nil
+}
+
+// isComputeNode reports whether the provider tree has vCPU and memory inventories, which sharing
+// providers don't.
+func isComputeNode(resources map[string]int) bool {
+	_, hasVCPU := resources[VCPU]
+	_, hasMemory := resources[MemoryMB]
+	return hasVCPU && hasMemory
+}
+
+// listHostZones returns the availability zone of the hosts of the zone aggregates, by short host
+// name: Placement names compute nodes after the hypervisor, which may be fully qualified.
+func listHostZones(client *gophercloud.ServiceClient) (map[string]string, error) {
+	pages, err := aggregates.List(client).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list host aggregates: %w", err)
+	}
+	aggregateList, err := aggregates.ExtractAggregates(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract host aggregates: %w", err)
+	}
+	zones := map[string]string{}
+	for _, aggregate := range aggregateList {
+		if aggregate.AvailabilityZone == "" {
+			continue
+		}
+		for _, host := range aggregate.Hosts {
+			zones[shortName(host)] = aggregate.AvailabilityZone
+		}
+	}
+	return zones, nil
+}
+
+func shortName(host string) string {
+	name, _, _ := strings.Cut(host, ".")
+	return name
+}
+
+// Instances returns how many servers requesting the resources would still fit in the zone, or in
+// every zone when it is empty. It reports false when no compute node of the zone is known.
+func (c *Capacity) Instances(resources map[string]int, zone string) (int, bool) {
+	var hosts []map[string]int
+	if zone == "" {
+		for _, zoneHosts := range c.zones {
+			hosts = append(hosts, zoneHosts...)
+		}
+	} else {
+		hosts = c.zones[zone]
+	}
+	if len(hosts) == 0 {
+		return 0, false
+	}
+	instances := 0
+	for _, free := range hosts {
+		instances += fits(free, resources)
+	}
+	return instances, true
+}
+
+// fits returns how many servers requesting the resources fit in the free resources of a host. A
+// host without disk inventory stores its disks on a sharing provider, which is not accounted for.
+func fits(free, resources map[string]int) int {
+	instances := math.MaxInt
+	for resourceClass, requested := range resources {
+		if requested <= 0 {
+			continue
+		}
+		available, ok := free[resourceClass]
+		if !ok && resourceClass == DiskGB {
+			continue
+		}
+		instances = min(instances, available/requested)
+	}
+	if instances == math.MaxInt {
+		return 0
+	}
+	return instances
+}
+
+// FlavorResources returns the resources a server of the flavor requests from Placement. The root
+// disk is not requested when the server boots from a volume. resources:<class> extra specs override
+// the standard resource classes, zero removing them, and add custom ones.
+func FlavorResources(flavor flavors.Flavor, extraSpecs map[string]string, bootFromVolume bool) map[string]int {
+	resources := map[string]int{
+		VCPU:     flavor.VCPUs,
+		MemoryMB: flavor.RAM,
+		DiskGB:   flavor.Ephemeral + int(math.Ceil(float64(flavor.Swap)/1024)),
+	}
+	if !bootFromVolume {
+		resources[DiskGB] += flavor.Disk
+	}
+	for key, value := range extraSpecs {
+		resourceClass, ok := strings.CutPrefix(key, resourcesPrefix)
+		if !ok {
+			continue
+		}
+		amount, err := strconv.Atoi(value)
+		if err != nil || amount < 0 {
+			continue
+		}
+		resources[resourceClass] = amount
+	}
+	for resourceClass, amount := range resources {
+		if amount == 0 {
+			delete(resources, resourceClass)
+		}
+	}
+	return resources
+}
@@ -0,0 +1,145 @@
+package placement
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func TestGet(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddAggregate(fake.Aggregate{Name: "rack-1", AvailabilityZone: "az-1", Hosts: []string{"compute-1", "compute-2"}})
+	nova.AddAggregate(fake.Aggregate{Name: "gpu", Hosts: []string{"compute-3"}})
+	nova.AddAggregate(fake.Aggregate{Name: "rack-2", AvailabilityZone: "az-2", Hosts: []string{"compute-4"}})
+	placement := fake.NewPlacement()
+	defer placement.Close()
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-1", Name: "compute-1.example.org", Inventories: map[string]fake.Inventory{
+		VCPU:     {Total: 16, Reserved: 2, AllocationRatio: 2},
+		MemoryMB: {Total: 32768, Reserved: 512},
+		DiskGB:   {Total: 500},
+	}})
+	placement.SetUsage("rp-1", VCPU, 20)
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-2", Name: "compute-2", Inventories: map[string]fake.Inventory{
+		VCPU:     {Total: 8},
+		MemoryMB: {Total: 16384},
+		DiskGB:   {Total: 100},
+	}})
+	placement.SetUsage("rp-2", MemoryMB, 16384)
+	// compute-3 is in no zone aggregate, its GPU is a nested provider.
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-3", Name: "compute-3", Inventories: map[string]fake.Inventory{
+		VCPU:     {Total: 32},
+		MemoryMB: {Total: 65536},
+		DiskGB:   {Total: 1000},
+	}})
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-3-gpu", Name: "compute-3_pci_0000_81_00_0", ParentUUID: "rp-3", Inventories: map[string]fake.Inventory{
+		"VGPU": {Total: 4},
+	}})
+	placement.SetUsage("rp-3-gpu", "VGPU", 1)
+	// compute-4 keeps its disks on the shared storage, which is a provider of its own.
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-4", Name: "compute-4", Inventories: map[string]fake.Inventory{
+		VCPU:     {Total: 16},
+		MemoryMB: {Total: 32768},
+	}})
+	placement.AddResourceProvider(fake.ResourceProvider{UUID: "rp-shared", Name: "shared-storage", Inventories: map[string]fake.Inventory{
+		DiskGB: {Total: 10000},
+	}})
+
+	capacity, err := Get(placement.ServiceClient(), nova.ServiceClient())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	expected := map[string][]map[string]int{
+		"az-1": {
+			{VCPU: 8, MemoryMB: 32256, DiskGB: 500},
+			// compute-2 has no memory left, it is no allocation candidate.
+			{},
+		},
+		"az-2": {
+			{VCPU: 16, MemoryMB: 32768},
+		},
+		DefaultZone: {
+			{VCPU: 32, MemoryMB: 65536, DiskGB: 1000, "VGPU": 3},
+		},
+	}
+	if !reflect.DeepEqual(capacity.zones, expected) {
+		t.Errorf("expected %v, got %v", expected, capacity.zones)
+	}
+
+	placement.SetUnavailable(true)
+	if _, err := Get(placement.ServiceClient(), nova.ServiceClient()); err == nil {
+		t.Errorf("expected an error when the resource providers can't be listed")
+	}
+}
+
+func TestInstances(t *testing.T) {
+	capacity := &Capacity{zones: map[string][]map[string]int{
+		"az-1": {
+			{VCPU: 8, MemoryMB: 16384, DiskGB: 100},
+			{VCPU: 3, MemoryMB: 65536, DiskGB: 100},
+		},
+		"az-2": {
+			{VCPU: 64, MemoryMB: 262144, DiskGB: 1000, "VGPU": 1},
+		},
+		"az-shared-storage": {
+			{VCPU: 4, MemoryMB: 8192},
+		},
+	}}
+	tests := []struct {
+		name      string
+		resources map[string]int
+		zone      string
+		expected  int
+		known     bool
+	}{
+		{name: "limited by vCPUs and memory", resources: map[string]int{VCPU: 2, MemoryMB: 4096}, zone: "az-1", expected: 5, known: true},
+		{name: "limited by disk", resources: map[string]int{VCPU: 1, MemoryMB: 1024, DiskGB: 40}, zone: "az-1", expected: 4, known: true},
+		{name: "custom resource class", resources: map[string]int{VCPU: 8, MemoryMB: 16384, "VGPU": 1}, zone: "az-1", expected: 0, known: true},
+		{name: "every zone", resources: map[string]int{VCPU: 8, MemoryMB: 16384, "VGPU": 1}, expected: 1, known: true},
+		{name: "disks on shared storage", resources: map[string]int{VCPU: 2, MemoryMB: 2048, DiskGB: 40}, zone: "az-shared-storage", expected: 2, known: true},
+		{name: "unknown zone", resources: map[string]int{VCPU: 1}, zone: "az-3"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			instances, known := capacity.Instances(tc.resources, tc.zone)
+			if instances != tc.expected || known != tc.known {
+				t.Errorf("expected %d, %t, got %d, %t", tc.expected, tc.known, instances, known)
+			}
+		})
+	}
+}
+
+func TestFlavorResources(t *testing.T) {
+	flavor := flavors.Flavor{Name: "g1.large", VCPUs: 8, RAM: 16384, Disk: 40, Ephemeral: 10, Swap: 1536}
+	tests := []struct {
+		name           string
+		extraSpecs     map[string]string
+		bootFromVolume bool
+		expected       map[string]int
+	}{
+		{name: "local disks", expected: map[string]int{VCPU: 8, MemoryMB: 16384, DiskGB: 52}},
+		{name: "boot from volume", bootFromVolume: true, expected: map[string]int{VCPU: 8, MemoryMB: 16384, DiskGB: 12}},
+		{
+			name: "resources extra specs",
+			extraSpecs: map[string]string{
+				"resources:VGPU":    "1",
+				"resources:DISK_GB": "0",
+				"resources:PCPU":    "8",
+				"resources:VCPU":    "0",
+				"hw:cpu_policy":     "dedicated",
+				"resources:BOGUS":   "many",
+			},
+			expected: map[string]int{"PCPU": 8, MemoryMB: 16384, "VGPU": 1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := FlavorResources(flavor, tc.extraSpecs, tc.bootFromVolume); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package pricing
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/samber/lo"
+)
+
+const (
+	// RatingServiceType is the catalog type of the CloudKitty rating API.
+	RatingServiceType = "rating"
+	// InstanceService is the CloudKitty service that rates Nova servers.
+	InstanceService = "instance"
+)
+
+// flavorFields are the hashmap fields CloudKitty rates flavors on, depending on its collector.
+var flavorFields = []string{"flavor_name", "flavor_id", "flavor"}
+
+// NewRatingClient returns a client for the CloudKitty API registered in the service catalog.
+func NewRatingClient(provider *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error) {
+	eo.ApplyDefaults(RatingServiceType)
+	endpoint, err := provider.EndpointLocator(eo)
+	if err != nil {
+		return nil, err
+	}
+	return &gophercloud.ServiceClient{ProviderClient: provider, Endpoint: endpoint, Type: RatingServiceType}, nil
+}
+
+type hashmapService struct {
+	ID   string `json:"service_id"`
+	Name string `json:"name"`
+}
+
+type hashmapField struct {
+	ID   string `json:"field_id"`
+	Name string `json:"name"`
+}
+
+type hashmapMapping struct {
+	Value    string `json:"value"`
+	Cost     cost   `json:"cost"`
+	Type     string `json:"type"`
+	TenantID string `json:"tenant_id"`
+}
+
+// cost is a CloudKitty decimal, which is serialized either as a number or as a string.
+type cost float64
+
+func (c *cost) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseFloat(strings.Trim(string(data), `"`), 64)
+	if err != nil {
+		return fmt.Errorf("invalid cost %s: %w", data, err)
+	}
+	*c = cost(value)
+	return nil
+}
+
+// Ratings returns the flat rates of the flavors in the CloudKitty hashmap module, by flavor name or
+// ID. Rates are taken as hourly prices, the default CloudKitty collection period. Project specific
+// mappings are ignored, they don't apply to every node.
+func Ratings(client *gophercloud.ServiceClient) (map[string]float64, error) {
+	var services struct {
+		Services []hashmapService `json:"services"`
+	}
+	if _, err := client.Get(hashmapURL(client, "services", nil), &services, nil); err != nil {
+		return nil, fmt.Errorf("failed to list CloudKitty hashmap services: %w", err)
+	}
+	service, ok := lo.Find(services.Services, func(s hashmapService) bool { return s.Name == InstanceService })
+	if !ok {
+		return nil, fmt.Errorf("CloudKitty hashmap service %q not found", InstanceService)
+	}
+
+	var fields struct {
+		Fields []hashmapField `json:"fields"`
+	}
+	if _, err := client.Get(hashmapURL(client, "fields", url.Values{"service_id": {service.ID}}), &fields, nil); err != nil {
+		return nil, fmt.Errorf("failed to list CloudKitty hashmap fields: %w", err)
+	}
+	ratings := map[string]float64{}
+	for _, field := range fields.Fields {
+		if !lo.Contains(flavorFields, field.Name) {
+			continue
+		}
+		var mappings struct {
+			Mappings []hashmapMapping `json:"mappings"`
+		}
+		if _, err := client.Get(hashmapURL(client, "mappings", url.Values{"field_id": {field.ID}}), &mappings, nil); err != nil {
+			return nil, fmt.Errorf("failed to list CloudKitty hashmap mappings of field %s: %w", field.Name, err)
+		}
+		for _, mapping := range mappings.Mappings {
+			if mapping.Type != "flat" || mapping.TenantID != "" || mapping.Cost < 0 {
+				continue
+			}
+			ratings[mapping.Value] = float64(mapping.Cost)
+		}
+	}
+	return ratings, nil
+}
+
+func hashmapURL(client *gophercloud.ServiceClient, resource string, query url.Values) string {
+	u := client.ServiceURL("v1", "rating", "module_config", "hashmap", resource)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
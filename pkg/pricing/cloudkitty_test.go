@@ -0,0 +1,58 @@
+package pricing
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func TestRatings(t *testing.T) {
+	cloudKitty := fake.NewCloudKitty()
+	defer cloudKitty.Close()
+	cloudKitty.AddService("instance")
+	cloudKitty.AddService("volume.size")
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "flavor_name", Value: "m1.large", Cost: "0.12000000"})
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "flavor_id", Value: "gpu-id", Cost: "2.5"})
+	// Not a flat price of every node.
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "flavor_name", Value: "m1.small", Cost: "1.1", Type: "rate"})
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "flavor_name", Value: "m1.xlarge", Cost: "0.01", TenantID: "project-a"})
+	cloudKitty.AddMapping(fake.RatingMapping{Field: "image_id", Value: "image-1", Cost: "0.5"})
+
+	ratings, err := Ratings(cloudKitty.ServiceClient())
+	if err != nil {
+		t.Fatalf("Ratings failed: %v", err)
+	}
+	expected := map[string]float64{"m1.large": 0.12, "gpu-id": 2.5}
+	if !reflect.DeepEqual(ratings, expected) {
+		t.Errorf("expected %v, got %v", expected, ratings)
+	}
+}
+
+func TestRatingsUnavailable(t *testing.T) {
+	cloudKitty := fake.NewCloudKitty()
+	defer cloudKitty.Close()
+	cloudKitty.AddService("instance")
+	cloudKitty.SetUnavailable(true)
+
+	if _, err := Ratings(cloudKitty.ServiceClient()); err == nil {
+		t.Errorf("expected an error while CloudKitty is unavailable")
+	}
+}
+
+func TestPriceFallsBackToStaticTable(t *testing.T) {
+	provider := NewProvider()
+	provider.UpdateConfig(Config{Flavors: map[string]float64{"m1.large": 0.3}})
+	flavor := flavors.Flavor{ID: "1", Name: "m1.large"}
+
+	provider.UpdateRatings(map[string]float64{"m1.large": 0.12})
+	if price := provider.Price(flavor, ""); price != 0.12 {
+		t.Errorf("expected the CloudKitty rating 0.12, got %v", price)
+	}
+	provider.UpdateRatings(nil)
+	if price := provider.Price(flavor, ""); price != 0.3 {
+		t.Errorf("expected the static price 0.3, got %v", price)
+	}
+}
@@ -0,0 +1,120 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigMapKey is the key of the pricing ConfigMap that holds the Config.
+const ConfigMapKey = "pricing.yaml"
+
+// Config describes the hourly price of the flavors. Prices only need to be consistent with each
+// other, e.g. internal chargeback rates: Karpenter uses them to order flavors and to consolidate.
+type Config struct {
+	// Flavors holds the price of individual flavors, by flavor name or ID. It takes precedence over
+	// the per-resource prices.
+	Flavors map[string]float64 `json:"flavors,omitempty"`
+	// VCPU is the price of a vCPU.
+	VCPU float64 `json:"vcpu,omitempty"`
+	// MemoryGiB is the price of a GiB of RAM.
+	MemoryGiB float64 `json:"memoryGiB,omitempty"`
+	// DiskGiB is the price of a GiB of root and ephemeral disk.
+	DiskGiB float64 `json:"diskGiB,omitempty"`
+	// ZoneMultipliers scale the price of every flavor in an availability zone. Zones without a
+	// multiplier use 1.
+	ZoneMultipliers map[string]float64 `json:"zoneMultipliers,omitempty"`
+}
+
+// DefaultConfig is used until a pricing ConfigMap is found. It makes larger flavors more expensive,
+// in the same proportions as public cloud on-demand prices.
+var DefaultConfig = Config{
+	VCPU:      0.03,
+	MemoryGiB: 0.004,
+}
+
+// Parse reads a Config from the content of the pricing ConfigMap.
+func Parse(data string) (Config, error) {
+	if strings.TrimSpace(data) == "" {
+		return Config{}, fmt.Errorf("pricing config is empty")
+	}
+	config := Config{}
+	if err := yaml.UnmarshalStrict([]byte(data), &config); err != nil {
+		return Config{}, fmt.Errorf("parsing pricing config: %w", err)
+	}
+	for name, price := range config.Flavors {
+		if price < 0 {
+			return Config{}, fmt.Errorf("price of flavor %s is negative", name)
+		}
+	}
+	if config.VCPU < 0 || config.MemoryGiB < 0 || config.DiskGiB < 0 {
+		return Config{}, fmt.Errorf("per-resource prices must not be negative")
+	}
+	for zone, multiplier := range config.ZoneMultipliers {
+		if multiplier <= 0 {
+			return Config{}, fmt.Errorf("multiplier of zone %s must be positive", zone)
+		}
+	}
+	return config, nil
+}
+
+type Provider interface {
+	// Price returns the hourly price of the flavor in the availability zone.
+	Price(flavor flavors.Flavor, zone string) float64
+}
+
+type DefaultProvider struct {
+	mu     sync.RWMutex
+	config Config
+	// ratings are the flavor prices from the rating service, by flavor name or ID. They take
+	// precedence over the config, which is the static table used while the service is unavailable.
+	ratings map[string]float64
+}
+
+func NewProvider() *DefaultProvider {
+	return &DefaultProvider{config: DefaultConfig}
+}
+
+// UpdateConfig replaces the prices used for new offerings.
+func (p *DefaultProvider) UpdateConfig(config Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+}
+
+// UpdateRatings replaces the flavor prices from the rating service. Nil falls back to the config.
+func (p *DefaultProvider) UpdateRatings(ratings map[string]float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ratings = ratings
+}
+
+func (p *DefaultProvider) Price(flavor flavors.Flavor, zone string) float64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	price, ok := lookup(flavor, p.ratings)
+	if !ok {
+		price, ok = lookup(flavor, p.config.Flavors)
+	}
+	if !ok {
+		price = p.config.VCPU*float64(flavor.VCPUs) +
+			p.config.MemoryGiB*float64(flavor.RAM)/1024 +
+			p.config.DiskGiB*float64(flavor.Disk+flavor.Ephemeral)
+	}
+	if multiplier, ok := p.config.ZoneMultipliers[zone]; ok {
+		price *= multiplier
+	}
+	return price
+}
+
+// lookup returns the price of the flavor by name, or else by ID.
+func lookup(flavor flavors.Flavor, prices map[string]float64) (float64, bool) {
+	if price, ok := prices[flavor.Name]; ok {
+		return price, true
+	}
+	price, ok := prices[flavor.ID]
+	return price, ok
+}
@@ -0,0 +1,62 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+)
+
+func TestPrice(t *testing.T) {
+	provider := NewProvider()
+	provider.UpdateConfig(Config{
+		Flavors:         map[string]float64{"m1.large": 0.3, "gpu-id": 2},
+		VCPU:            0.02,
+		MemoryGiB:       0.005,
+		DiskGiB:         0.001,
+		ZoneMultipliers: map[string]float64{"az-premium": 1.5},
+	})
+
+	tests := []struct {
+		name     string
+		flavor   flavors.Flavor
+		zone     string
+		expected float64
+	}{
+		{name: "by flavor name", flavor: flavors.Flavor{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192}, expected: 0.3},
+		{name: "by flavor ID", flavor: flavors.Flavor{ID: "gpu-id", Name: "g1.large", VCPUs: 8, RAM: 65536}, expected: 2},
+		{name: "formula", flavor: flavors.Flavor{ID: "2", Name: "m1.small", VCPUs: 2, RAM: 2048, Disk: 20, Ephemeral: 10}, expected: 0.04 + 0.01 + 0.03},
+		{name: "zone multiplier", flavor: flavors.Flavor{ID: "1", Name: "m1.large"}, zone: "az-premium", expected: 0.45},
+		{name: "zone without multiplier", flavor: flavors.Flavor{ID: "1", Name: "m1.large"}, zone: "az-1", expected: 0.3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if price := provider.Price(tc.flavor, tc.zone); math.Abs(price-tc.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tc.expected, price)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse("flavors:\n  m1.large: 0.3\nvcpu: 0.02\nmemoryGiB: 0.005\nzoneMultipliers:\n  az-1: 1.2\n")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if config.Flavors["m1.large"] != 0.3 || config.VCPU != 0.02 || config.MemoryGiB != 0.005 || config.ZoneMultipliers["az-1"] != 1.2 {
+		t.Errorf("unexpected config: %+v", config)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"vcpu: [1]",
+		"cpu: 0.02",
+		"vcpu: -1",
+		"flavors:\n  m1.large: -0.3",
+		"zoneMultipliers:\n  az-1: 0",
+	} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package quota
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/limits"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+)
+
+// Unlimited is the value Nova reports for a resource without quota.
+const Unlimited = -1
+
+// Remaining is the Nova quota left to the project: vCPUs, RAM in MiB and servers. Resources without
+// quota are Unlimited.
+type Remaining struct {
+	Cores     int
+	RAMMiB    int
+	Instances int
+}
+
+// Get reads the absolute limits and the current usage of the project from Nova.
+func Get(computeClient *gophercloud.ServiceClient) (Remaining, error) {
+	l, err := limits.Get(computeClient, nil).Extract()
+	if err != nil {
+		return Remaining{}, fmt.Errorf("failed to get compute limits: %w", err)
+	}
+	return Remaining{
+		Cores:     remaining(l.Absolute.MaxTotalCores, l.Absolute.TotalCoresUsed),
+		RAMMiB:    remaining(l.Absolute.MaxTotalRAMSize, l.Absolute.TotalRAMUsed),
+		Instances: remaining(l.Absolute.MaxTotalInstances, l.Absolute.TotalInstancesUsed),
+	}, nil
+}
+
+func remaining(limit, used int) int {
+	if limit < 0 {
+		return Unlimited
+	}
+	// Usage exceeds the limit when the quota is lowered below it.
+	return max(limit-used, 0)
+}
+
+// Fits reports whether one more server of the flavor can be launched within the quota.
+func (r Remaining) Fits(flavor flavors.Flavor) bool {
+	return fits(r.Cores, flavor.VCPUs) && fits(r.RAMMiB, flavor.RAM) && fits(r.Instances, 1)
+}
+
+func fits(remaining, requested int) bool {
+	return remaining == Unlimited || requested <= remaining
+}
@@ -0,0 +1,57 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func TestGet(t *testing.T) {
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddFlavor(fake.Flavor{ID: "1", Name: "m1.large", VCPUs: 4, RAM: 8192})
+	nova.AddServer(fake.Server{ID: "server-1", FlavorID: "1"})
+	nova.AddServer(fake.Server{ID: "server-2", FlavorID: "1"})
+
+	remaining, err := Get(nova.ServiceClient())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if expected := (Remaining{Cores: Unlimited, RAMMiB: Unlimited, Instances: Unlimited}); remaining != expected {
+		t.Errorf("expected %+v, got %+v", expected, remaining)
+	}
+
+	// The quota was lowered below the RAM in use.
+	nova.SetLimits(fake.Limits{MaxTotalCores: 20, MaxTotalRAMSize: 10240, MaxTotalInstances: -1})
+	remaining, err = Get(nova.ServiceClient())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if expected := (Remaining{Cores: 12, RAMMiB: 0, Instances: Unlimited}); remaining != expected {
+		t.Errorf("expected %+v, got %+v", expected, remaining)
+	}
+}
+
+func TestFits(t *testing.T) {
+	flavor := flavors.Flavor{Name: "m1.large", VCPUs: 4, RAM: 8192}
+	tests := []struct {
+		name      string
+		remaining Remaining
+		expected  bool
+	}{
+		{name: "unlimited", remaining: Remaining{Cores: Unlimited, RAMMiB: Unlimited, Instances: Unlimited}, expected: true},
+		{name: "exactly enough", remaining: Remaining{Cores: 4, RAMMiB: 8192, Instances: 1}, expected: true},
+		{name: "out of cores", remaining: Remaining{Cores: 3, RAMMiB: Unlimited, Instances: Unlimited}},
+		{name: "out of RAM", remaining: Remaining{Cores: Unlimited, RAMMiB: 4096, Instances: Unlimited}},
+		{name: "out of instances", remaining: Remaining{Cores: Unlimited, RAMMiB: Unlimited, Instances: 0}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.remaining.Fits(flavor); actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package reservation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/samber/lo"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+)
+
+const (
+	// ServiceType is the catalog type of the Blazar reservation API.
+	ServiceType = "reservation"
+
+	// Blazar resource types of the reservations.
+	HostReservation     = "physical:host"
+	InstanceReservation = "virtual:instance"
+
+	// SchedulerHint is the Nova scheduler hint placing a server on the hosts of a reservation.
+	SchedulerHint = "reservation"
+
+	leaseStatusActive       = "ACTIVE"
+	reservationStatusActive = "active"
+)
+
+// NewClient returns a client for the Blazar API registered in the service catalog. The endpoint is
+// registered with or without its version.
+func NewClient(provider *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (*gophercloud.ServiceClient, error) {
+	eo.ApplyDefaults(ServiceType)
+	endpoint, err := provider.EndpointLocator(eo)
+	if err != nil {
+		return nil, err
+	}
+	endpoint = gophercloud.NormalizeURL(endpoint)
+	resourceBase := endpoint
+	if !strings.HasSuffix(endpoint, "/v1/") {
+		resourceBase += "v1/"
+	}
+	return &gophercloud.ServiceClient{ProviderClient: provider, Endpoint: endpoint, ResourceBase: resourceBase, Type: ServiceType}, nil
+}
+
+// Resources are the vCPUs, RAM in MiB and disk in GB of a host or a server.
+type Resources struct {
+	VCPUs    int
+	MemoryMB int
+	DiskGB   int
+}
+
+func flavorResources(flavor flavors.Flavor) Resources {
+	return Resources{VCPUs: flavor.VCPUs, MemoryMB: flavor.RAM, DiskGB: flavor.Disk}
+}
+
+// Host is a compute host allocated to a reservation.
+type Host struct {
+	Name string
+	// Zone is the availability zone Blazar recorded for the host, if any.
+	Zone string
+	Resources
+}
+
+// Reservation is an active reservation of an active lease of the project.
+type Reservation struct {
+	ID        string
+	LeaseID   string
+	LeaseName string
+	// ResourceType is HostReservation or InstanceReservation.
+	ResourceType string
+	// Hosts are the hosts allocated to the reservation.
+	Hosts []Host
+	// HostsHidden is set on host reservations when Blazar didn't let the project list the hosts,
+	// leaving them without capacity.
+	HostsHidden bool
+
+	// Amount is the number of servers of an instance reservation, and Resources the resources of
+	// each of them. Blazar creates a flavor with the ID of the reservation for them.
+	Amount int
+	Resources
+
+	// Instances and Used are the servers running in the reservation and the resources they take.
+	Instances int
+	Used      Resources
+}
+
+// Type returns the value of the reservation-type label of the reservation.
+func (r Reservation) Type() string {
+	if r.ResourceType == InstanceReservation {
+		return v1openstack.ReservationTypeInstance
+	}
+	return v1openstack.ReservationTypeHost
+}
+
+// Zone returns the availability zone of the hosts of the reservation, or "" when it is unknown or
+// the hosts span several zones.
+func (r Reservation) Zone() string {
+	zones := lo.Uniq(lo.Map(r.Hosts, func(host Host, _ int) string { return host.Zone }))
+	if len(zones) != 1 {
+		return ""
+	}
+	return zones[0]
+}
+
+// Offers reports whether servers of the flavor can be launched into the reservation. Instance
+// reservations only take the flavors of the size they were made for.
+func (r Reservation) Offers(flavor flavors.Flavor) bool {
+	if r.ResourceType == InstanceReservation {
+		return flavorResources(flavor) == r.Resources
+	}
+	return true
+}
+
+// Capacity returns how many more servers of the flavor fit in the reservation. The hosts of a host
+// reservation are pooled, so it is an upper bound when the servers running in it are spread out.
+func (r Reservation) Capacity(flavor flavors.Flavor) int {
+	if !r.Offers(flavor) {
+		return 0
+	}
+	if r.ResourceType == InstanceReservation {
+		return max(r.Amount-r.Instances, 0)
+	}
+	var free Resources
+	fitsOnAHost := false
+	for _, host := range r.Hosts {
+		free.VCPUs += host.VCPUs
+		free.MemoryMB += host.MemoryMB
+		free.DiskGB += host.DiskGB
+		fitsOnAHost = fitsOnAHost || fits(host.Resources, flavorResources(flavor)) > 0
+	}
+	if !fitsOnAHost {
+		return 0
+	}
+	free.VCPUs -= r.Used.VCPUs
+	free.MemoryMB -= r.Used.MemoryMB
+	free.DiskGB -= r.Used.DiskGB
+	return fits(free, flavorResources(flavor))
+}
+
+// fits returns how many servers requesting the resources fit in the free resources.
+func fits(free, requested Resources) int {
+	instances := math.MaxInt
+	for _, amounts := range [][2]int{{free.VCPUs, requested.VCPUs}, {free.MemoryMB, requested.MemoryMB}, {free.DiskGB, requested.DiskGB}} {
+		if amounts[1] <= 0 {
+			continue
+		}
+		instances = min(instances, max(amounts[0], 0)/amounts[1])
+	}
+	if instances == math.MaxInt {
+		return 0
+	}
+	return instances
+}
+
+// flexibleString is a Blazar ID, which is serialized either as a number or as a string.
+type flexibleString string
+
+func (s *flexibleString) UnmarshalJSON(data []byte) error {
+	*s = flexibleString(strings.Trim(string(data), `"`))
+	return nil
+}
+
+type lease struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	Status       string             `json:"status"`
+	Reservations []leaseReservation `json:"reservations"`
+}
+
+type leaseReservation struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	ResourceType string `json:"resource_type"`
+	Amount       int    `json:"amount"`
+	VCPUs        int    `json:"vcpus"`
+	MemoryMB     int    `json:"memory_mb"`
+	DiskGB       int    `json:"disk_gb"`
+}
+
+type host struct {
+	ID                 flexibleString `json:"id"`
+	HypervisorHostname string         `json:"hypervisor_hostname"`
+	AvailabilityZone   string         `json:"availability_zone"`
+	VCPUs              int            `json:"vcpus"`
+	MemoryMB           int            `json:"memory_mb"`
+	LocalGB            int            `json:"local_gb"`
+}
+
+type allocation struct {
+	ResourceID   flexibleString `json:"resource_id"`
+	Reservations []struct {
+		ID string `json:"id"`
+	} `json:"reservations"`
+}
+
+// Get returns the active reservations of the active leases of the project, with the hosts Blazar
+// allocated to them and the servers already running in them. Servers are counted in a reservation
+// when they were launched into it by Karpenter, or run the flavor of an instance reservation.
+// catalog resolves the resources of the servers.
+func Get(reservationClient, computeClient *gophercloud.ServiceClient, catalog []flavors.Flavor) ([]Reservation, error) {
+	var leases struct {
+		Leases []lease `json:"leases"`
+	}
+	if _, err := reservationClient.Get(reservationClient.ServiceURL("leases"), &leases, nil); err != nil {
+		return nil, fmt.Errorf("failed to list Blazar leases: %w", err)
+	}
+	var reservations []Reservation
+	for _, l := range leases.Leases {
+		if l.Status != leaseStatusActive {
+			continue
+		}
+		for _, r := range l.Reservations {
+			if r.Status != reservationStatusActive || (r.ResourceType != HostReservation && r.ResourceType != InstanceReservation) {
+				continue
+			}
+			reservations = append(reservations, Reservation{
+				ID:           r.ID,
+				LeaseID:      l.ID,
+				LeaseName:    l.Name,
+				ResourceType: r.ResourceType,
+				Amount:       r.Amount,
+				Resources:    Resources{VCPUs: r.VCPUs, MemoryMB: r.MemoryMB, DiskGB: r.DiskGB},
+			})
+		}
+	}
+	if len(reservations) == 0 {
+		return nil, nil
+	}
+
+	byID := map[string]*Reservation{}
+	for i := range reservations {
+		byID[reservations[i].ID] = &reservations[i]
+	}
+	allocatedHosts, hidden, err := listAllocatedHosts(reservationClient)
+	if err != nil {
+		return nil, err
+	}
+	for reservationID, hosts := range allocatedHosts {
+		if r, ok := byID[reservationID]; ok {
+			r.Hosts = hosts
+		}
+	}
+	for i := range reservations {
+		reservations[i].HostsHidden = hidden && reservations[i].ResourceType == HostReservation
+	}
+
+	pages, err := servers.List(computeClient, servers.ListOpts{}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+	serverList, err := servers.ExtractServers(pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract servers: %w", err)
+	}
+	flavorsByID := lo.SliceToMap(catalog, func(flavor flavors.Flavor) (string, flavors.Flavor) { return flavor.ID, flavor })
+	for _, server := range serverList {
+		flavorID, _ := server.Flavor["id"].(string)
+		r, ok := byID[server.Metadata[v1openstack.ReservationMetadataKey]]
+		if !ok {
+			r, ok = byID[flavorID]
+		}
+		if !ok {
+			continue
+		}
+		r.Instances++
+		if flavor, ok := flavorsByID[flavorID]; ok {
+			r.Used.VCPUs += flavor.VCPUs
+			r.Used.MemoryMB += flavor.RAM
+			r.Used.DiskGB += flavor.Disk
+		}
+	}
+	return reservations, nil
+}
+
+// listAllocatedHosts returns the hosts allocated to each reservation. Blazar only lets admins list
+// hosts by default: without the role, no host is known, which is reported by hidden, and host
+// reservations have no capacity.
+func listAllocatedHosts(client *gophercloud.ServiceClient) (allocated map[string][]Host, hidden bool, err error) {
+	var hosts struct {
+		Hosts []host `json:"hosts"`
+	}
+	if _, err := client.Get(client.ServiceURL("os-hosts"), &hosts, nil); err != nil {
+		if isForbidden(err) {
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("failed to list Blazar hosts: %w", err)
+	}
+	var allocations struct {
+		Allocations []allocation `json:"allocations"`
+	}
+	if _, err := client.Get(client.ServiceURL("os-hosts", "allocations"), &allocations, nil); err != nil {
+		if isForbidden(err) {
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("failed to list Blazar host allocations: %w", err)
+	}
+	hostsByID := lo.SliceToMap(hosts.Hosts, func(h host) (flexibleString, Host) {
+		return h.ID, Host{
+			Name:      h.HypervisorHostname,
+			Zone:      h.AvailabilityZone,
+			Resources: Resources{VCPUs: h.VCPUs, MemoryMB: h.MemoryMB, DiskGB: h.LocalGB},
+		}
+	})
+	allocated = map[string][]Host{}
+	for _, a := range allocations.Allocations {
+		h, ok := hostsByID[a.ResourceID]
+		if !ok {
+			continue
+		}
+		for _, r := range a.Reservations {
+			allocated[r.ID] = append(allocated[r.ID], h)
+		}
+	}
+	return allocated, false, nil
+}
+
+func isForbidden(err error) bool {
+	var statusErr gophercloud.StatusCodeError
+	return errors.As(err, &statusErr) && statusErr.GetStatusCode() == http.StatusForbidden
+}
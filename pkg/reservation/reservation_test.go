@@ -0,0 +1,140 @@
+package reservation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/flavors"
+
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/apis/v1openstack"
+	"github.com/ianzx15/karpenter-provider-openstack/pkg/fake"
+)
+
+func TestGet(t *testing.T) {
+	blazar := fake.NewBlazar()
+	defer blazar.Close()
+	blazar.AddHost(fake.BlazarHost{ID: "1", Name: "compute-1", AvailabilityZone: "az-1", VCPUs: 32, MemoryMB: 65536, LocalGB: 500})
+	blazar.AddHost(fake.BlazarHost{ID: "2", Name: "compute-2", AvailabilityZone: "az-1", VCPUs: 32, MemoryMB: 65536, LocalGB: 500})
+	blazar.AddHost(fake.BlazarHost{ID: "3", Name: "compute-3", AvailabilityZone: "az-2", VCPUs: 16, MemoryMB: 32768, LocalGB: 200})
+	blazar.AddLease(fake.Lease{ID: "lease-1", Name: "batch", Reservations: []fake.Reservation{
+		{ID: "host-reservation", ResourceType: HostReservation, Hosts: []string{"1", "2"}},
+		{ID: "instance-reservation", ResourceType: InstanceReservation, Amount: 3, VCPUs: 4, MemoryMB: 8192, DiskGB: 40, Hosts: []string{"3"}},
+		{ID: "floating-ip-reservation", ResourceType: "virtual:floatingip"},
+	}})
+	blazar.AddLease(fake.Lease{ID: "lease-2", Name: "next-week", Status: "PENDING", Reservations: []fake.Reservation{
+		{ID: "pending-reservation", ResourceType: HostReservation, Status: "pending", Hosts: []string{"3"}},
+	}})
+	nova := fake.NewNova()
+	defer nova.Close()
+	nova.AddServer(fake.Server{ID: "server-1", FlavorID: "2", Metadata: map[string]string{v1openstack.ReservationMetadataKey: "host-reservation"}})
+	nova.AddServer(fake.Server{ID: "server-2", FlavorID: "instance-reservation"})
+	nova.AddServer(fake.Server{ID: "server-3", FlavorID: "2"})
+	catalog := []flavors.Flavor{
+		{ID: "2", Name: "m1.large", VCPUs: 4, RAM: 8192, Disk: 40},
+		{ID: "instance-reservation", Name: "reservation:instance-reservation", VCPUs: 4, RAM: 8192, Disk: 40},
+	}
+
+	reservations, err := Get(blazar.ServiceClient(), nova.ServiceClient(), catalog)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	expected := []Reservation{
+		{
+			ID: "host-reservation", LeaseID: "lease-1", LeaseName: "batch", ResourceType: HostReservation,
+			Hosts: []Host{
+				{Name: "compute-1", Zone: "az-1", Resources: Resources{VCPUs: 32, MemoryMB: 65536, DiskGB: 500}},
+				{Name: "compute-2", Zone: "az-1", Resources: Resources{VCPUs: 32, MemoryMB: 65536, DiskGB: 500}},
+			},
+			Instances: 1,
+			Used:      Resources{VCPUs: 4, MemoryMB: 8192, DiskGB: 40},
+		},
+		{
+			ID: "instance-reservation", LeaseID: "lease-1", LeaseName: "batch", ResourceType: InstanceReservation,
+			Hosts:     []Host{{Name: "compute-3", Zone: "az-2", Resources: Resources{VCPUs: 16, MemoryMB: 32768, DiskGB: 200}}},
+			Amount:    3,
+			Resources: Resources{VCPUs: 4, MemoryMB: 8192, DiskGB: 40},
+			Instances: 1,
+			Used:      Resources{VCPUs: 4, MemoryMB: 8192, DiskGB: 40},
+		},
+	}
+	if !reflect.DeepEqual(reservations, expected) {
+		t.Errorf("expected %+v, got %+v", expected, reservations)
+	}
+
+	// Without the admin role, the reserved hosts are unknown.
+	blazar.SetHostsDenied(true)
+	reservations, err = Get(blazar.ServiceClient(), nova.ServiceClient(), catalog)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	for _, r := range reservations {
+		if len(r.Hosts) != 0 {
+			t.Errorf("expected no host for %s, got %+v", r.ID, r.Hosts)
+		}
+		if r.HostsHidden != (r.ResourceType == HostReservation) {
+			t.Errorf("expected the hosts of %s to be reported hidden only for a host reservation", r.ID)
+		}
+	}
+
+	blazar.SetUnavailable(true)
+	if _, err := Get(blazar.ServiceClient(), nova.ServiceClient(), catalog); err == nil {
+		t.Errorf("expected an error when the leases can't be listed")
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	small := flavors.Flavor{Name: "m1.small", VCPUs: 2, RAM: 4096, Disk: 20}
+	large := flavors.Flavor{Name: "m1.large", VCPUs: 4, RAM: 8192, Disk: 40}
+	huge := flavors.Flavor{Name: "m1.huge", VCPUs: 48, RAM: 65536, Disk: 100}
+	hosts := []Host{
+		{Name: "compute-1", Resources: Resources{VCPUs: 32, MemoryMB: 65536, DiskGB: 500}},
+		{Name: "compute-2", Resources: Resources{VCPUs: 32, MemoryMB: 65536, DiskGB: 500}},
+	}
+	tests := []struct {
+		name        string
+		reservation Reservation
+		flavor      flavors.Flavor
+		expected    int
+	}{
+		{
+			name:        "host reservation limited by vCPUs",
+			reservation: Reservation{ResourceType: HostReservation, Hosts: hosts},
+			flavor:      large,
+			expected:    16,
+		},
+		{
+			name:        "host reservation with servers running",
+			reservation: Reservation{ResourceType: HostReservation, Hosts: hosts, Instances: 3, Used: Resources{VCPUs: 60, MemoryMB: 8192, DiskGB: 60}},
+			flavor:      small,
+			expected:    2,
+		},
+		{
+			name:        "flavor larger than every reserved host",
+			reservation: Reservation{ResourceType: HostReservation, Hosts: hosts},
+			flavor:      huge,
+		},
+		{
+			name:        "host reservation without known hosts",
+			reservation: Reservation{ResourceType: HostReservation},
+			flavor:      small,
+		},
+		{
+			name:        "instance reservation",
+			reservation: Reservation{ResourceType: InstanceReservation, Amount: 3, Resources: Resources{VCPUs: 4, MemoryMB: 8192, DiskGB: 40}, Instances: 1},
+			flavor:      large,
+			expected:    2,
+		},
+		{
+			name:        "instance reservation of another size",
+			reservation: Reservation{ResourceType: InstanceReservation, Amount: 3, Resources: Resources{VCPUs: 4, MemoryMB: 8192, DiskGB: 40}},
+			flavor:      small,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.reservation.Capacity(tc.flavor); actual != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, actual)
+			}
+		})
+	}
+}